@@ -40,6 +40,8 @@ const (
 	Groups_6
 	Groups_7
 	Groups_8
+	Groups_9
+	Groups_10
 
 	Potential
 	Threat
@@ -129,6 +131,8 @@ func init() {
 		defaultWeights6, // 6
 		defaultWeights,  // 7
 		defaultWeights,  // 8
+		defaultWeights,  // 9
+		defaultWeights,  // 10
 	}
 }
 
@@ -152,14 +156,15 @@ func evaluateTerminal(p *tak.Position, w *Weights) int64 {
 	}
 
 	var reserves, opponent int64
-	var flats int64
+	// margin of victory in half-flats, including komi
+	half := int64(2*(d.WhiteFlats-d.BlackFlats) - p.Config().Komi)
 	if d.Winner == tak.White {
 		reserves, opponent = int64(p.WhiteStones()), int64(p.BlackStones())
-		flats = int64(d.WhiteFlats - d.BlackFlats)
 	} else {
 		opponent, reserves = int64(p.WhiteStones()), int64(p.BlackStones())
-		flats = int64(d.BlackFlats - d.WhiteFlats)
+		half = -half
 	}
+	flats := half / 2
 	if int(flats) > p.Size() || d.Reason == tak.RoadWin {
 		flats = int64(p.Size())
 	}
@@ -195,7 +200,13 @@ func evaluate(c *bitboard.Constants, w *Weights, p *tak.Position) int64 {
 	if over, _ := p.GameOver(); over {
 		return evaluateTerminal(p, w)
 	}
+	if c.Mask.Hi == 0 {
+		return evaluate64(c, w, p)
+	}
+	return evaluateWide(c, w, p)
+}
 
+func evaluateWide(c *bitboard.Constants, w *Weights, p *tak.Position) int64 {
 	var score int64
 
 	analysis := p.Analysis()
@@ -206,26 +217,26 @@ func evaluate(c *bitboard.Constants, w *Weights, p *tak.Position) int64 {
 		score -= int64(w[TopFlat]/2 + w[Tempo])
 	}
 
-	score += int64(bitboard.Popcount(p.White&^(p.Caps|p.Standing))) * w[TopFlat]
-	score -= int64(bitboard.Popcount(p.Black&^(p.Caps|p.Standing))) * w[TopFlat]
-	score += int64(bitboard.Popcount(p.White&p.Standing)) * w[Standing]
-	score -= int64(bitboard.Popcount(p.Black&p.Standing)) * w[Standing]
-	score += int64(bitboard.Popcount(p.White&p.Caps)) * w[Capstone]
-	score -= int64(bitboard.Popcount(p.Black&p.Caps)) * w[Capstone]
+	score += int64(p.White.AndNot(p.Caps.Or(p.Standing)).Popcount()) * w[TopFlat]
+	score -= int64(p.Black.AndNot(p.Caps.Or(p.Standing)).Popcount()) * w[TopFlat]
+	score += int64(p.White.And(p.Standing).Popcount()) * w[Standing]
+	score -= int64(p.Black.And(p.Standing).Popcount()) * w[Standing]
+	score += int64(p.White.And(p.Caps).Popcount()) * w[Capstone]
+	score -= int64(p.Black.And(p.Caps).Popcount()) * w[Capstone]
 
-	score += int64(bitboard.Popcount(p.White&^c.Edge)) * w[Center]
-	score -= int64(bitboard.Popcount(p.Black&^c.Edge)) * w[Center]
+	score += int64(p.White.AndNot(c.Edge).Popcount()) * w[Center]
+	score -= int64(p.Black.AndNot(c.Edge).Popcount()) * w[Center]
 
 	mask := uint64((1 << c.Size) - 1)
 	for i, h := range p.Height {
 		if h <= 1 {
 			continue
 		}
-		bit := uint64(1 << uint(i))
+		bit := bitboard.Bit(uint(i))
 		s := p.Stacks[i] & ((1 << (h - 1)) - 1) & mask
 		var hf, sf int
 		var sign int64
-		if p.White&bit != 0 {
+		if p.White.Intersects(bit) {
 			sf = bitboard.Popcount(s)
 			hf = int(h) - sf - 1
 			sign = 1
@@ -234,18 +245,18 @@ func evaluate(c *bitboard.Constants, w *Weights, p *tak.Position) int64 {
 			sf = int(h) - hf - 1
 			sign = -1
 		}
-		if p.Caps&bit != 0 {
-			if ((p.Black & bit) == 0) == ((s & 1) == 0) {
+		if p.Caps.Intersects(bit) {
+			if !p.Black.Intersects(bit) == ((s & 1) == 0) {
 				score += sign * w[HardTopCap]
 			}
 			score += sign * w[CapMobility] *
-				int64(bitboard.Popcount(mobility(c, p, bit, int(h))))
+				int64(mobility(c, p, bit, int(h)).Popcount())
 		}
 		if hf > 0 {
 			throw := mobility(c, p, bit, hf)
-			wt := bitboard.Popcount(throw & p.White)
-			bt := bitboard.Popcount(throw & p.Black)
-			et := bitboard.Popcount(throw &^ (p.White | p.Black))
+			wt := throw.And(p.White).Popcount()
+			bt := throw.And(p.Black).Popcount()
+			et := throw.AndNot(p.White.Or(p.Black)).Popcount()
 			if sign == 1 {
 				score += w[ThrowMine]*int64(wt) +
 					w[ThrowTheirs]*int64(bt) +
@@ -258,10 +269,10 @@ func evaluate(c *bitboard.Constants, w *Weights, p *tak.Position) int64 {
 		}
 
 		switch {
-		case p.Standing&(1<<uint(i)) != 0:
+		case p.Standing.Intersects(bit):
 			score += sign * (int64(hf)*w[StandingCaptives_Hard] +
 				int64(sf)*w[StandingCaptives_Soft])
-		case p.Caps&(1<<uint(i)) != 0:
+		case p.Caps.Intersects(bit):
 			score += sign * (int64(hf)*w[CapstoneCaptives_Hard] +
 				int64(sf)*w[CapstoneCaptives_Soft])
 		default:
@@ -270,14 +281,14 @@ func evaluate(c *bitboard.Constants, w *Weights, p *tak.Position) int64 {
 		}
 	}
 
-	score += scoreGroups(c, analysis.WhiteGroups, w, p.Black|p.Standing)
-	score -= scoreGroups(c, analysis.BlackGroups, w, p.White|p.Standing)
+	score += scoreGroups(c, analysis.WhiteGroups, w, p.Black.Or(p.Standing))
+	score -= scoreGroups(c, analysis.BlackGroups, w, p.White.Or(p.Standing))
 
 	if w[Liberties] != 0 {
-		wr := p.White &^ p.Standing
-		br := p.Black &^ p.Standing
-		wl := bitboard.Popcount(bitboard.Grow(c, ^p.Black, wr) &^ p.White)
-		bl := bitboard.Popcount(bitboard.Grow(c, ^p.White, br) &^ p.Black)
+		wr := p.White.AndNot(p.Standing)
+		br := p.Black.AndNot(p.Standing)
+		wl := bitboard.Grow(c, p.Black.Not(), wr).AndNot(p.White).Popcount()
+		bl := bitboard.Grow(c, p.White.Not(), br).AndNot(p.Black).Popcount()
 		score += w[Liberties] * int64(wl)
 		score -= w[Liberties] * int64(bl)
 	}
@@ -291,50 +302,57 @@ func evaluate(c *bitboard.Constants, w *Weights, p *tak.Position) int64 {
 	return -score
 }
 
-func mobility(c *bitboard.Constants, p *tak.Position, bit uint64, height int) uint64 {
+func mobility(c *bitboard.Constants, p *tak.Position, bit bitboard.Bits, height int) bitboard.Bits {
+	if c.Mask.Hi == 0 {
+		return bitboard.Bits{Lo: mobility64(c, p, bit.Lo, height)}
+	}
+	return mobilityWide(c, p, bit, height)
+}
+
+func mobilityWide(c *bitboard.Constants, p *tak.Position, bit bitboard.Bits, height int) bitboard.Bits {
 	m := bit
 
-	stop := ((p.Caps | p.Standing | ^c.Mask) &^ bit)
+	stop := p.Caps.Or(p.Standing).Or(c.Mask.Not()).AndNot(bit)
 
-	e := bit << 1
-	for i := 0; i < height && (e&(stop|c.R)) == 0; i++ {
-		m |= e
-		e <<= 1
+	e := bit.Shl(1)
+	for i := 0; i < height && !e.Intersects(stop.Or(c.R)); i++ {
+		m = m.Or(e)
+		e = e.Shl(1)
 	}
 
-	e = bit >> 1
-	for i := 0; i < height && (e&(stop|c.L)) == 0; i++ {
-		m |= e
-		e >>= 1
+	e = bit.Shr(1)
+	for i := 0; i < height && !e.Intersects(stop.Or(c.L)); i++ {
+		m = m.Or(e)
+		e = e.Shr(1)
 	}
 
-	e = bit << c.Size
-	for i := 0; i < height && (e&stop) == 0; i++ {
-		m |= e
-		e <<= c.Size
+	e = bit.Shl(c.Size)
+	for i := 0; i < height && !e.Intersects(stop); i++ {
+		m = m.Or(e)
+		e = e.Shl(c.Size)
 	}
 
-	e = bit >> c.Size
-	for i := 0; i < height && (e&stop) == 0; i++ {
-		m |= e
-		e >>= c.Size
+	e = bit.Shr(c.Size)
+	for i := 0; i < height && !e.Intersects(stop); i++ {
+		m = m.Or(e)
+		e = e.Shr(c.Size)
 	}
 
 	return m
 }
 
-func scoreGroups(c *bitboard.Constants, gs []uint64, ws *Weights, other uint64) int64 {
+func scoreGroups(c *bitboard.Constants, gs []bitboard.Bits, ws *Weights, other bitboard.Bits) int64 {
 	var sc int64
-	var allg uint64
+	var allg bitboard.Bits
 	for _, g := range gs {
 		w, h := bitboard.Dimensions(c, g)
 
 		sc += ws[int(Groups)+w]
 		sc += ws[int(Groups)+h]
-		allg |= g
+		allg = allg.Or(g)
 	}
 	if ws[GroupLiberties] != 0 {
-		libs := bitboard.Popcount(bitboard.Grow(c, ^other, allg) &^ allg)
+		libs := bitboard.Grow(c, other.Not(), allg).AndNot(allg).Popcount()
 		sc += int64(libs) * ws[GroupLiberties]
 	}
 
@@ -342,72 +360,79 @@ func scoreGroups(c *bitboard.Constants, gs []uint64, ws *Weights, other uint64)
 }
 
 func CountThreats(c *bitboard.Constants, p *tak.Position) (wp, wt, bp, bt int) {
+	if c.Mask.Hi == 0 {
+		return countThreats64(c, p)
+	}
+	return countThreatsWide(c, p)
+}
+
+func countThreatsWide(c *bitboard.Constants, p *tak.Position) (wp, wt, bp, bt int) {
 	analysis := p.Analysis()
-	empty := c.Mask &^ (p.White | p.Black)
+	empty := c.Mask.AndNot(p.White.Or(p.Black))
 
-	countOne := func(gs []uint64, pieces uint64) (int, int) {
+	countOne := func(gs []bitboard.Bits, pieces bitboard.Bits) (int, int) {
 		var place, threat int
 		singles := pieces
 		for _, g := range gs {
-			singles &= ^g
+			singles = singles.AndNot(g)
 		}
 		for i, g := range gs {
-			if g&c.Edge == 0 {
+			if !g.Intersects(c.Edge) {
 				continue
 			}
-			var pmap, tmap uint64
+			var pmap, tmap bitboard.Bits
 
-			slides := bitboard.Grow(c, c.Mask&^(p.Standing|p.Caps), pieces&^g)
-			if g&c.L != 0 {
-				pmap |= (g >> 1) & empty & c.R
-				tmap |= (g >> 1) & slides & c.R
+			slides := bitboard.Grow(c, c.Mask.AndNot(p.Standing.Or(p.Caps)), pieces.AndNot(g))
+			if g.Intersects(c.L) {
+				pmap = pmap.Or(g.Shr(1).And(empty).And(c.R))
+				tmap = tmap.Or(g.Shr(1).And(slides).And(c.R))
 			}
-			if g&c.R != 0 {
-				pmap |= (g << 1) & empty & c.L
-				tmap |= (g << 1) & slides & c.L
+			if g.Intersects(c.R) {
+				pmap = pmap.Or(g.Shl(1).And(empty).And(c.L))
+				tmap = tmap.Or(g.Shl(1).And(slides).And(c.L))
 			}
-			if g&c.T != 0 {
-				pmap |= (g >> c.Size) & empty & c.B
-				tmap |= (g >> c.Size) & slides & c.B
+			if g.Intersects(c.T) {
+				pmap = pmap.Or(g.Shr(c.Size).And(empty).And(c.B))
+				tmap = tmap.Or(g.Shr(c.Size).And(slides).And(c.B))
 			}
-			if g&c.B != 0 {
-				pmap |= (g << c.Size) & empty & c.T
-				tmap |= (g << c.Size) & slides & c.T
+			if g.Intersects(c.B) {
+				pmap = pmap.Or(g.Shl(c.Size).And(empty).And(c.T))
+				tmap = tmap.Or(g.Shl(c.Size).And(slides).And(c.T))
 			}
 
 			s := singles
 			j := 0
 			for {
-				var other uint64
+				var other bitboard.Bits
 				if j < i {
 					other = gs[j]
 					j++
-				} else if s != 0 {
-					next := s & (s - 1)
-					other = s &^ next
+				} else if !s.IsZero() {
+					next := s.ClearLowest()
+					other = s.AndNot(next)
 					s = next
 				} else {
 					break
 				}
-				if !((g&c.L != 0 && other&c.R != 0) ||
-					(g&c.R != 0 && other&c.L != 0) ||
-					(g&c.B != 0 && other&c.T != 0) ||
-					(g&c.T != 0 && other&c.B != 0)) {
+				if !((g.Intersects(c.L) && other.Intersects(c.R)) ||
+					(g.Intersects(c.R) && other.Intersects(c.L)) ||
+					(g.Intersects(c.B) && other.Intersects(c.T)) ||
+					(g.Intersects(c.T) && other.Intersects(c.B))) {
 					continue
 				}
-				slides := bitboard.Grow(c, c.Mask&^(p.Standing|p.Caps), pieces&^(g|other))
-				isect := bitboard.Grow(c, c.Mask, g) &
-					bitboard.Grow(c, c.Mask, other)
-				pmap |= isect & empty
-				tmap |= isect & slides
+				slides := bitboard.Grow(c, c.Mask.AndNot(p.Standing.Or(p.Caps)), pieces.AndNot(g.Or(other)))
+				isect := bitboard.Grow(c, c.Mask, g).And(
+					bitboard.Grow(c, c.Mask, other))
+				pmap = pmap.Or(isect.And(empty))
+				tmap = tmap.Or(isect.And(slides))
 			}
-			place += bitboard.Popcount(pmap)
-			threat += bitboard.Popcount(tmap)
+			place += pmap.Popcount()
+			threat += tmap.Popcount()
 		}
 		return place, threat
 	}
-	wp, wt = countOne(analysis.WhiteGroups, p.White&^(p.Standing|p.Caps))
-	bp, bt = countOne(analysis.BlackGroups, p.Black&^(p.Standing|p.Caps))
+	wp, wt = countOne(analysis.WhiteGroups, p.White.AndNot(p.Standing.Or(p.Caps)))
+	bp, bt = countOne(analysis.BlackGroups, p.Black.AndNot(p.Standing.Or(p.Caps)))
 	return
 }
 
@@ -428,45 +453,53 @@ func scoreThreats(c *bitboard.Constants, ws *Weights, p *tak.Position) int64 {
 	return int64(wp-bp)*ws[Potential] + int64(wt-bt)*ws[Threat]
 }
 
-func computeInfluence(c *bitboard.Constants, mine uint64, out []uint64) {
-	for mine != 0 {
-		next := mine & (mine - 1)
-		bit := mine &^ next
+func computeInfluence(c *bitboard.Constants, mine bitboard.Bits, out []bitboard.Bits) {
+	for !mine.IsZero() {
+		next := mine.ClearLowest()
+		bit := mine.AndNot(next)
 		mine = next
 
-		g := bitboard.Grow(c, c.Mask, bit) &^ bit
+		g := bitboard.Grow(c, c.Mask, bit).AndNot(bit)
 
 		carry := g
-		for i := 0; carry != 0 && i < len(out); i++ {
-			cout := out[i] & carry
-			out[i] ^= carry
+		for i := 0; !carry.IsZero() && i < len(out); i++ {
+			cout := out[i].And(carry)
+			out[i] = out[i].Xor(carry)
 			carry = cout
 		}
-		if carry != 0 {
-			out[len(out)-1] |= carry
+		if !carry.IsZero() {
+			out[len(out)-1] = out[len(out)-1].Or(carry)
 		}
 	}
 }
 
-func computeControl(c *bitboard.Constants, p *tak.Position) (uint64, uint64) {
-	var wi, bi [3]uint64
-	computeInfluence(c, p.White&^(p.Caps|p.Standing), wi[:])
-	computeInfluence(c, p.Black&^(p.Caps|p.Standing), bi[:])
-	var bc, wc uint64
+func computeControl(c *bitboard.Constants, p *tak.Position) (bitboard.Bits, bitboard.Bits) {
+	if c.Mask.Hi == 0 {
+		wc, bc := computeControl64(c, p)
+		return bitboard.Bits{Lo: wc}, bitboard.Bits{Lo: bc}
+	}
+	return computeControlWide(c, p)
+}
+
+func computeControlWide(c *bitboard.Constants, p *tak.Position) (bitboard.Bits, bitboard.Bits) {
+	var wi, bi [3]bitboard.Bits
+	computeInfluence(c, p.White.AndNot(p.Caps.Or(p.Standing)), wi[:])
+	computeInfluence(c, p.Black.AndNot(p.Caps.Or(p.Standing)), bi[:])
+	var bc, wc bitboard.Bits
 	for i := len(wi) - 1; i >= 0; i-- {
-		wb := wi[i] &^ (wc | bc)
-		bb := bi[i] &^ (wc | bc)
+		wb := wi[i].AndNot(wc.Or(bc))
+		bb := bi[i].AndNot(wc.Or(bc))
 
-		wc |= (wb &^ bb)
-		bc |= (bb &^ wb)
+		wc = wc.Or(wb.AndNot(bb))
+		bc = bc.Or(bb.AndNot(wb))
 	}
 	block := bitboard.Grow(c, c.Mask, p.Standing)
-	wcap := bitboard.Grow(c, c.Mask, p.Caps&p.White)
-	bcap := bitboard.Grow(c, c.Mask, p.Caps&p.Black)
-	wc |= wcap &^ bcap
-	bc |= bcap &^ wcap
-	wc &= ^block
-	bc &= ^block
+	wcap := bitboard.Grow(c, c.Mask, p.Caps.And(p.White))
+	bcap := bitboard.Grow(c, c.Mask, p.Caps.And(p.Black))
+	wc = wc.Or(wcap.AndNot(bcap))
+	bc = bc.Or(bcap.AndNot(wcap))
+	wc = wc.AndNot(block)
+	bc = bc.AndNot(block)
 	return wc, bc
 }
 
@@ -476,15 +509,15 @@ func scoreControl(c *bitboard.Constants, ws *Weights, p *tak.Position) int64 {
 	}
 	wc, bc := computeControl(c, p)
 
-	empty := c.Mask &^ (p.White | p.Black)
-	flat := (p.White | p.Black) &^ (p.Standing | p.Caps)
+	empty := c.Mask.AndNot(p.White.Or(p.Black))
+	flat := p.White.Or(p.Black).AndNot(p.Standing.Or(p.Caps))
 	var s int64
 	s += ws[EmptyControl] *
-		int64(bitboard.Popcount(wc&empty)-bitboard.Popcount(bc&empty))
+		int64(wc.And(empty).Popcount()-bc.And(empty).Popcount())
 	s += ws[FlatControl] *
-		int64(bitboard.Popcount(wc&flat)-bitboard.Popcount(bc&flat))
+		int64(wc.And(flat).Popcount()-bc.And(flat).Popcount())
 	s += ws[CenterControl] *
-		int64(bitboard.Popcount(wc&^c.Edge)-bitboard.Popcount(bc&^c.Edge))
+		int64(wc.AndNot(c.Edge).Popcount()-bc.AndNot(c.Edge).Popcount())
 	return s
 }
 
@@ -500,12 +533,12 @@ func ExplainScore(m *MinimaxAI, out io.Writer, p *tak.Position) {
 		captured int
 	}
 
-	scores[0].flats = bitboard.Popcount(p.White &^ (p.Caps | p.Standing))
-	scores[1].flats = bitboard.Popcount(p.Black &^ (p.Caps | p.Standing))
-	scores[0].standing = bitboard.Popcount(p.White & p.Standing)
-	scores[1].standing = bitboard.Popcount(p.Black & p.Standing)
-	scores[0].caps = bitboard.Popcount(p.White & p.Caps)
-	scores[1].caps = bitboard.Popcount(p.Black & p.Caps)
+	scores[0].flats = p.White.AndNot(p.Caps.Or(p.Standing)).Popcount()
+	scores[1].flats = p.Black.AndNot(p.Caps.Or(p.Standing)).Popcount()
+	scores[0].standing = p.White.And(p.Standing).Popcount()
+	scores[1].standing = p.Black.And(p.Standing).Popcount()
+	scores[0].caps = p.White.And(p.Caps).Popcount()
+	scores[1].caps = p.Black.And(p.Caps).Popcount()
 
 	for i, h := range p.Height {
 		if h <= 1 {
@@ -513,6 +546,15 @@ func ExplainScore(m *MinimaxAI, out io.Writer, p *tak.Position) {
 		}
 		s := p.Stacks[i] & ((1 << (h - 1)) - 1)
 		bf := bitboard.Popcount(s)
+		if h > 65 {
+			// Stacks holds only the top 64 captives.
+			bf = 0
+			for _, pc := range p.At(i%p.Size(), i/p.Size())[1:] {
+				if pc.Color() == tak.Black {
+					bf++
+				}
+			}
+		}
 		wf := int(h) - bf - 1
 		scores[0].stones += wf
 		scores[1].stones += bf
@@ -521,7 +563,7 @@ func ExplainScore(m *MinimaxAI, out io.Writer, p *tak.Position) {
 		if captured > p.Size()-1 {
 			captured = p.Size() - 1
 		}
-		if p.White&(1<<uint(i)) != 0 {
+		if p.White.Has(uint(i)) {
 			scores[0].captured += captured
 		} else {
 			scores[1].captured += captured
@@ -536,10 +578,10 @@ func ExplainScore(m *MinimaxAI, out io.Writer, p *tak.Position) {
 
 	analysis := p.Analysis()
 
-	wr := p.White &^ p.Standing
-	br := p.Black &^ p.Standing
-	wl := bitboard.Popcount(bitboard.Grow(&m.c, ^p.Black, wr) &^ p.White)
-	bl := bitboard.Popcount(bitboard.Grow(&m.c, ^p.White, br) &^ p.Black)
+	wr := p.White.AndNot(p.Standing)
+	br := p.Black.AndNot(p.Standing)
+	wl := bitboard.Grow(&m.c, p.Black.Not(), wr).AndNot(p.White).Popcount()
+	bl := bitboard.Grow(&m.c, p.White.Not(), br).AndNot(p.Black).Popcount()
 
 	fmt.Fprintf(tw, "liberties\t%d\t%d\n", wl, bl)
 
@@ -549,23 +591,23 @@ func ExplainScore(m *MinimaxAI, out io.Writer, p *tak.Position) {
 
 	wc, bc := computeControl(&m.c, p)
 	fmt.Fprintf(tw, "control\t%d\t%d\n",
-		bitboard.Popcount(wc),
-		bitboard.Popcount(bc))
+		wc.Popcount(),
+		bc.Popcount())
 
-	var allg uint64
+	var allg bitboard.Bits
 	for i, g := range analysis.WhiteGroups {
 		w, h := bitboard.Dimensions(&m.c, g)
 		fmt.Fprintf(tw, "g%d\t%dx%x\n", i, w, h)
-		allg |= g
+		allg = allg.Or(g)
 	}
-	wgl := bitboard.Popcount(bitboard.Grow(&m.c, m.c.Mask&^(p.Black|p.Standing), allg) &^ allg)
-	allg = 0
+	wgl := bitboard.Grow(&m.c, m.c.Mask.AndNot(p.Black.Or(p.Standing)), allg).AndNot(allg).Popcount()
+	allg = bitboard.Bits{}
 	for i, g := range analysis.BlackGroups {
 		w, h := bitboard.Dimensions(&m.c, g)
 		fmt.Fprintf(tw, "g%d\t\t%dx%x\n", i, w, h)
-		allg |= g
+		allg = allg.Or(g)
 	}
-	bgl := bitboard.Popcount(bitboard.Grow(&m.c, m.c.Mask&^(p.White|p.Standing), allg) &^ allg)
+	bgl := bitboard.Grow(&m.c, m.c.Mask.AndNot(p.White.Or(p.Standing)), allg).AndNot(allg).Popcount()
 	fmt.Fprintf(tw, "gl\t%d\t%d\n", wgl, bgl)
 	tw.Flush()
 }
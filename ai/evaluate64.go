@@ -0,0 +1,305 @@
+package ai
+
+import (
+	"github.com/nelhage/taktician/bitboard"
+	"github.com/nelhage/taktician/tak"
+)
+
+// The functions in this file are single-word specializations of the
+// evaluator, used for boards of size 8 and smaller, where every
+// bitboard fits in bitboard.Bits.Lo. They must compute exactly what
+// their general counterparts in evaluate.go do; TestEvaluateNarrow
+// checks that they agree.
+//
+// Keeping two copies is deliberate: BenchmarkNarrow shows the narrow
+// helpers 1.5-2.5x faster than the general ones on a 5x5 board, and
+// evaluate64 about 25% faster than evaluateWide even when the latter
+// calls the narrow helpers. A single generic implementation over
+// uint64 and bitboard.Bits was slower than either, since Go calls the
+// methods of a type parameter through a dictionary.
+
+func evaluate64(c *bitboard.Constants, w *Weights, p *tak.Position) int64 {
+	var score int64
+
+	analysis := p.Analysis()
+	white, black := p.White.Lo, p.Black.Lo
+	standing, caps := p.Standing.Lo, p.Caps.Lo
+
+	if p.ToMove() == tak.White {
+		score += int64(w[TopFlat]/2 + w[Tempo])
+	} else {
+		score -= int64(w[TopFlat]/2 + w[Tempo])
+	}
+
+	score += int64(bitboard.Popcount(white&^(caps|standing))) * w[TopFlat]
+	score -= int64(bitboard.Popcount(black&^(caps|standing))) * w[TopFlat]
+	score += int64(bitboard.Popcount(white&standing)) * w[Standing]
+	score -= int64(bitboard.Popcount(black&standing)) * w[Standing]
+	score += int64(bitboard.Popcount(white&caps)) * w[Capstone]
+	score -= int64(bitboard.Popcount(black&caps)) * w[Capstone]
+
+	score += int64(bitboard.Popcount(white&^c.Edge.Lo)) * w[Center]
+	score -= int64(bitboard.Popcount(black&^c.Edge.Lo)) * w[Center]
+
+	mask := uint64((1 << c.Size) - 1)
+	for i, h := range p.Height {
+		if h <= 1 {
+			continue
+		}
+		bit := uint64(1 << uint(i))
+		s := p.Stacks[i] & ((1 << (h - 1)) - 1) & mask
+		var hf, sf int
+		var sign int64
+		if white&bit != 0 {
+			sf = bitboard.Popcount(s)
+			hf = int(h) - sf - 1
+			sign = 1
+		} else {
+			hf = bitboard.Popcount(s)
+			sf = int(h) - hf - 1
+			sign = -1
+		}
+		if caps&bit != 0 {
+			if ((black & bit) == 0) == ((s & 1) == 0) {
+				score += sign * w[HardTopCap]
+			}
+			score += sign * w[CapMobility] *
+				int64(bitboard.Popcount(mobility64(c, p, bit, int(h))))
+		}
+		if hf > 0 {
+			throw := mobility64(c, p, bit, hf)
+			wt := bitboard.Popcount(throw & white)
+			bt := bitboard.Popcount(throw & black)
+			et := bitboard.Popcount(throw &^ (white | black))
+			if sign == 1 {
+				score += w[ThrowMine]*int64(wt) +
+					w[ThrowTheirs]*int64(bt) +
+					w[ThrowEmpty]*int64(et)
+			} else {
+				score += w[ThrowMine]*int64(bt) +
+					w[ThrowTheirs]*int64(wt) +
+					w[ThrowEmpty]*int64(et)
+			}
+		}
+
+		switch {
+		case standing&bit != 0:
+			score += sign * (int64(hf)*w[StandingCaptives_Hard] +
+				int64(sf)*w[StandingCaptives_Soft])
+		case caps&bit != 0:
+			score += sign * (int64(hf)*w[CapstoneCaptives_Hard] +
+				int64(sf)*w[CapstoneCaptives_Soft])
+		default:
+			score += sign * (int64(hf)*w[FlatCaptives_Hard] +
+				int64(sf)*w[FlatCaptives_Soft])
+		}
+	}
+
+	score += scoreGroups64(c, analysis.WhiteGroups, w, black|standing)
+	score -= scoreGroups64(c, analysis.BlackGroups, w, white|standing)
+
+	if w[Liberties] != 0 {
+		wr := white &^ standing
+		br := black &^ standing
+		wl := bitboard.Popcount(bitboard.Grow64(c, ^black, wr) &^ white)
+		bl := bitboard.Popcount(bitboard.Grow64(c, ^white, br) &^ black)
+		score += w[Liberties] * int64(wl)
+		score -= w[Liberties] * int64(bl)
+	}
+
+	score += scoreThreats(c, w, p)
+	score += scoreControl64(c, w, p)
+
+	if p.ToMove() == tak.White {
+		return score
+	}
+	return -score
+}
+
+func mobility64(c *bitboard.Constants, p *tak.Position, bit uint64, height int) uint64 {
+	m := bit
+
+	stop := ((p.Caps.Lo | p.Standing.Lo | ^c.Mask.Lo) &^ bit)
+
+	e := bit << 1
+	for i := 0; i < height && (e&(stop|c.R.Lo)) == 0; i++ {
+		m |= e
+		e <<= 1
+	}
+
+	e = bit >> 1
+	for i := 0; i < height && (e&(stop|c.L.Lo)) == 0; i++ {
+		m |= e
+		e >>= 1
+	}
+
+	e = bit << c.Size
+	for i := 0; i < height && (e&stop) == 0; i++ {
+		m |= e
+		e <<= c.Size
+	}
+
+	e = bit >> c.Size
+	for i := 0; i < height && (e&stop) == 0; i++ {
+		m |= e
+		e >>= c.Size
+	}
+
+	return m
+}
+
+func scoreGroups64(c *bitboard.Constants, gs []bitboard.Bits, ws *Weights, other uint64) int64 {
+	var sc int64
+	var allg uint64
+	for _, g := range gs {
+		w, h := bitboard.Dimensions(c, g)
+
+		sc += ws[int(Groups)+w]
+		sc += ws[int(Groups)+h]
+		allg |= g.Lo
+	}
+	if ws[GroupLiberties] != 0 {
+		libs := bitboard.Popcount(bitboard.Grow64(c, ^other, allg) &^ allg)
+		sc += int64(libs) * ws[GroupLiberties]
+	}
+
+	return sc
+}
+
+func countThreats64(c *bitboard.Constants, p *tak.Position) (wp, wt, bp, bt int) {
+	analysis := p.Analysis()
+	white, black := p.White.Lo, p.Black.Lo
+	standing, caps := p.Standing.Lo, p.Caps.Lo
+	mask, edge := c.Mask.Lo, c.Edge.Lo
+	L, R, T, B := c.L.Lo, c.R.Lo, c.T.Lo, c.B.Lo
+	empty := mask &^ (white | black)
+
+	countOne := func(gs []bitboard.Bits, pieces uint64) (int, int) {
+		var place, threat int
+		singles := pieces
+		for _, g := range gs {
+			singles &= ^g.Lo
+		}
+		for i, gb := range gs {
+			g := gb.Lo
+			if g&edge == 0 {
+				continue
+			}
+			var pmap, tmap uint64
+
+			slides := bitboard.Grow64(c, mask&^(standing|caps), pieces&^g)
+			if g&L != 0 {
+				pmap |= (g >> 1) & empty & R
+				tmap |= (g >> 1) & slides & R
+			}
+			if g&R != 0 {
+				pmap |= (g << 1) & empty & L
+				tmap |= (g << 1) & slides & L
+			}
+			if g&T != 0 {
+				pmap |= (g >> c.Size) & empty & B
+				tmap |= (g >> c.Size) & slides & B
+			}
+			if g&B != 0 {
+				pmap |= (g << c.Size) & empty & T
+				tmap |= (g << c.Size) & slides & T
+			}
+
+			s := singles
+			j := 0
+			for {
+				var other uint64
+				if j < i {
+					other = gs[j].Lo
+					j++
+				} else if s != 0 {
+					next := s & (s - 1)
+					other = s &^ next
+					s = next
+				} else {
+					break
+				}
+				if !((g&L != 0 && other&R != 0) ||
+					(g&R != 0 && other&L != 0) ||
+					(g&B != 0 && other&T != 0) ||
+					(g&T != 0 && other&B != 0)) {
+					continue
+				}
+				slides := bitboard.Grow64(c, mask&^(standing|caps), pieces&^(g|other))
+				isect := bitboard.Grow64(c, mask, g) &
+					bitboard.Grow64(c, mask, other)
+				pmap |= isect & empty
+				tmap |= isect & slides
+			}
+			place += bitboard.Popcount(pmap)
+			threat += bitboard.Popcount(tmap)
+		}
+		return place, threat
+	}
+	wp, wt = countOne(analysis.WhiteGroups, white&^(standing|caps))
+	bp, bt = countOne(analysis.BlackGroups, black&^(standing|caps))
+	return
+}
+
+func computeInfluence64(c *bitboard.Constants, mine uint64, out []uint64) {
+	for mine != 0 {
+		next := mine & (mine - 1)
+		bit := mine &^ next
+		mine = next
+
+		g := bitboard.Grow64(c, c.Mask.Lo, bit) &^ bit
+
+		carry := g
+		for i := 0; carry != 0 && i < len(out); i++ {
+			cout := out[i] & carry
+			out[i] ^= carry
+			carry = cout
+		}
+		if carry != 0 {
+			out[len(out)-1] |= carry
+		}
+	}
+}
+
+func computeControl64(c *bitboard.Constants, p *tak.Position) (uint64, uint64) {
+	white, black := p.White.Lo, p.Black.Lo
+	standing, caps := p.Standing.Lo, p.Caps.Lo
+	var wi, bi [3]uint64
+	computeInfluence64(c, white&^(caps|standing), wi[:])
+	computeInfluence64(c, black&^(caps|standing), bi[:])
+	var bc, wc uint64
+	for i := len(wi) - 1; i >= 0; i-- {
+		wb := wi[i] &^ (wc | bc)
+		bb := bi[i] &^ (wc | bc)
+
+		wc |= (wb &^ bb)
+		bc |= (bb &^ wb)
+	}
+	block := bitboard.Grow64(c, c.Mask.Lo, standing)
+	wcap := bitboard.Grow64(c, c.Mask.Lo, caps&white)
+	bcap := bitboard.Grow64(c, c.Mask.Lo, caps&black)
+	wc |= wcap &^ bcap
+	bc |= bcap &^ wcap
+	wc &= ^block
+	bc &= ^block
+	return wc, bc
+}
+
+func scoreControl64(c *bitboard.Constants, ws *Weights, p *tak.Position) int64 {
+	if ws[EmptyControl] == 0 && ws[FlatControl] == 0 {
+		return 0
+	}
+	wc, bc := computeControl64(c, p)
+
+	white, black := p.White.Lo, p.Black.Lo
+	empty := c.Mask.Lo &^ (white | black)
+	flat := (white | black) &^ (p.Standing.Lo | p.Caps.Lo)
+	var s int64
+	s += ws[EmptyControl] *
+		int64(bitboard.Popcount(wc&empty)-bitboard.Popcount(bc&empty))
+	s += ws[FlatControl] *
+		int64(bitboard.Popcount(wc&flat)-bitboard.Popcount(bc&flat))
+	s += ws[CenterControl] *
+		int64(bitboard.Popcount(wc&^c.Edge.Lo)-bitboard.Popcount(bc&^c.Edge.Lo))
+	return s
+}
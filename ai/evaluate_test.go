@@ -3,6 +3,7 @@ package ai
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"strconv"
 	"testing"
 
@@ -74,6 +75,28 @@ func TestEvaluateTerminal(t *testing.T) {
 	}
 }
 
+func TestEvaluateTerminalKomi(t *testing.T) {
+	cases := []struct {
+		komi     int
+		min, max int64
+	}{
+		{0, 0, 0},
+		{1, -MaxEval, -WinThreshold},
+		{-1, WinThreshold, MaxEval},
+	}
+	for _, tc := range cases {
+		p, e := ptn.ParseTPSWithConfig("1,2,1,2/2,1,2,1/1,2,1,2/2,1,2,1 1 9",
+			tak.Config{Komi: tc.komi})
+		if e != nil {
+			t.Fatal("tps:", e)
+		}
+		v := evaluateTerminal(p, &defaultWeights)
+		if v < tc.min || v > tc.max {
+			t.Errorf("komi=%d: eval=%d (not in [%d,%d])", tc.komi, v, tc.min, tc.max)
+		}
+	}
+}
+
 func benchmarkEval(b *testing.B, tps string) {
 	p, e := ptn.ParseTPS(tps)
 	if e != nil {
@@ -98,6 +121,59 @@ func BenchmarkEvalMidGame(b *testing.B) {
 	benchmarkEval(b, `x3,2,x/x4,12/1,1,x,1,21C/x,1,x,12111112C,2/2,x,22121,x,2 2 20`)
 }
 
+// BenchmarkNarrow compares each single-word function in
+// evaluate64.go with its general counterpart, on the same position.
+// The specializations are only worth keeping while the narrow
+// versions are measurably faster. evaluateWide still calls the narrow
+// helpers on small boards, so "evaluate" measures evaluate64 alone.
+func BenchmarkNarrow(b *testing.B) {
+	p, e := ptn.ParseTPS(`x3,2,x/x4,12/1,1,x,1,21C/x,1,x,12111112C,2/2,x,22121,x,2 2 20`)
+	if e != nil {
+		b.Fatal("tps:", e)
+	}
+	c := bitboard.Precompute(uint(p.Size()))
+	w := DefaultWeights[p.Size()]
+	w[Potential] = 100
+	w[Threat] = 300
+	cases := []struct {
+		name         string
+		narrow, wide func()
+	}{
+		{"evaluate",
+			func() { evaluate64(&c, &w, p) },
+			func() { evaluateWide(&c, &w, p) }},
+		{"threats",
+			func() { countThreats64(&c, p) },
+			func() { countThreatsWide(&c, p) }},
+		{"control",
+			func() { computeControl64(&c, p) },
+			func() { computeControlWide(&c, p) }},
+		{"mobility",
+			func() {
+				for i := range p.Height {
+					mobility64(&c, p, 1<<uint(i), p.Size())
+				}
+			},
+			func() {
+				for i := range p.Height {
+					mobilityWide(&c, p, bitboard.Bit(uint(i)), p.Size())
+				}
+			}},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name+"/narrow", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tc.narrow()
+			}
+		})
+		b.Run(tc.name+"/wide", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tc.wide()
+			}
+		})
+	}
+}
+
 func TestHardTopCap(t *testing.T) {
 	cases := []struct {
 		tps string
@@ -184,13 +260,13 @@ BC . .  .  .
 			}
 			c := bitboard.Precompute(uint(p.Size()))
 			for _, ic := range tc.inner {
-				bit := uint64(1) << uint(ic.x+p.Size()*ic.y)
+				bit := bitboard.Bit(uint(ic.x + p.Size()*ic.y))
 				sqs := mobility(&c, p, bit, ic.h)
-				m := bitboard.Popcount(sqs)
+				m := sqs.Popcount()
 				if m != ic.mobility {
 					t.Errorf("(%d,%d): got %d (%s) != %d",
 						ic.x, ic.y, m,
-						strconv.FormatUint(sqs, 2), ic.mobility)
+						strconv.FormatUint(sqs.Lo, 2), ic.mobility)
 				}
 			}
 		})
@@ -315,25 +391,25 @@ W . W . .
 	}
 	c := bitboard.Precompute(uint(p.Size()))
 
-	var out [3]uint64
+	var out [3]bitboard.Bits
 	computeInfluence(&c, p.White, out[:])
-	expect := []uint64{
-		0x10100,
-		0x1405,
-		0x40,
+	expect := []bitboard.Bits{
+		{Lo: 0x10100},
+		{Lo: 0x1405},
+		{Lo: 0x40},
 	}
 	for i, o := range out {
 		if o != expect[i] {
 			t.Errorf("[%d]=%25s != %25s",
 				i,
-				strconv.FormatUint(o, 2),
-				strconv.FormatUint(expect[i], 2))
+				strconv.FormatUint(o.Lo, 2),
+				strconv.FormatUint(expect[i].Lo, 2))
 		}
 	}
 
-	var sat [2]uint64
+	var sat [2]bitboard.Bits
 	computeInfluence(&c, p.White, sat[:])
-	if sat[1] != expect[1]|expect[2] {
+	if sat[1] != expect[1].Or(expect[2]) {
 		t.Error("bad saturate")
 	}
 }
@@ -424,3 +500,58 @@ func TestCenterControl(t *testing.T) {
 		t.Errorf("CenterControl %d != 204", sc)
 	}
 }
+
+func TestEvaluateNarrow(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for size := 3; size <= 8; size++ {
+		c := bitboard.Precompute(uint(size))
+		w := DefaultWeights[size]
+		w[Potential] = 100
+		w[Threat] = 300
+		for game := 0; game < 20; game++ {
+			p := tak.New(tak.Config{Size: size})
+			var buf []tak.Move
+			for ply := 0; ply < 150; ply++ {
+				if over, _ := p.GameOver(); over {
+					break
+				}
+				if got, want := evaluate64(&c, &w, p), evaluateWide(&c, &w, p); got != want {
+					t.Fatalf("evaluate(%s): narrow=%d wide=%d", ptn.FormatTPS(p), got, want)
+				}
+				wp, wt, bp, bt := countThreats64(&c, p)
+				xp, xt, yp, yt := countThreatsWide(&c, p)
+				if wp != xp || wt != xt || bp != yp || bt != yt {
+					t.Fatalf("CountThreats(%s): narrow=%v wide=%v",
+						ptn.FormatTPS(p), []int{wp, wt, bp, bt}, []int{xp, xt, yp, yt})
+				}
+				wc, bc := computeControl64(&c, p)
+				xc, yc := computeControlWide(&c, p)
+				if wc != xc.Lo || bc != yc.Lo || xc.Hi != 0 || yc.Hi != 0 {
+					t.Fatalf("computeControl(%s): narrow=%x/%x wide=%v/%v",
+						ptn.FormatTPS(p), wc, bc, xc, yc)
+				}
+				for i, h := range p.Height {
+					if h == 0 {
+						continue
+					}
+					bit := bitboard.Bit(uint(i))
+					got := mobility64(&c, p, bit.Lo, int(h))
+					want := mobilityWide(&c, p, bit, int(h))
+					if got != want.Lo || want.Hi != 0 {
+						t.Fatalf("mobility(%s, %d): narrow=%x wide=%v",
+							ptn.FormatTPS(p), i, got, want)
+					}
+				}
+
+				buf = p.AllMoves(buf[:0])
+				for {
+					next, err := p.Move(buf[r.Intn(len(buf))])
+					if err == nil {
+						p = next
+						break
+					}
+				}
+			}
+		}
+	}
+}
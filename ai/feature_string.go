@@ -31,25 +31,27 @@ func _() {
 	_ = x[Groups_6-20]
 	_ = x[Groups_7-21]
 	_ = x[Groups_8-22]
-	_ = x[Potential-23]
-	_ = x[Threat-24]
-	_ = x[EmptyControl-25]
-	_ = x[FlatControl-26]
-	_ = x[Center-27]
-	_ = x[CenterControl-28]
-	_ = x[ThrowMine-29]
-	_ = x[ThrowTheirs-30]
-	_ = x[ThrowEmpty-31]
-	_ = x[Terminal_Plies-32]
-	_ = x[Terminal_Flats-33]
-	_ = x[Terminal_Reserves-34]
-	_ = x[Terminal_OpponentReserves-35]
-	_ = x[MaxFeature-36]
+	_ = x[Groups_9-23]
+	_ = x[Groups_10-24]
+	_ = x[Potential-25]
+	_ = x[Threat-26]
+	_ = x[EmptyControl-27]
+	_ = x[FlatControl-28]
+	_ = x[Center-29]
+	_ = x[CenterControl-30]
+	_ = x[ThrowMine-31]
+	_ = x[ThrowTheirs-32]
+	_ = x[ThrowEmpty-33]
+	_ = x[Terminal_Plies-34]
+	_ = x[Terminal_Flats-35]
+	_ = x[Terminal_Reserves-36]
+	_ = x[Terminal_OpponentReserves-37]
+	_ = x[MaxFeature-38]
 }
 
-const _Feature_name = "TempoTopFlatStandingCapstoneHardTopCapCapMobilityFlatCaptives_SoftFlatCaptives_HardStandingCaptives_SoftStandingCaptives_HardCapstoneCaptives_SoftCapstoneCaptives_HardLibertiesGroupLibertiesGroupsGroups_1Groups_2Groups_3Groups_4Groups_5Groups_6Groups_7Groups_8PotentialThreatEmptyControlFlatControlCenterCenterControlThrowMineThrowTheirsThrowEmptyTerminal_PliesTerminal_FlatsTerminal_ReservesTerminal_OpponentReservesMaxFeature"
+const _Feature_name = "TempoTopFlatStandingCapstoneHardTopCapCapMobilityFlatCaptives_SoftFlatCaptives_HardStandingCaptives_SoftStandingCaptives_HardCapstoneCaptives_SoftCapstoneCaptives_HardLibertiesGroupLibertiesGroupsGroups_1Groups_2Groups_3Groups_4Groups_5Groups_6Groups_7Groups_8Groups_9Groups_10PotentialThreatEmptyControlFlatControlCenterCenterControlThrowMineThrowTheirsThrowEmptyTerminal_PliesTerminal_FlatsTerminal_ReservesTerminal_OpponentReservesMaxFeature"
 
-var _Feature_index = [...]uint16{0, 5, 12, 20, 28, 38, 49, 66, 83, 104, 125, 146, 167, 176, 190, 196, 204, 212, 220, 228, 236, 244, 252, 260, 269, 275, 287, 298, 304, 317, 326, 337, 347, 361, 375, 392, 417, 427}
+var _Feature_index = [...]uint16{0, 5, 12, 20, 28, 38, 49, 66, 83, 104, 125, 146, 167, 176, 190, 196, 204, 212, 220, 228, 236, 244, 252, 260, 268, 277, 286, 292, 304, 315, 321, 334, 343, 354, 364, 378, 392, 409, 434, 444}
 
 func (i Feature) String() string {
 	if i < 0 || i >= Feature(len(_Feature_index)-1) {
@@ -65,57 +65,46 @@ type PlaceWins struct {
 	uniform UniformRandom
 }
 
-func findPlaceWins(mask uint64, empty uint64, gs []uint64, c *bitboard.Constants) uint64 {
-	l := mask & c.L
-	r := mask & c.R
-	b := mask & c.B
-	t := mask & c.T
+func findPlaceWins(mask bitboard.Bits, empty bitboard.Bits, gs []bitboard.Bits, c *bitboard.Constants) bitboard.Bits {
+	l := mask.And(c.L)
+	r := mask.And(c.R)
+	b := mask.And(c.B)
+	t := mask.And(c.T)
 	for _, g := range gs {
-		if g&c.L != 0 {
-			l |= g
+		if g.Intersects(c.L) {
+			l = l.Or(g)
 		}
-		if g&c.R != 0 {
-			r |= g
+		if g.Intersects(c.R) {
+			r = r.Or(g)
 		}
-		if g&c.B != 0 {
-			b |= g
+		if g.Intersects(c.B) {
+			b = b.Or(g)
 		}
-		if g&c.T != 0 {
-			t |= g
+		if g.Intersects(c.T) {
+			t = t.Or(g)
 		}
 	}
-	lf := bitboard.Grow(c, empty, l) | c.L&empty
-	rf := bitboard.Grow(c, empty, r) | c.R&empty
-	bf := bitboard.Grow(c, empty, b) | c.B&empty
-	tf := bitboard.Grow(c, empty, t) | c.T&empty
-	/*
-		log.Printf("l =%64s", strconv.FormatUint(l, 2))
-		log.Printf("lf=%64s", strconv.FormatUint(lf, 2))
-		log.Printf("r =%64s", strconv.FormatUint(r, 2))
-		log.Printf("rf=%64s", strconv.FormatUint(rf, 2))
-		log.Printf("t =%64s", strconv.FormatUint(t, 2))
-		log.Printf("tf=%64s", strconv.FormatUint(tf, 2))
-		log.Printf("b =%64s", strconv.FormatUint(b, 2))
-		log.Printf("bf=%64s", strconv.FormatUint(bf, 2))
-	*/
-	return (lf & rf) | (tf & bf)
+	lf := bitboard.Grow(c, empty, l).Or(c.L.And(empty))
+	rf := bitboard.Grow(c, empty, r).Or(c.R.And(empty))
+	bf := bitboard.Grow(c, empty, b).Or(c.B.And(empty))
+	tf := bitboard.Grow(c, empty, t).Or(c.T.And(empty))
+	return lf.And(rf).Or(tf.And(bf))
 }
 
 func placeWinMove(c *bitboard.Constants, p *tak.Position) tak.Move {
-	var myroad uint64
-	var gs []uint64
+	var myroad bitboard.Bits
+	var gs []bitboard.Bits
 	if p.ToMove() == tak.White {
-		myroad = p.White &^ p.Standing
+		myroad = p.White.AndNot(p.Standing)
 		gs = p.Analysis().WhiteGroups
 	} else {
-		myroad = p.Black &^ p.Standing
+		myroad = p.Black.AndNot(p.Standing)
 		gs = p.Analysis().BlackGroups
 	}
-	empty := c.Mask &^ (p.White | p.Black)
+	empty := c.Mask.AndNot(p.White.Or(p.Black))
 	mask := findPlaceWins(myroad, empty, gs, c)
-	if mask != 0 {
-		bit := mask ^ (mask & (mask - 1))
-		x, y := bitboard.BitCoords(c, bit)
+	if !mask.IsZero() {
+		x, y := bitboard.BitCoords(c, mask.Lowest())
 		return tak.Move{X: int8(x), Y: int8(y), Type: tak.PlaceFlat}
 	}
 	return tak.Move{}
@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"os"
 	"reflect"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/nelhage/taktician/ptn"
 	"github.com/nelhage/taktician/symmetry"
 	"github.com/nelhage/taktician/tak"
+	"github.com/nelhage/taktician/timemanager"
 )
 
 const (
@@ -27,11 +29,15 @@ const (
 
 	ForcedWin = 1 << 20
 
-	defaultTableMem = 100 * (1 << 20)
+	// DefaultTableMem is the transposition table size used when
+	// MinimaxConfig.TableMem is zero.
+	DefaultTableMem = 100 * (1 << 20)
 
 	maxDedup = 4
 
-	maxDepth   = 15
+	// MaxDepth is the deepest search MinimaxAI supports; larger
+	// values of MinimaxConfig.Depth are clamped to it.
+	MaxDepth   = 15
 	allocMoves = 500
 
 	multiCutSearch    = 6
@@ -52,20 +58,49 @@ type MinimaxAI struct {
 
 	evaluate EvaluationFunc
 
-	table []tableEntry
+	table []ttSlot
+	// game describes the games whose positions are in table, and
+	// gameKey is its key(), which we fold into Experience keys.
+	game    tableGame
+	gameKey uint64
+
 	depth int
-	stack [maxDepth]frame
+	stack [MaxDepth]frame
 
 	cancel *int32
 
 	cuts *json.Encoder
+
+	helpers []*MinimaxAI
+
+	lines []Line
+
+	// avoid lists root moves which Experience says have lost
+	// games, and which we therefore don't search.
+	avoid []tak.Move
+
+	// tracker applies the time budget, if any, of the current
+	// search.
+	tracker *timemanager.Tracker
+}
+
+// A Line is one of the root moves considered in a MultiPV search,
+// along with its principal variation and value.
+type Line struct {
+	PV    []tak.Move
+	Value int64
 }
 
 type frame struct {
 	p  *tak.Position
 	mg moveGenerator
-	pv [maxDepth]tak.Move
+	pv [MaxDepth]tak.Move
 	m  tak.Move
+	te tableEntry
+
+	// killers are the last two moves to cause a cutoff at this
+	// ply, most recent first.
+	killers [2]tak.Move
 
 	moves struct {
 		slice []tak.Move
@@ -77,22 +112,6 @@ type frame struct {
 	}
 }
 
-type tableEntry struct {
-	hash  uint64
-	value int64
-	m     tak.Move
-	bound boundType
-	depth int8
-}
-
-type boundType byte
-
-const (
-	lowerBound = iota
-	exactBound = iota
-	upperBound = iota
-)
-
 type Stats struct {
 	Depth    int
 	Canceled bool
@@ -172,8 +191,39 @@ type MinimaxConfig struct {
 
 	Evaluate EvaluationFunc
 
+	// Weights, if non-nil, are the weights of the default
+	// evaluation function, used if Evaluate is nil. Saved
+	// transposition tables record them, so that a table is only
+	// reloaded by an AI that evaluates positions the same way.
+	Weights *Weights
+
 	DedupSymmetry bool
 	CutLog        string
+
+	// Threads is the number of threads to search with. Additional
+	// threads run helper searches of the same position which share
+	// the transposition table with the main search ("Lazy SMP").
+	// Values less than 2 search on a single thread.
+	Threads int
+
+	// OnIteration, if non-nil, is called by Analyze after each
+	// completed iteration of iterative deepening, with the
+	// principal variation and value found so far and the Stats
+	// accumulated over the search (st.Depth is the depth just
+	// completed). It is called on the searching goroutine, and
+	// `pv` is only valid for the duration of the call.
+	OnIteration func(pv []tak.Move, v int64, st Stats)
+
+	// Experience, if non-nil, supplies results from previous
+	// games for positions missing from the transposition table,
+	// and moves to avoid at the root.
+	Experience Experience
+
+	// MultiPV is the number of root moves for which Analyze
+	// finds exact values and principal variations on each
+	// iteration. The results are available from Lines. Values
+	// less than 2 search only the best move.
+	MultiPV int
 }
 
 // MakePrecise modifies a MinimaxConfig to produce a MinimaxAI that
@@ -193,8 +243,8 @@ func (cfg *MinimaxConfig) MakePrecise() {
 
 func NewMinimax(cfg MinimaxConfig) *MinimaxAI {
 	m := &MinimaxAI{Cfg: cfg}
-	if m.Cfg.Depth == 0 {
-		m.Cfg.Depth = maxDepth
+	if m.Cfg.Depth == 0 || m.Cfg.Depth > MaxDepth {
+		m.Cfg.Depth = MaxDepth
 	}
 	if m.Cfg.RandomizeScale == 0 {
 		m.Cfg.RandomizeScale = 1
@@ -202,16 +252,17 @@ func NewMinimax(cfg MinimaxConfig) *MinimaxAI {
 	m.precompute()
 	m.evaluate = cfg.Evaluate
 	if m.evaluate == nil {
-		m.evaluate = MakeEvaluator(cfg.Size, nil)
+		m.evaluate = MakeEvaluator(cfg.Size, cfg.Weights)
 	}
+	m.game = gameOf(tak.Config{Size: cfg.Size})
 	m.history = make(map[tak.Move]int, m.Cfg.Size*m.Cfg.Size*m.Cfg.Size)
 	m.response = make(map[tak.Move]tak.Move, m.Cfg.Size*m.Cfg.Size*m.Cfg.Size)
 	if m.Cfg.TableMem >= 0 {
 		mem := m.Cfg.TableMem
 		if mem == 0 {
-			mem = defaultTableMem
+			mem = DefaultTableMem
 		}
-		m.table = make([]tableEntry, mem/int64(reflect.TypeOf(tableEntry{}).Size()))
+		m.table = make([]ttSlot, mem/int64(reflect.TypeOf(ttSlot{}).Size()))
 	}
 
 	for i := range m.stack {
@@ -227,41 +278,71 @@ func NewMinimax(cfg MinimaxConfig) *MinimaxAI {
 		m.cuts = json.NewEncoder(f)
 		m.cuts.SetEscapeHTML(false)
 	}
+	if m.table != nil {
+		for i := 1; i < m.Cfg.Threads; i++ {
+			m.helpers = append(m.helpers, m.newHelper())
+		}
+	}
 	return m
 }
 
-const hashMul = 0x61C8864680B583EB
-
-func (m *MinimaxAI) ttGet(h uint64) *tableEntry {
-	if m.table == nil {
-		return nil
-	}
-	i1 := h % uint64(len(m.table))
-	i2 := (h * hashMul) % uint64(len(m.table))
-	te := &m.table[i1]
-	if te.hash == h {
-		return te
-	}
-	te = &m.table[i2]
-	if te.hash == h {
-		return te
-	}
-	return nil
+// newHelper returns a MinimaxAI that shares m's configuration,
+// evaluation function and transposition table, but otherwise has
+// its own search state, for use as a Lazy SMP helper thread.
+func (m *MinimaxAI) newHelper() *MinimaxAI {
+	h := &MinimaxAI{
+		Cfg:      m.Cfg,
+		c:        m.c,
+		evaluate: m.evaluate,
+		table:    m.table,
+	}
+	h.Cfg.Debug = 0
+	h.Cfg.CutLog = ""
+	h.Cfg.OnIteration = nil
+	h.Cfg.MultiPV = 0
+	h.history = make(map[tak.Move]int, len(m.history))
+	h.response = make(map[tak.Move]tak.Move, len(m.response))
+	for i := range h.stack {
+		h.stack[i].p = tak.Alloc(m.Cfg.Size)
+	}
+	return h
 }
 
-func (m *MinimaxAI) ttPut(h uint64) *tableEntry {
-	if m.table == nil {
-		return nil
-	}
-	if atomic.LoadInt32(m.cancel) != 0 {
-		return nil
-	}
-	i1 := h % uint64(len(m.table))
-	i2 := (h * hashMul) % uint64(len(m.table))
-	if m.table[i1].hash != 0 {
-		m.table[i2] = m.table[i1]
+// startHelpers starts every helper searching `p` in the background,
+// alternating between `depth` and `depth+1` so that the helpers
+// populate the table with entries the main search will need. The
+// returned function stops the helpers, waits for them to exit, and
+// returns their combined Stats.
+func (m *MinimaxAI) startHelpers(p *tak.Position, depth int, pv []tak.Move) func() Stats {
+	if len(m.helpers) == 0 {
+		return func() Stats { return Stats{} }
+	}
+	var stop int32
+	var wg sync.WaitGroup
+	for i, h := range m.helpers {
+		d := depth + i%2
+		if d > m.Cfg.Depth {
+			d = m.Cfg.Depth
+		}
+		h.cancel = &stop
+		h.gameKey = m.gameKey
+		h.st = Stats{}
+		h.depth = d
+		wg.Add(1)
+		go func(h *MinimaxAI, d int) {
+			defer wg.Done()
+			h.pvSearch(p, 0, d, pv, MinEval-1, MaxEval+1)
+		}(h, d)
+	}
+	return func() Stats {
+		atomic.StoreInt32(&stop, 1)
+		wg.Wait()
+		var st Stats
+		for _, h := range m.helpers {
+			st = st.Merge(h.st)
+		}
+		return st
 	}
-	return &m.table[i1]
 }
 
 func (m *MinimaxAI) precompute() {
@@ -307,6 +388,9 @@ func (ai *MinimaxAI) GetMove(ctx context.Context, p *tak.Position) tak.Move {
 	}
 
 	for m, child := mg.Next(); child != nil; m, child = mg.Next() {
+		if ai.avoided(m) {
+			continue
+		}
 		ai.stack[0].m = m
 		_, cv := ai.pvSearch(child, 1, st.Depth-1, pv[1:],
 			-v-1, -base)
@@ -361,7 +445,7 @@ func (ai *MinimaxAI) AnalyzeAll(ctx context.Context, p *tak.Position) ([][]tak.M
 		if cv != v {
 			continue
 		}
-		if m.Equal(pv[0]) {
+		if m.Equal(pv[0]) || ai.avoided(m) {
 			continue
 		}
 		outpv := []tak.Move{m}
@@ -370,13 +454,93 @@ func (ai *MinimaxAI) AnalyzeAll(ctx context.Context, p *tak.Position) ([][]tak.M
 	return out, v, st
 }
 
+// AnalyzeLines analyzes `p` like Analyze, and returns up to
+// Cfg.MultiPV of the best root moves, best first.
+func (m *MinimaxAI) AnalyzeLines(ctx context.Context, p *tak.Position) ([]Line, Stats) {
+	_, _, st := m.Analyze(ctx, p)
+	return m.Lines(), st
+}
+
+// Lines returns the lines found by the most recently completed
+// iteration of Analyze, best first. The first line is always the
+// principal variation. If Cfg.MultiPV is at least 2, it may be
+// called from OnIteration to retrieve every line for that iteration.
+func (m *MinimaxAI) Lines() []Line {
+	return m.lines
+}
+
+// searchLines searches every root move other than pv[0] to `depth`,
+// keeping the Cfg.MultiPV best lines. Each move is searched with a
+// window that excludes values no better than the worst line kept so
+// far, so only moves that enter the list get exact values. It
+// returns false if the search is cancelled before it finishes.
+func (m *MinimaxAI) searchLines(p *tak.Position, depth int, pv []tak.Move, v int64) ([]Line, bool) {
+	lines := []Line{{PV: append([]tak.Move(nil), pv...), Value: v}}
+	n := m.Cfg.MultiPV
+	mg := &m.stack[0].mg
+	*mg = moveGenerator{
+		ai:    m,
+		f:     &m.stack[0],
+		ply:   0,
+		depth: depth,
+		p:     p,
+		pv:    pv,
+	}
+	for mv, child := mg.Next(); child != nil; mv, child = mg.Next() {
+		if mv.Equal(pv[0]) || m.avoided(mv) {
+			continue
+		}
+		α := MinEval - 1
+		if len(lines) == n {
+			α = lines[n-1].Value
+		}
+		m.stack[0].m = mv
+		ms, cv := m.pvSearch(child, 1, depth-1, nil, -MaxEval-1, -α)
+		if atomic.LoadInt32(m.cancel) != 0 {
+			return nil, false
+		}
+		cv = -cv
+		if cv <= α {
+			continue
+		}
+		line := Line{PV: append([]tak.Move{mv}, ms...), Value: cv}
+		i := len(lines)
+		for i > 1 && lines[i-1].Value < cv {
+			i--
+		}
+		if len(lines) < n {
+			lines = append(lines, Line{})
+		}
+		copy(lines[i+1:], lines[i:])
+		lines[i] = line
+	}
+	if m.Cfg.Debug > 1 {
+		for i, l := range lines {
+			log.Printf("[multipv] %d: v=%d pv=%s", i+1, l.Value, formatpv(l.PV))
+		}
+	}
+	return lines, true
+}
+
 func (m *MinimaxAI) Analyze(ctx context.Context, p *tak.Position) ([]tak.Move, int64, Stats) {
 	if m.Cfg.Size != p.Size() {
 		panic("Analyze: wrong size")
 	}
+	if g := gameOf(p.Config()); g != m.game {
+		// Hashes don't cover komi or reserves, so entries
+		// from other games would be wrong here.
+		m.clearTable()
+		m.game = g
+	}
+	m.gameKey = m.game.key()
 	for i, v := range m.history {
 		m.history[i] = v / 2
 	}
+	for _, h := range m.helpers {
+		for i, v := range h.history {
+			h.history[i] = v / 2
+		}
+	}
 	var cancel int32
 	m.cancel = &cancel
 	if ctx.Done() != nil {
@@ -403,18 +567,23 @@ func (m *MinimaxAI) Analyze(ctx context.Context, p *tak.Position) ([]tak.Move, i
 	}
 
 	var next []tak.Move
-	ms := make([]tak.Move, 0, maxDepth)
+	ms := make([]tak.Move, 0, MaxDepth)
 	var v, nv int64
 	top := time.Now()
+	m.tracker = timemanager.TrackerFrom(ctx, top)
+	limited = limited || m.tracker != nil
 	var prevEval uint64
 	var branchSum uint64
 	var branchEstimate uint64
 
+	m.lines = nil
+	m.avoid = m.rootAvoid(p)
 	base := 0
-	te := m.ttGet(p.Hash())
-	if te != nil && te.bound == exactBound {
+	te := m.ttGet(p.Hash(), &m.stack[0].te)
+	if te != nil && te.bound == exactBound && len(m.avoid) == 0 {
 		base = int(te.depth)
-		ms = append(ms[:0], te.m)
+		v = te.value
+		ms = m.ttPV(p, base, ms[:0])
 	}
 
 	var st Stats
@@ -423,15 +592,40 @@ func (m *MinimaxAI) Analyze(ctx context.Context, p *tak.Position) ([]tak.Move, i
 		m.st = Stats{Depth: i + base}
 		start := time.Now()
 		m.depth = i + base
+		wait := m.startHelpers(p, i+base, ms)
 		next, nv = m.pvSearch(p, 0, i+base, ms, MinEval-1, MaxEval+1)
+		m.st = m.st.Merge(wait())
 		if next == nil || atomic.LoadInt32(m.cancel) != 0 {
 			st.Canceled = true
 			break
 		}
+		if m.Cfg.MultiPV > 1 {
+			// The iteration is not complete until it has
+			// searched every line; if it is cancelled
+			// first, we keep the last complete one, unless
+			// there is none.
+			lines, ok := m.searchLines(p, i+base, next, nv)
+			if !ok {
+				st.Canceled = true
+				if len(ms) == 0 {
+					v = nv
+					ms = append(ms[:0], next...)
+				}
+				break
+			}
+			m.lines = lines
+		}
 		v = nv
-		st = m.st.Merge(st)
 		ms = append(ms[:0], next...)
+		if m.tracker != nil {
+			m.tracker.Iteration(ms[0])
+		}
+		st = m.st.Merge(st)
 		timeUsed := time.Since(top)
+		if m.Cfg.OnIteration != nil {
+			st.Elapsed = timeUsed
+			m.Cfg.OnIteration(ms, v, st)
+		}
 		timeMove := time.Since(start)
 		if m.Cfg.Debug > 0 {
 			log.Printf("[minimax] deepen: depth=%d val=%d pv=%s time=%s total=%s evaluated=%d tt=%d/%d branch=%d(%d)",
@@ -485,6 +679,16 @@ func (m *MinimaxAI) Analyze(ctx context.Context, p *tak.Position) ([]tak.Move, i
 				// returns a deep move
 				branchEstimate = 5
 			}
+			if m.tracker != nil {
+				estimate := time.Since(start) * time.Duration(branchEstimate)
+				if !m.tracker.Continue(time.Now(), estimate) {
+					if m.Cfg.Debug > 0 {
+						log.Printf("[minimax] budget cutoff: depth=%d used=%s soft=%s estimate=%s",
+							base+i, timeUsed, m.tracker.Soft(), estimate)
+					}
+					break
+				}
+			}
 			if !deadline.IsZero() {
 				estimate := time.Now().Add(time.Since(start) * time.Duration(branchEstimate))
 				if estimate.After(deadline) {
@@ -507,10 +711,73 @@ func (m *MinimaxAI) Analyze(ctx context.Context, p *tak.Position) ([]tak.Move, i
 			}
 		}
 	}
+	if (m.lines == nil || m.Cfg.MultiPV < 2) && len(ms) > 0 {
+		m.lines = []Line{{PV: append([]tak.Move(nil), ms...), Value: v}}
+	}
 	st.Elapsed = time.Since(top)
 	return ms, v, st
 }
 
+// rootAvoid returns the moves Experience tells us to avoid in `p`,
+// unless that would leave us no legal moves.
+func (m *MinimaxAI) rootAvoid(p *tak.Position) []tak.Move {
+	if m.Cfg.Experience == nil {
+		return nil
+	}
+	avoid := m.Cfg.Experience.Avoid(p.Hash() ^ m.gameKey)
+	if len(avoid) == 0 {
+		return nil
+	}
+	for _, mv := range p.AllMoves(nil) {
+		if _, err := p.Move(mv); err != nil {
+			continue
+		}
+		if !containsMove(avoid, mv) {
+			if m.Cfg.Debug > 0 {
+				log.Printf("[minimax] experience: avoiding %s", formatpv(avoid))
+			}
+			return avoid
+		}
+	}
+	return nil
+}
+
+func (m *MinimaxAI) avoided(mv tak.Move) bool {
+	return containsMove(m.avoid, mv)
+}
+
+func containsMove(ms []tak.Move, m tak.Move) bool {
+	for _, o := range ms {
+		if o.Equal(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// ttPV reconstructs a principal variation of at most `depth` moves
+// from `p` by following exact entries in the table, and appends it
+// to `out`.
+func (m *MinimaxAI) ttPV(p *tak.Position, depth int, out []tak.Move) []tak.Move {
+	var e tableEntry
+	for len(out) < depth {
+		if over, _ := p.GameOver(); over {
+			break
+		}
+		te := m.ttGet(p.Hash(), &e)
+		if te == nil || te.bound != exactBound {
+			break
+		}
+		next, err := p.Move(te.m)
+		if err != nil {
+			break
+		}
+		out = append(out, te.m)
+		p = next
+	}
+	return out
+}
+
 func (m *MinimaxAI) Evaluate(p *tak.Position) int64 {
 	return m.evaluate(&m.c, p)
 }
@@ -548,6 +815,9 @@ func (ai *MinimaxAI) recordCut(p *tak.Position, m tak.Move, move, depth, ply int
 	if ply > 0 {
 		ai.response[ai.stack[ply-1].m] = m
 	}
+	if k := &ai.stack[ply].killers; !k[0].Equal(m) {
+		k[0], k[1] = m, k[0]
+	}
 	if ai.cuts == nil {
 		return
 	}
@@ -617,7 +887,10 @@ func (ai *MinimaxAI) pvSearch(
 		dedupCache = make(map[uint64]struct{})
 	}
 
-	te := ai.ttGet(p.Hash())
+	te := ai.ttGet(p.Hash(), &ai.stack[ply].te)
+	if te != nil && ply == 0 && ai.avoided(te.m) {
+		te = nil
+	}
 	if te != nil {
 		ai.st.TTHits++
 		if teSuffices(te, depth, α, β) {
@@ -653,6 +926,9 @@ func (ai *MinimaxAI) pvSearch(
 	improved := false
 	var i int
 	for m, child := mg.Next(); child != nil; m, child = mg.Next() {
+		if ply == 0 && ai.avoided(m) {
+			continue
+		}
 		if dedup {
 			_, seen := dedupCache[child.Hash()]
 			if seen {
@@ -701,26 +977,32 @@ func (ai *MinimaxAI) pvSearch(
 				break
 			}
 		}
+		if ply == 0 && ai.tracker != nil && ai.tracker.StopRoot(time.Now(), best[0]) {
+			// We're out of time, and this iteration
+			// agrees with the last.
+			atomic.StoreInt32(ai.cancel, 1)
+		}
 		if atomic.LoadInt32(ai.cancel) != 0 {
 			return nil, 0
 		}
 	}
 
-	hash := p.Hash()
-	if te = ai.ttPut(hash); te != nil && (te.hash != hash || int(te.depth) <= depth) {
-		te.hash = hash
-		te.depth = int8(depth)
-		te.m = best[0]
-		te.value = α
-		if !improved {
-			te.bound = upperBound
-			ai.st.AllNodes++
-		} else if α >= β {
-			te.bound = lowerBound
-		} else {
-			te.bound = exactBound
-		}
+	var bound boundType
+	if !improved {
+		bound = upperBound
+		ai.st.AllNodes++
+	} else if α >= β {
+		bound = lowerBound
+	} else {
+		bound = exactBound
 	}
+	ai.ttPut(&tableEntry{
+		hash:  p.Hash(),
+		depth: int8(depth),
+		m:     best[0],
+		value: α,
+		bound: bound,
+	}, true)
 
 	return best, α
 }
@@ -742,7 +1024,7 @@ func (ai *MinimaxAI) zwSearch(
 	ai.st.Visited++
 	ai.st.Scout++
 
-	te := ai.ttGet(p.Hash())
+	te := ai.ttGet(p.Hash(), &ai.stack[ply].te)
 	if te != nil {
 		ai.st.TTHits++
 		if teSuffices(te, depth, α, α+1) {
@@ -854,18 +1136,18 @@ func (ai *MinimaxAI) zwSearch(
 		}
 	}
 
-	if te = ai.ttPut(p.Hash()); te != nil {
-		te.hash = p.Hash()
-		te.depth = int8(depth)
-		te.m = best[0]
-		te.value = α
-		if didCut {
-			te.bound = lowerBound
-		} else {
-			te.bound = upperBound
-			ai.st.AllNodes++
-		}
+	var bound boundType = lowerBound
+	if !didCut {
+		bound = upperBound
+		ai.st.AllNodes++
 	}
+	ai.ttPut(&tableEntry{
+		hash:  p.Hash(),
+		depth: int8(depth),
+		m:     best[0],
+		value: α,
+		bound: bound,
+	}, false)
 
 	if didCut {
 		return best, α + 1
@@ -886,7 +1168,7 @@ func (ai *MinimaxAI) nullMoveOK(ply, depth int, p *tak.Position) bool {
 	if p.WhiteStones() < 3 || p.BlackStones() < 3 {
 		return false
 	}
-	if bitboard.Popcount(p.White|p.Black)+3 >= len(p.Stacks) {
+	if p.White.Or(p.Black).Popcount()+3 >= len(p.Stacks) {
 		return false
 	}
 	return true
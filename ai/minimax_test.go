@@ -2,6 +2,8 @@ package ai
 
 import (
 	"flag"
+	"sort"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -9,6 +11,7 @@ import (
 
 	"github.com/nelhage/taktician/ptn"
 	"github.com/nelhage/taktician/tak"
+	"github.com/nelhage/taktician/timemanager"
 )
 
 var size = flag.Int("size", 5, "board size to benchmark")
@@ -63,9 +66,27 @@ func TestRegression(t *testing.T) {
 	}
 }
 
+func TestLargeBoard(t *testing.T) {
+	game, err := ptn.ParseTPS(
+		`x10/x10/1,1,1,1,1,1,1,1,1,x/x10/x10/2,2,2,2,2,2,2,2,x2/x10/x10/x10/x10 1 10`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ai := NewMinimax(MinimaxConfig{Size: game.Size(), Depth: 3})
+	m := ai.GetMove(context.Background(), game)
+	next, e := game.Move(m)
+	if e != nil {
+		t.Fatalf("ai returned illegal move: %s: %s", ptn.FormatMove(m), e)
+	}
+	if over, winner := next.GameOver(); !over || winner != tak.White {
+		t.Fatalf("did not find the road: %s", ptn.FormatMove(m))
+	}
+}
+
 func TestCancel(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
-	ai := NewMinimax(MinimaxConfig{Size: 5, Depth: maxDepth})
+	ai := NewMinimax(MinimaxConfig{Size: 5, Depth: MaxDepth})
 	p := tak.New(tak.Config{Size: 5})
 	done := make(chan Stats)
 	go func() {
@@ -74,7 +95,7 @@ func TestCancel(t *testing.T) {
 	}()
 	cancel()
 	st := <-done
-	if st.Depth == maxDepth {
+	if st.Depth == MaxDepth {
 		t.Fatal("wtf too deep")
 	}
 	if !st.Canceled {
@@ -121,3 +142,236 @@ func TestRepeatedCancel(t *testing.T) {
 		t.Fatal("did not do full search")
 	}
 }
+
+func TestThreads(t *testing.T) {
+	game, err := ptn.ParseTPS(
+		`2,x4/x2,2,x2/x,2,2,x2/x2,12,2,1/1,1,21,2,1 1 9`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ai := NewMinimax(MinimaxConfig{Size: game.Size(), Depth: 4, Threads: 4})
+	if len(ai.helpers) != 3 {
+		t.Fatalf("expected 3 helpers, got %d", len(ai.helpers))
+	}
+	for i := 0; i < 2; i++ {
+		pv, _, st := ai.Analyze(context.Background(), game)
+		if len(pv) == 0 {
+			t.Fatal("did not return a move")
+		}
+		if _, e := game.Move(pv[0]); e != nil {
+			t.Fatalf("ai returned illegal move: %s: %s", ptn.FormatMove(pv[0]), e)
+		}
+		if st.Depth != 4 {
+			t.Fatalf("searched to depth %d, want 4", st.Depth)
+		}
+	}
+}
+
+func TestSingleThreadDeterministic(t *testing.T) {
+	game, err := ptn.ParseTPS(
+		`2,x4/x2,2,x2/x,2,2,x2/x2,12,2,1/1,1,21,2,1 1 9`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analyze := func() ([]tak.Move, int64, Stats) {
+		ai := NewMinimax(MinimaxConfig{Size: game.Size(), Depth: 4, Threads: 1})
+		return ai.Analyze(context.Background(), game)
+	}
+	pv1, v1, st1 := analyze()
+	pv2, v2, st2 := analyze()
+	if v1 != v2 || st1.Visited != st2.Visited || len(pv1) != len(pv2) {
+		t.Fatalf("nondeterministic search: %v/%d/%d vs %v/%d/%d",
+			pv1, v1, st1.Visited, pv2, v2, st2.Visited)
+	}
+	for i := range pv1 {
+		if !pv1[i].Equal(pv2[i]) {
+			t.Fatalf("pv differs at %d: %s vs %s",
+				i, ptn.FormatMove(pv1[i]), ptn.FormatMove(pv2[i]))
+		}
+	}
+}
+
+func TestMultiPV(t *testing.T) {
+	game, err := ptn.ParseTPS(
+		`2,x4/x2,2,x2/x,2,2,x2/x2,12,2,1/1,1,21,2,1 1 9`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const depth, lines = 3, 4
+	cfg := MinimaxConfig{Size: game.Size(), Depth: depth, MultiPV: lines}
+	cfg.MakePrecise()
+	ai := NewMinimax(cfg)
+	var iterations int
+	ai.Cfg.OnIteration = func(pv []tak.Move, v int64, st Stats) {
+		iterations++
+		if got := ai.Lines(); len(got) != lines || !got[0].PV[0].Equal(pv[0]) {
+			t.Errorf("depth=%d: bad lines: %v", st.Depth, got)
+		}
+	}
+	ls, st := ai.AnalyzeLines(context.Background(), game)
+	if st.Depth != depth || iterations != depth {
+		t.Fatalf("depth=%d iterations=%d", st.Depth, iterations)
+	}
+	if len(ls) != lines {
+		t.Fatalf("got %d lines, want %d", len(ls), lines)
+	}
+
+	// Compute the value of every root move independently, and
+	// check that we found the best ones.
+	var values []int64
+	for _, m := range game.AllMoves(nil) {
+		child, e := game.Move(m)
+		if e != nil {
+			continue
+		}
+		ccfg := cfg
+		ccfg.Depth = depth - 1
+		ccfg.MultiPV = 0
+		ccfg.TableMem = -1
+		_, v, _ := NewMinimax(ccfg).Analyze(context.Background(), child)
+		values = append(values, -v)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] > values[j] })
+	for i, l := range ls {
+		if l.Value != values[i] {
+			t.Errorf("line %d: %s value=%d, want %d",
+				i, ptn.FormatMove(l.PV[0]), l.Value, values[i])
+		}
+		for j := 0; j < i; j++ {
+			if ls[j].PV[0].Equal(l.PV[0]) {
+				t.Errorf("line %d repeats %s", i, ptn.FormatMove(l.PV[0]))
+			}
+		}
+	}
+
+	// Lines cut short by cancellation are not reported.
+	canceled := int32(1)
+	ai.cancel = &canceled
+	if _, ok := ai.searchLines(game, depth, ls[0].PV, ls[0].Value); ok {
+		t.Errorf("cancelled searchLines reported complete lines")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	game, err := ptn.ParseTPS(
+		`2,x4/x2,2,x2/x,2,2,x2/x2,12,2,1/1,1,21,2,1 1 9`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ai := NewMinimax(MinimaxConfig{Size: game.Size(), TableMem: -1})
+	ctx, cancel := timemanager.WithBudget(context.Background(),
+		timemanager.Budget{Soft: 50 * time.Millisecond, Hard: time.Minute})
+	defer cancel()
+	start := time.Now()
+	pv, _, st := ai.Analyze(ctx, game)
+	if len(pv) == 0 {
+		t.Fatal("did not return a move")
+	}
+	// The search may overrun the soft limit, but should stop long
+	// before the hard one.
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("ignored the soft limit: depth=%d elapsed=%s", st.Depth, elapsed)
+	}
+}
+
+func TestPonderBudget(t *testing.T) {
+	game, err := ptn.ParseTPS(
+		`2,x4/x2,2,x2/x,2,2,x2/x2,12,2,1/1,1,21,2,1 1 9`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ai := NewMinimax(MinimaxConfig{Size: game.Size(), TableMem: -1})
+	ctx, start := timemanager.WithPonder(context.Background(),
+		timemanager.Budget{Soft: 50 * time.Millisecond, Hard: time.Minute})
+	done := make(chan []tak.Move)
+	go func() {
+		pv, _, _ := ai.Analyze(ctx, game)
+		done <- pv
+	}()
+	// The clock does not run while we ponder.
+	select {
+	case <-done:
+		t.Fatal("stopped before the clock started")
+	case <-time.After(200 * time.Millisecond):
+	}
+	start()
+	select {
+	case pv := <-done:
+		if len(pv) == 0 {
+			t.Fatal("did not return a move")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("ignored the soft limit after the clock started")
+	}
+}
+
+// mapExperience knows the results in `known`, and counts the
+// lookups it gets, and how many of them it answered.
+type mapExperience struct {
+	known         map[uint64]tableEntry
+	lookups, hits int64
+}
+
+func (e *mapExperience) Lookup(h uint64) (tak.Move, int64, int, bool) {
+	atomic.AddInt64(&e.lookups, 1)
+	te, ok := e.known[h]
+	if ok {
+		atomic.AddInt64(&e.hits, 1)
+	}
+	return te.m, te.value, int(te.depth), ok
+}
+
+func (e *mapExperience) Avoid(h uint64) []tak.Move {
+	return nil
+}
+
+func TestExperienceInterior(t *testing.T) {
+	game, err := ptn.ParseTPS(
+		`2,x4/x2,2,x2/x,2,2,x2/x2,12,2,1/1,1,21,2,1 1 9`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := MinimaxConfig{Size: game.Size(), Depth: 3}
+	pv, _, _ := NewMinimax(cfg).Analyze(context.Background(), game)
+	if len(pv) < 3 {
+		t.Fatalf("short pv: %s", formatpv(pv))
+	}
+
+	// Experience says that the position two plies down the
+	// principal variation is lost, so the AI must not play
+	// towards it.
+	lost := game
+	for _, m := range pv[:2] {
+		if lost, err = lost.Move(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exp := &mapExperience{known: map[uint64]tableEntry{
+		lost.Hash(): {m: pv[2], value: -WinBase, depth: 10},
+	}}
+	cfg.Experience = exp
+	got, _, _ := NewMinimax(cfg).Analyze(context.Background(), game)
+	if got[0].Equal(pv[0]) {
+		t.Errorf("played %s into a lost position", ptn.FormatMove(got[0]))
+	}
+	if exp.lookups < 2 {
+		t.Errorf("searched Experience %d times", exp.lookups)
+	}
+
+	// The result doesn't apply to a game with komi.
+	komi, err := ptn.ParseTPSWithConfig(ptn.FormatTPS(game), tak.Config{Komi: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp.hits = 0
+	NewMinimax(cfg).Analyze(context.Background(), komi)
+	if exp.hits != 0 {
+		t.Errorf("used a result from a game without komi %d times", exp.hits)
+	}
+}
@@ -16,9 +16,14 @@ type moveGenerator struct {
 	te *tableEntry
 	pv []tak.Move
 	r  tak.Move
+	k  [2]tak.Move
 
+	// ms holds the placements, sorted by history; once we have
+	// tried them, we generate the slides with `it` as we need
+	// them.
 	ms []tak.Move
 	i  int
+	it tak.MoveIterator
 }
 
 type sortMoves struct {
@@ -57,6 +62,18 @@ func (mg *moveGenerator) Reset() {
 	mg.i = 0
 }
 
+// tried reports whether `m` is one of the moves we try before
+// generating moves with `it`.
+func (mg *moveGenerator) tried(m tak.Move) bool {
+	if mg.te != nil && mg.te.m.Equal(m) {
+		return true
+	}
+	if len(mg.pv) != 0 && mg.pv[0].Equal(m) {
+		return true
+	}
+	return mg.r.Equal(m) || mg.k[0].Equal(m) || mg.k[1].Equal(m)
+}
+
 func (mg *moveGenerator) Next() (m tak.Move, p *tak.Position) {
 	for {
 		var m tak.Move
@@ -80,43 +97,66 @@ func (mg *moveGenerator) Next() (m tak.Move, p *tak.Position) {
 			fallthrough
 		case 2:
 			mg.i++
+			mg.r = tak.Move{}
 			if mg.ply == 0 {
 				continue
 			}
 			var ok bool
 			if mg.r, ok = mg.ai.response[mg.ai.stack[mg.ply-1].m]; ok {
 				m = mg.r
+				if mg.te != nil && m.Equal(mg.te.m) ||
+					len(mg.pv) != 0 && m.Equal(mg.pv[0]) {
+					continue
+				}
 				break
 			}
 			fallthrough
-		case 3:
+		case 3, 4:
+			// The killer moves: the last two moves to cause a
+			// cutoff at this ply.
+			if mg.i == 3 {
+				mg.k = mg.f.killers
+			}
+			n := mg.i - 3
 			mg.i++
-			if mg.ms == nil {
-				ms := mg.f.moves.slice
-				if ms == nil {
-					ms = mg.f.moves.alloc[:]
-				}
-				mg.ms = mg.p.AllMoves(ms[:0])
-				mg.f.moves.slice = ms[:]
+			k := mg.k[n]
+			mg.k[n] = tak.Move{}
+			if k.Type == 0 || mg.tried(k) {
+				continue
 			}
+			mg.k[n] = k
+			m = k
+		case 5:
+			mg.i++
+			mg.it.Reset(mg.p, tak.Move{})
+			ms := mg.f.moves.slice
+			if ms == nil {
+				ms = mg.f.moves.alloc[:]
+			}
+			mg.ms = ms[:0]
 			if mg.depth > 1 && !mg.ai.Cfg.NoSort {
+				// Placements are cheap to generate, so
+				// we sort them by history, but generate
+				// slides only as we need them.
+				for m, ok := mg.it.NextPlace(); ok; m, ok = mg.it.NextPlace() {
+					mg.ms = append(mg.ms, m)
+				}
+				mg.f.moves.slice = mg.ms[:0]
 				mg.sortMoves()
 			}
-			fallthrough
+			continue
 		default:
-			j := mg.i - 4
-			mg.i++
-			if j >= len(mg.ms) {
-				return tak.Move{}, nil
-			}
-			m = mg.ms[j]
-			if mg.te != nil && mg.te.m.Equal(m) {
-				continue
-			}
-			if len(mg.pv) != 0 && mg.pv[0].Equal(m) {
-				continue
+			j := mg.i - 6
+			if j < len(mg.ms) {
+				mg.i++
+				m = mg.ms[j]
+			} else {
+				var ok bool
+				if m, ok = mg.it.Next(); !ok {
+					return tak.Move{}, nil
+				}
 			}
-			if mg.r.Equal(m) {
+			if mg.tried(m) {
 				continue
 			}
 		}
@@ -13,14 +13,16 @@ func TestMoveGenerator(t *testing.T) {
 	p, _ := ptn.ParseTPS("1,1,x3/x,1,x,2,x/x,2,1C,x2/x,2,1,x2/2,2,1,x2 2 6")
 	pvm := taktest.Move("Cc4")
 	tem := taktest.Move("c4")
-	cm := taktest.Move("b1>")
+	km := taktest.Move("b1>")
+	hm := taktest.Move("e5")
 	te := tableEntry{
 		m: tem,
 	}
 
 	ai := NewMinimax(MinimaxConfig{Size: 5})
 	ai.rand = rand.New(rand.NewSource(7))
-	ai.history[cm] = 100
+	ai.stack[1].killers[0] = km
+	ai.history[hm] = 100
 
 	mg := &ai.stack[1].mg
 	*mg = moveGenerator{
@@ -61,9 +63,19 @@ func TestMoveGenerator(t *testing.T) {
 		t.Errorf("move[1]=%s != %s",
 			ptn.FormatMove(g), ptn.FormatMove(pvm))
 	}
-	if g := generated[2]; !g.Equal(cm) {
+	if g := generated[2]; !g.Equal(km) {
 		t.Errorf("move[2]=%s != %s",
-			ptn.FormatMove(g), ptn.FormatMove(cm))
+			ptn.FormatMove(g), ptn.FormatMove(km))
+	}
+	if g := generated[3]; !g.Equal(hm) {
+		t.Errorf("move[3]=%s != %s",
+			ptn.FormatMove(g), ptn.FormatMove(hm))
+	}
+	for i := 4; i < len(generated); i++ {
+		if !generated[i].IsSlide() && generated[i-1].IsSlide() {
+			t.Errorf("move[%d]=%s follows slide %s",
+				i, ptn.FormatMove(generated[i]), ptn.FormatMove(generated[i-1]))
+		}
 	}
 
 	for g := range genS {
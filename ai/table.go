@@ -0,0 +1,139 @@
+package ai
+
+import (
+	"sync/atomic"
+
+	"github.com/nelhage/taktician/tak"
+)
+
+type tableEntry struct {
+	hash  uint64
+	value int64
+	m     tak.Move
+	bound boundType
+	depth int8
+}
+
+type boundType byte
+
+const (
+	lowerBound = iota
+	exactBound = iota
+	upperBound = iota
+)
+
+// ttSlot is the in-memory representation of a tableEntry. The
+// transposition table is shared, without locks, between all of the
+// threads searching on behalf of a MinimaxAI, so we use the
+// "lockless hashing" scheme of Hyatt and Mann: each word is read and
+// written atomically, and `check` stores the position hash XORed with
+// both data words. A slot torn by two concurrent writers will fail to
+// match any position's hash, and so reads as a miss.
+type ttSlot struct {
+	check uint64
+	data  uint64
+	move  uint64
+}
+
+func packEntry(e *tableEntry) (data, move uint64) {
+	data = uint64(uint32(int32(e.value))) |
+		uint64(uint8(e.depth))<<32 |
+		uint64(e.bound)<<40
+	move = uint64(uint8(e.m.X)) |
+		uint64(uint8(e.m.Y))<<8 |
+		uint64(e.m.Type)<<16 |
+		uint64(e.m.Slides)<<24
+	return data, move
+}
+
+func (s *ttSlot) load(out *tableEntry) {
+	check := atomic.LoadUint64(&s.check)
+	data := atomic.LoadUint64(&s.data)
+	move := atomic.LoadUint64(&s.move)
+
+	out.hash = check ^ data ^ move
+	out.value = int64(int32(uint32(data)))
+	out.depth = int8(uint8(data >> 32))
+	out.bound = boundType(data >> 40)
+	out.m = tak.Move{
+		X:      int8(uint8(move)),
+		Y:      int8(uint8(move >> 8)),
+		Type:   tak.MoveType(move >> 16),
+		Slides: tak.Slides(move >> 24),
+	}
+}
+
+func (s *ttSlot) store(e *tableEntry) {
+	data, move := packEntry(e)
+	atomic.StoreUint64(&s.data, data)
+	atomic.StoreUint64(&s.move, move)
+	atomic.StoreUint64(&s.check, e.hash^data^move)
+}
+
+const hashMul = 0x61C8864680B583EB
+
+// ttGet looks up `h` in the table, falling back to Cfg.Experience,
+// and decodes the entry, if any, into `out`. It returns `out` on a
+// hit, and nil otherwise.
+func (m *MinimaxAI) ttGet(h uint64, out *tableEntry) *tableEntry {
+	if m.table != nil {
+		i1 := h % uint64(len(m.table))
+		i2 := (h * hashMul) % uint64(len(m.table))
+		m.table[i1].load(out)
+		if out.hash == h {
+			return out
+		}
+		m.table[i2].load(out)
+		if out.hash == h {
+			return out
+		}
+	}
+	return m.experience(h, out)
+}
+
+// experience looks up `h` in Cfg.Experience, and decodes the result,
+// if any, into `out`. Hits are stored into the table, so we only
+// consult the Experience again once they are evicted. It returns
+// `out` on a hit, and nil otherwise.
+func (m *MinimaxAI) experience(h uint64, out *tableEntry) *tableEntry {
+	if m.Cfg.Experience == nil {
+		return nil
+	}
+	mv, v, depth, ok := m.Cfg.Experience.Lookup(h ^ m.gameKey)
+	if !ok {
+		return nil
+	}
+	*out = tableEntry{
+		hash:  h,
+		value: v,
+		m:     mv,
+		bound: exactBound,
+		depth: int8(depth),
+	}
+	m.ttPut(out, true)
+	return out
+}
+
+// ttPut stores `e` into the table, moving any existing entry in its
+// primary slot into the secondary slot. If `keepDeeper` is set, an
+// existing entry for the same position with a greater depth is
+// preserved.
+func (m *MinimaxAI) ttPut(e *tableEntry, keepDeeper bool) {
+	if m.table == nil {
+		return
+	}
+	if atomic.LoadInt32(m.cancel) != 0 {
+		return
+	}
+	i1 := e.hash % uint64(len(m.table))
+	i2 := (e.hash * hashMul) % uint64(len(m.table))
+	var old tableEntry
+	m.table[i1].load(&old)
+	if old.hash != 0 {
+		m.table[i2].store(&old)
+	}
+	if keepDeeper && old.hash == e.hash && old.depth > e.depth {
+		return
+	}
+	m.table[i1].store(e)
+}
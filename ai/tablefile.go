@@ -0,0 +1,325 @@
+package ai
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+// A saved transposition table consists of a tableHeader followed by
+// every slot of the table, each as three little-endian words.
+type tableHeader struct {
+	Magic         [8]byte
+	Version       uint32
+	Size          uint32
+	Komi          int32
+	Pieces        uint32
+	Capstones     uint32
+	BlackWinsTies bool
+	Fingerprint   uint64
+	EvalDigest    uint64
+	Slots         uint64
+}
+
+var tableMagic = [8]byte{'t', 'a', 'k', 't', 'a', 'b', 'l', 'e'}
+
+const tableVersion = 2
+
+// ErrIncompatibleTable is returned when loading a transposition
+// table that was saved for a different game configuration, by an AI
+// that evaluates positions differently, or by a version of
+// Taktician that hashes positions differently.
+var ErrIncompatibleTable = errors.New("incompatible transposition table")
+
+// tableGame is the part of a tak.Config that determines the values
+// of positions in the transposition table. Position hashes don't
+// cover komi or the size of the reserves, so a table only holds
+// positions from games that agree on all of them.
+type tableGame struct {
+	Size, Komi, Pieces, Capstones int
+	BlackWinsTies                 bool
+}
+
+func gameOf(c tak.Config) tableGame {
+	pieces, caps := c.Reserves()
+	return tableGame{
+		Size:          c.Size,
+		Komi:          c.Komi,
+		Pieces:        pieces,
+		Capstones:     caps,
+		BlackWinsTies: c.BlackWinsTies,
+	}
+}
+
+// key returns a hash of `g`, which is zero for a game with the
+// standard rules for its size.
+func (g tableGame) key() uint64 {
+	if g == gameOf(tak.Config{Size: g.Size}) {
+		return 0
+	}
+	h := fnv.New64a()
+	binary.Write(h, binary.LittleEndian, []int64{
+		int64(g.Komi), int64(g.Pieces), int64(g.Capstones),
+	})
+	binary.Write(h, binary.LittleEndian, g.BlackWinsTies)
+	return h.Sum64()
+}
+
+// evalDigest returns a hash of the settings that determine the
+// values m stores in its table: the evaluation weights, and the
+// heuristics MakePrecise disables. A custom Cfg.Evaluate can't be
+// inspected, so all such AIs share one digest.
+func (m *MinimaxAI) evalDigest() uint64 {
+	h := fnv.New64a()
+	switch {
+	case m.Cfg.Evaluate != nil:
+		h.Write([]byte("custom"))
+	case m.Cfg.Weights != nil:
+		binary.Write(h, binary.LittleEndian, m.Cfg.Weights)
+	default:
+		binary.Write(h, binary.LittleEndian, &DefaultWeights[m.Cfg.Size])
+	}
+	binary.Write(h, binary.LittleEndian, []bool{
+		m.Cfg.NoNullMove,
+		m.Cfg.NoExtendForces,
+		m.Cfg.NoReduceSlides,
+		m.Cfg.MultiCut,
+	})
+	return h.Sum64()
+}
+
+// clearTable empties the transposition table.
+func (m *MinimaxAI) clearTable() {
+	for i := range m.table {
+		m.table[i] = ttSlot{}
+	}
+}
+
+// hashFingerprint returns a value derived from the hashes of a few
+// positions on a board of size `size`. Table entries are keyed by
+// tak.Position.Hash, so a table is only usable if the fingerprint
+// it was saved with matches.
+func hashFingerprint(size int) uint64 {
+	p := tak.New(tak.Config{Size: size})
+	fp := p.Hash()
+	for _, s := range []string{"a1", "b1", "Sb2", "a2", "b2-"} {
+		m, err := ptn.ParseMove(s)
+		if err != nil {
+			panic(err)
+		}
+		p, err = p.Move(m)
+		if err != nil {
+			panic(err)
+		}
+		fp = fp*hashMul ^ p.Hash()
+	}
+	return fp
+}
+
+// WriteTable writes the transposition table to `w`. It must not be
+// called while Analyze is running.
+func (m *MinimaxAI) WriteTable(w io.Writer) error {
+	if m.table == nil {
+		return errors.New("no transposition table")
+	}
+	bw := bufio.NewWriter(w)
+	hdr := tableHeader{
+		Magic:         tableMagic,
+		Version:       tableVersion,
+		Size:          uint32(m.Cfg.Size),
+		Komi:          int32(m.game.Komi),
+		Pieces:        uint32(m.game.Pieces),
+		Capstones:     uint32(m.game.Capstones),
+		BlackWinsTies: m.game.BlackWinsTies,
+		Fingerprint:   hashFingerprint(m.Cfg.Size),
+		EvalDigest:    m.evalDigest(),
+		Slots:         uint64(len(m.table)),
+	}
+	if err := binary.Write(bw, binary.LittleEndian, &hdr); err != nil {
+		return err
+	}
+	var buf [24]byte
+	for i := range m.table {
+		s := &m.table[i]
+		binary.LittleEndian.PutUint64(buf[0:], s.check)
+		binary.LittleEndian.PutUint64(buf[8:], s.data)
+		binary.LittleEndian.PutUint64(buf[16:], s.move)
+		if _, err := bw.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadTable loads a transposition table written by WriteTable,
+// replacing the current contents of the table. `g` is the
+// configuration of the games we will analyze; the table must have
+// been saved for the same komi and reserves, by an AI with the same
+// evaluation settings. If the saved table has a different number of
+// slots, its entries are rehashed into ours, and entries that
+// collide are dropped. If the table cannot be read, ReadTable
+// returns an error and leaves our table empty. It must not be
+// called while Analyze is running.
+func (m *MinimaxAI) ReadTable(r io.Reader, g tak.Config) error {
+	if m.table == nil {
+		return errors.New("no transposition table")
+	}
+	if g.Size != m.Cfg.Size {
+		return fmt.Errorf("ReadTable: size %d, not %d", g.Size, m.Cfg.Size)
+	}
+	want := gameOf(g)
+	m.clearTable()
+	m.game = want
+	br := bufio.NewReader(r)
+	var hdr tableHeader
+	if err := binary.Read(br, binary.LittleEndian, &hdr); err != nil {
+		return fmt.Errorf("reading header: %w", err)
+	}
+	if hdr.Magic != tableMagic {
+		return errors.New("not a transposition table")
+	}
+	if hdr.Version != tableVersion {
+		return fmt.Errorf("%w: version %d", ErrIncompatibleTable, hdr.Version)
+	}
+	if int(hdr.Size) != m.Cfg.Size {
+		return fmt.Errorf("%w: saved for size %d, not %d",
+			ErrIncompatibleTable, hdr.Size, m.Cfg.Size)
+	}
+	if hdr.Fingerprint != hashFingerprint(m.Cfg.Size) {
+		return fmt.Errorf("%w: position hashes differ", ErrIncompatibleTable)
+	}
+	if int(hdr.Komi) != want.Komi {
+		return fmt.Errorf("%w: saved for komi %d, not %d",
+			ErrIncompatibleTable, hdr.Komi, want.Komi)
+	}
+	if int(hdr.Pieces) != want.Pieces || int(hdr.Capstones) != want.Capstones {
+		return fmt.Errorf("%w: saved for reserves %d/%d, not %d/%d",
+			ErrIncompatibleTable, hdr.Pieces, hdr.Capstones,
+			want.Pieces, want.Capstones)
+	}
+	if hdr.BlackWinsTies != want.BlackWinsTies {
+		return fmt.Errorf("%w: tie rules differ", ErrIncompatibleTable)
+	}
+	if hdr.EvalDigest != m.evalDigest() {
+		return fmt.Errorf("%w: evaluation settings differ", ErrIncompatibleTable)
+	}
+
+	if err := readSlots(br, m.table, hdr.Slots); err != nil {
+		m.clearTable()
+		return err
+	}
+	return nil
+}
+
+func readSlots(br *bufio.Reader, table []ttSlot, n uint64) error {
+	var buf [24]byte
+	for i := uint64(0); i < n; i++ {
+		if _, err := io.ReadFull(br, buf[:]); err != nil {
+			return fmt.Errorf("reading slot %d: %w", i, err)
+		}
+		s := ttSlot{
+			check: binary.LittleEndian.Uint64(buf[0:]),
+			data:  binary.LittleEndian.Uint64(buf[8:]),
+			move:  binary.LittleEndian.Uint64(buf[16:]),
+		}
+		if n == uint64(len(table)) {
+			table[i] = s
+			continue
+		}
+		insertSlot(table, s)
+	}
+	return nil
+}
+
+// insertSlot stores the entry `s` from another table into `table`.
+// A position may be present in both of its slots, in either table;
+// we keep the deepest entry. If both slots hold other positions, the
+// entry is dropped.
+func insertSlot(table []ttSlot, s ttSlot) {
+	var e, e1, e2 tableEntry
+	s.load(&e)
+	if e.hash == 0 {
+		return
+	}
+	i1 := e.hash % uint64(len(table))
+	i2 := (e.hash * hashMul) % uint64(len(table))
+	table[i1].load(&e1)
+	table[i2].load(&e2)
+	switch {
+	case e1.hash == e.hash:
+		if e1.depth < e.depth {
+			table[i1] = s
+		}
+	case e2.hash == e.hash:
+		if e2.depth < e.depth {
+			table[i2] = s
+		}
+	case e1.hash == 0:
+		table[i1] = s
+	case e2.hash == 0:
+		table[i2] = s
+	}
+}
+
+// MergeTable adds the entries in o's transposition table to ours.
+// Both AIs must be analyzing games with the same configuration, with
+// the same evaluation settings, or MergeTable returns
+// ErrIncompatibleTable. It must not be called while either AI is
+// running Analyze.
+func (m *MinimaxAI) MergeTable(o *MinimaxAI) error {
+	if m.table == nil || o.table == nil {
+		return errors.New("no transposition table")
+	}
+	if o.game != m.game {
+		return fmt.Errorf("%w: games differ", ErrIncompatibleTable)
+	}
+	if o.evalDigest() != m.evalDigest() {
+		return fmt.Errorf("%w: evaluation settings differ", ErrIncompatibleTable)
+	}
+	for _, s := range o.table {
+		insertSlot(m.table, s)
+	}
+	return nil
+}
+
+// SaveTable writes the transposition table to the file at `path`,
+// replacing it atomically.
+func (m *MinimaxAI) SaveTable(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := m.WriteTable(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadTable loads a transposition table saved by SaveTable, for
+// analyzing games configured by `g`. See ReadTable; if the file
+// can't be opened, the table is likewise left empty.
+func (m *MinimaxAI) LoadTable(path string, g tak.Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if m.table != nil && g.Size == m.Cfg.Size {
+			m.clearTable()
+			m.game = gameOf(g)
+		}
+		return err
+	}
+	defer f.Close()
+	return m.ReadTable(f, g)
+}
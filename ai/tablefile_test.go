@@ -0,0 +1,131 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+func TestSaveTable(t *testing.T) {
+	p, err := ptn.ParseTPS(`2,x4/x2,2,x2/x,2,2,x2/x2,12,2,1/1,1,21,2,1 1 9`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := MinimaxConfig{Size: p.Size(), Depth: 4, TableMem: 1 << 20}
+	ai := NewMinimax(cfg)
+	pv, _, _ := ai.Analyze(context.Background(), p)
+
+	var buf bytes.Buffer
+	if err := ai.WriteTable(&buf); err != nil {
+		t.Fatal("write: ", err)
+	}
+	saved := buf.Bytes()
+
+	for _, mem := range []int64{1 << 20, 1 << 19} {
+		cfg.TableMem = mem
+		loaded := NewMinimax(cfg)
+		if err := loaded.ReadTable(bytes.NewReader(saved), p.Config()); err != nil {
+			t.Fatalf("mem=%d: read: %v", mem, err)
+		}
+		var e tableEntry
+		te := loaded.ttGet(p.Hash(), &e)
+		if te == nil {
+			t.Fatalf("mem=%d: root position missing", mem)
+		}
+		if te.depth != 4 || te.bound != exactBound || !te.m.Equal(pv[0]) {
+			t.Fatalf("mem=%d: bad root entry: %+v", mem, te)
+		}
+	}
+
+	other := NewMinimax(MinimaxConfig{Size: 6, TableMem: 1 << 20})
+	if err := other.ReadTable(bytes.NewReader(saved), tak.Config{Size: 6}); !errors.Is(err, ErrIncompatibleTable) {
+		t.Fatalf("loaded a 5x5 table into a 6x6 AI: %v", err)
+	}
+	for _, g := range []tak.Config{
+		{Size: 5, Komi: 4},
+		{Size: 5, Pieces: 20},
+		{Size: 5, Capstones: tak.NoCapstones},
+		{Size: 5, BlackWinsTies: true},
+	} {
+		if err := ai.ReadTable(bytes.NewReader(saved), g); !errors.Is(err, ErrIncompatibleTable) {
+			t.Fatalf("loaded a table into an AI for %+v: %v", g, err)
+		}
+	}
+	w := DefaultWeights[5]
+	w[TopFlat]++
+	precise := cfg
+	precise.MakePrecise()
+	for _, c := range []MinimaxConfig{
+		{Size: 5, TableMem: 1 << 20, Weights: &w},
+		{Size: 5, TableMem: 1 << 20, Evaluate: EvaluateWinner},
+		precise,
+	} {
+		if err := NewMinimax(c).ReadTable(bytes.NewReader(saved), p.Config()); !errors.Is(err, ErrIncompatibleTable) {
+			t.Fatalf("loaded a table with different evaluation settings: %v", err)
+		}
+	}
+	if err := ai.ReadTable(bytes.NewReader(saved[:len(saved)/2]), p.Config()); err == nil {
+		t.Fatal("loaded a truncated table")
+	}
+	var e tableEntry
+	if ai.ttGet(p.Hash(), &e) != nil {
+		t.Fatal("failed load did not clear the table")
+	}
+}
+
+func TestTableKomi(t *testing.T) {
+	p, err := ptn.ParseTPS(`2,x4/x2,2,x2/x,2,2,x2/x2,12,2,1/1,1,21,2,1 1 9`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ai := NewMinimax(MinimaxConfig{Size: 5, Depth: 3, TableMem: 1 << 20})
+	ai.Analyze(context.Background(), p)
+
+	komi, err := ptn.ParseTPSWithConfig(`2,x4/x2,2,x2/x,2,2,x2/x2,12,2,1/1,1,21,2,1 1 9`,
+		tak.Config{Size: 5, Komi: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ai.Cfg.Depth = 1
+	_, _, st := ai.Analyze(context.Background(), komi)
+	if st.Depth != 1 {
+		t.Fatalf("reused a depth-%d result from a game without komi", st.Depth)
+	}
+}
+
+func TestMergeTable(t *testing.T) {
+	p, err := ptn.ParseTPS(`2,x4/x2,2,x2/x,2,2,x2/x2,12,2,1/1,1,21,2,1 1 9`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := ptn.ParseTPS(`x5/x5/x2,1,x2/x5/2,x4 1 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := MinimaxConfig{Size: 5, Depth: 3, TableMem: 1 << 20}
+	a, b := NewMinimax(cfg), NewMinimax(cfg)
+	a.Analyze(context.Background(), p)
+	b.Analyze(context.Background(), q)
+	if err := a.MergeTable(b); err != nil {
+		t.Fatal("merge: ", err)
+	}
+	var e tableEntry
+	for _, pos := range []*tak.Position{p, q} {
+		if te := a.ttGet(pos.Hash(), &e); te == nil || te.depth != 3 {
+			t.Fatalf("missing depth-3 entry after merge: %+v", te)
+		}
+	}
+
+	komi, err := ptn.ParseTPSWithConfig(`x5/x5/x5/x5/x5 1 1`, tak.Config{Size: 5, Komi: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Analyze(context.Background(), komi)
+	if err := a.MergeTable(b); !errors.Is(err, ErrIncompatibleTable) {
+		t.Fatalf("merged a table from a game with komi: %v", err)
+	}
+}
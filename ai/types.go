@@ -9,3 +9,27 @@ import (
 type TakPlayer interface {
 	GetMove(ctx context.Context, p *tak.Position) tak.Move
 }
+
+// Experience is knowledge carried over from previous games, which
+// MinimaxAI consults in addition to its transposition table. It is
+// called concurrently by every search thread, for each position
+// missing from the table, so Lookup must be cheap, and should not
+// take locks.
+type Experience interface {
+	// Lookup returns the best move and value found by a
+	// previous search of the position with ExperienceKey `h`,
+	// and the depth of that search.
+	Lookup(h uint64) (m tak.Move, v int64, depth int, ok bool)
+	// Avoid returns moves known to have lost games from the
+	// position with ExperienceKey `h`.
+	Avoid(h uint64) []tak.Move
+}
+
+// ExperienceKey returns the key under which MinimaxAI looks `p` up
+// in its Experience. Position hashes don't cover komi or the size of
+// the reserves, which change the value of a position, so the key
+// combines them with the hash. For a game with the standard rules,
+// it is just p.Hash().
+func ExperienceKey(p *tak.Position) uint64 {
+	return p.Hash() ^ gameOf(p.Config()).key()
+}
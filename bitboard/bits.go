@@ -1,27 +1,134 @@
 package bitboard
 
+// Bits is a set of squares on a board of up to 11x11, with the
+// square (x, y) stored at bit x+y*size. Boards of size 8 or smaller
+// fit entirely within Lo, and Hi is always zero for them.
+type Bits struct {
+	Lo, Hi uint64
+}
+
+// Bit returns the set containing only bit `i`.
+func Bit(i uint) Bits {
+	// Go defines over-wide shifts to produce zero, so exactly
+	// one of these is nonzero.
+	return Bits{Lo: 1 << i, Hi: 1 << (i - 64)}
+}
+
+func (b Bits) And(o Bits) Bits {
+	return Bits{b.Lo & o.Lo, b.Hi & o.Hi}
+}
+
+func (b Bits) Or(o Bits) Bits {
+	return Bits{b.Lo | o.Lo, b.Hi | o.Hi}
+}
+
+func (b Bits) Xor(o Bits) Bits {
+	return Bits{b.Lo ^ o.Lo, b.Hi ^ o.Hi}
+}
+
+func (b Bits) AndNot(o Bits) Bits {
+	return Bits{b.Lo &^ o.Lo, b.Hi &^ o.Hi}
+}
+
+func (b Bits) Not() Bits {
+	return Bits{^b.Lo, ^b.Hi}
+}
+
+// Shl shifts `b` left by `n` bits; `n` must be at most 64.
+func (b Bits) Shl(n uint) Bits {
+	return Bits{b.Lo << n, b.Hi<<n | b.Lo>>(64-n)}
+}
+
+// Shr shifts `b` right by `n` bits; `n` must be at most 64.
+func (b Bits) Shr(n uint) Bits {
+	return Bits{b.Lo>>n | b.Hi<<(64-n), b.Hi >> n}
+}
+
+func (b Bits) IsZero() bool {
+	return b.Lo|b.Hi == 0
+}
+
+// Intersects reports whether `b` and `o` have any bits in common.
+func (b Bits) Intersects(o Bits) bool {
+	return b.Lo&o.Lo|b.Hi&o.Hi != 0
+}
+
+// Has reports whether bit `i` is set.
+func (b Bits) Has(i uint) bool {
+	return b.Intersects(Bit(i))
+}
+
+// Lowest returns the lowest set bit of `b`.
+func (b Bits) Lowest() Bits {
+	if b.Lo != 0 {
+		return Bits{Lo: b.Lo & -b.Lo}
+	}
+	return Bits{Hi: b.Hi & -b.Hi}
+}
+
+// ClearLowest returns `b` with its lowest set bit cleared.
+func (b Bits) ClearLowest() Bits {
+	if b.Lo != 0 {
+		return Bits{b.Lo & (b.Lo - 1), b.Hi}
+	}
+	return Bits{0, b.Hi & (b.Hi - 1)}
+}
+
+func (b Bits) Popcount() int {
+	return Popcount(b.Lo) + Popcount(b.Hi)
+}
+
+// TrailingZeros returns the index of the lowest set bit of `b`, or
+// 128 if `b` is empty.
+func (b Bits) TrailingZeros() uint {
+	if b.Lo != 0 {
+		return TrailingZeros(b.Lo)
+	}
+	return 64 + TrailingZeros(b.Hi)
+}
+
 type Constants struct {
 	Size       uint
-	L, R, T, B uint64
-	Edge       uint64
-	Mask       uint64
+	L, R, T, B Bits
+	Edge       Bits
+	Mask       Bits
 }
 
+// MaxSize is the largest board size that fits in a Bits.
+const MaxSize = 11
+
 func Precompute(size uint) Constants {
 	var c Constants
 	for i := uint(0); i < size; i++ {
-		c.R |= 1 << (i * size)
+		c.R = c.R.Or(Bit(i * size))
+		c.L = c.L.Or(Bit(i*size + size - 1))
+		c.B = c.B.Or(Bit(i))
+		c.T = c.T.Or(Bit(size*(size-1) + i))
+	}
+	for i := uint(0); i < size*size; i++ {
+		c.Mask = c.Mask.Or(Bit(i))
 	}
 	c.Size = size
-	c.L = c.R << (size - 1)
-	c.T = ((1 << size) - 1) << (size * (size - 1))
-	c.B = (1 << size) - 1
-	c.Mask = 1<<(size*size) - 1
-	c.Edge = c.L | c.R | c.B | c.T
+	c.Edge = c.L.Or(c.R).Or(c.B).Or(c.T)
 	return c
 }
 
-func Flood(c *Constants, within uint64, seed uint64) uint64 {
+// IsRoad reports whether `g` spans two opposite edges of the board.
+func (c *Constants) IsRoad(g Bits) bool {
+	return (g.Intersects(c.T) && g.Intersects(c.B)) ||
+		(g.Intersects(c.L) && g.Intersects(c.R))
+}
+
+// narrow reports whether the board fits in a single word, in which
+// case the hot functions below operate on Lo alone.
+func (c *Constants) narrow() bool {
+	return c.Mask.Hi == 0
+}
+
+func Flood(c *Constants, within Bits, seed Bits) Bits {
+	if c.narrow() {
+		return Bits{Lo: flood64(c, within.Lo, seed.Lo)}
+	}
 	for {
 		next := Grow(c, within, seed)
 		if next == seed {
@@ -31,25 +138,75 @@ func Flood(c *Constants, within uint64, seed uint64) uint64 {
 	}
 }
 
-func Grow(c *Constants, within uint64, seed uint64) uint64 {
+func flood64(c *Constants, within uint64, seed uint64) uint64 {
+	for {
+		next := Grow64(c, within, seed)
+		if next == seed {
+			return next
+		}
+		seed = next
+	}
+}
+
+func Grow(c *Constants, within Bits, seed Bits) Bits {
+	if c.narrow() {
+		return Bits{Lo: Grow64(c, within.Lo, seed.Lo)}
+	}
+	return growWide(c, within, seed)
+}
+
+func growWide(c *Constants, within Bits, seed Bits) Bits {
+	next := seed
+	next = next.Or(seed.Shl(1).AndNot(c.R))
+	next = next.Or(seed.Shr(1).AndNot(c.L))
+	next = next.Or(seed.Shr(c.Size))
+	next = next.Or(seed.Shl(c.Size))
+	return next.And(within)
+}
+
+// Grow64 is Grow for boards of size 8 or smaller, which fit in the
+// Lo word of a Bits.
+func Grow64(c *Constants, within uint64, seed uint64) uint64 {
 	next := seed
-	next |= (seed << 1) &^ c.R
-	next |= (seed >> 1) &^ c.L
+	next |= (seed << 1) &^ c.R.Lo
+	next |= (seed >> 1) &^ c.L.Lo
 	next |= (seed >> c.Size)
 	next |= (seed << c.Size)
 	return next & within
 }
 
-func FloodGroups(c *Constants, bits uint64, out []uint64) []uint64 {
+func FloodGroups(c *Constants, bits Bits, out []Bits) []Bits {
+	if c.narrow() {
+		return floodGroups64(c, bits.Lo, out)
+	}
+	var seen Bits
+	for !bits.IsZero() {
+		next := bits.ClearLowest()
+		bit := bits.AndNot(next)
+
+		if !seen.Intersects(bit) {
+			g := Flood(c, bits, bit)
+			if g != bit {
+				out = append(out, g)
+			}
+			seen = seen.Or(g)
+		}
+
+		bits = next
+	}
+	return out
+}
+
+func floodGroups64(c *Constants, bits uint64, out []Bits) []Bits {
 	var seen uint64
 	for bits != 0 {
 		next := bits & (bits - 1)
 		bit := bits &^ next
 
 		if seen&bit == 0 {
-			g := Flood(c, bits, bit)
+			g := flood64(c, bits, bit)
 			if g != bit {
-				out = append(out, g)
+				out = append(out, Bits{Lo: g})
 			}
 			seen |= g
 		}
@@ -59,35 +216,58 @@ func FloodGroups(c *Constants, bits uint64, out []uint64) []uint64 {
 	return out
 }
 
-func Dimensions(c *Constants, bits uint64) (w, h int) {
-	if bits == 0 {
+func Dimensions(c *Constants, bits Bits) (w, h int) {
+	if bits.IsZero() {
 		return 0, 0
 	}
+	if c.narrow() {
+		return dimensions64(c, bits.Lo)
+	}
 	b := c.L
-	for bits&b == 0 {
-		b >>= 1
+	for !bits.Intersects(b) {
+		b = b.Shr(1)
 	}
-	for b != 0 && bits&b != 0 {
-		b >>= 1
+	for bits.Intersects(b) {
+		b = b.Shr(1)
 		w++
 	}
 	b = c.T
-	for bits&b == 0 {
-		b >>= c.Size
+	for !bits.Intersects(b) {
+		b = b.Shr(c.Size)
 	}
-	for b != 0 && bits&b != 0 {
-		b >>= c.Size
+	for bits.Intersects(b) {
+		b = b.Shr(c.Size)
 		h++
 	}
 	return w, h
 }
 
-func BitCoords(c *Constants, bits uint64) (x, y uint) {
-	if bits == 0 || bits&(bits-1) != 0 {
+func BitCoords(c *Constants, bits Bits) (x, y uint) {
+	if bits.IsZero() || !bits.ClearLowest().IsZero() {
 		panic("BitCoords: non-singular")
 	}
-	n := TrailingZeros(bits)
+	n := bits.TrailingZeros()
 	y = n / c.Size
 	x = n % c.Size
 	return x, y
 }
+
+func dimensions64(c *Constants, bits uint64) (w, h int) {
+	b := c.L.Lo
+	for bits&b == 0 {
+		b >>= 1
+	}
+	for b != 0 && bits&b != 0 {
+		b >>= 1
+		w++
+	}
+	b = c.T.Lo
+	for bits&b == 0 {
+		b >>= c.Size
+	}
+	for b != 0 && bits&b != 0 {
+		b >>= c.Size
+		h++
+	}
+	return w, h
+}
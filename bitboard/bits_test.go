@@ -1,70 +1,169 @@
 package bitboard
 
 import (
-	"strconv"
 	"testing"
 )
 
 func TestPrecompute(t *testing.T) {
 	c := Precompute(5)
-	if c.B != (1<<5)-1 {
-		t.Error("c.b(5):", strconv.FormatUint(c.B, 2))
+	if c.B != (Bits{Lo: (1 << 5) - 1}) {
+		t.Error("c.b(5):", c.B)
 	}
-	if c.T != ((1<<5)-1)<<(4*5) {
-		t.Error("c.t(5):", strconv.FormatUint(c.T, 2))
+	if c.T != (Bits{Lo: ((1 << 5) - 1) << (4 * 5)}) {
+		t.Error("c.t(5):", c.T)
 	}
-	if c.R != 0x0108421 {
-		t.Error("c.r(5):", strconv.FormatUint(c.R, 2))
+	if c.R != (Bits{Lo: 0x0108421}) {
+		t.Error("c.r(5):", c.R)
 	}
-	if c.L != 0x1084210 {
-		t.Error("c.l(5):", strconv.FormatUint(c.L, 2))
+	if c.L != (Bits{Lo: 0x1084210}) {
+		t.Error("c.l(5):", c.L)
 	}
-	if c.Mask != 0x1ffffff {
-		t.Error("c.mask(5):", strconv.FormatUint(c.Mask, 2))
+	if c.Mask != (Bits{Lo: 0x1ffffff}) {
+		t.Error("c.mask(5):", c.Mask)
 	}
 
 	c = Precompute(8)
-	if c.B != (1<<8)-1 {
-		t.Error("c.b(8):", strconv.FormatUint(c.B, 2))
+	if c.B != (Bits{Lo: (1 << 8) - 1}) {
+		t.Error("c.b(8):", c.B)
 	}
-	if c.T != ((1<<8)-1)<<(7*8) {
-		t.Error("c.t(8):", strconv.FormatUint(c.T, 2))
+	if c.T != (Bits{Lo: ((1 << 8) - 1) << (7 * 8)}) {
+		t.Error("c.t(8):", c.T)
 	}
-	if c.R != 0x101010101010101 {
-		t.Error("c.r(8):", strconv.FormatUint(c.R, 2))
+	if c.R != (Bits{Lo: 0x101010101010101}) {
+		t.Error("c.r(8):", c.R)
 	}
-	if c.L != 0x8080808080808080 {
-		t.Error("c.l(8):", strconv.FormatUint(c.L, 2))
+	if c.L != (Bits{Lo: 0x8080808080808080}) {
+		t.Error("c.l(8):", c.L)
 	}
-	if c.Mask != ^uint64(0) {
-		t.Error("c.mask(8):", strconv.FormatUint(c.Mask, 2))
+	if c.Mask != (Bits{Lo: ^uint64(0)}) {
+		t.Error("c.mask(8):", c.Mask)
+	}
+
+	c = Precompute(10)
+	if c.B != (Bits{Lo: (1 << 10) - 1}) {
+		t.Error("c.b(10):", c.B)
+	}
+	if c.T != (Bits{Hi: ((1 << 10) - 1) << (90 - 64)}) {
+		t.Error("c.t(10):", c.T)
+	}
+	if c.Mask != (Bits{Lo: ^uint64(0), Hi: (1 << (100 - 64)) - 1}) {
+		t.Error("c.mask(10):", c.Mask)
+	}
+	if c.R.Popcount() != 10 || c.L.Popcount() != 10 {
+		t.Error("c.l/c.r(10):", c.L, c.R)
+	}
+	if c.L != c.R.Shl(9) {
+		t.Error("c.l(10) != c.r(10)<<9:", c.L, c.R)
+	}
+}
+
+func TestShift(t *testing.T) {
+	cases := []struct {
+		in  Bits
+		n   uint
+		shl Bits
+		shr Bits
+	}{
+		{Bits{Lo: 1}, 0, Bits{Lo: 1}, Bits{Lo: 1}},
+		{Bits{Lo: 1 << 63}, 1, Bits{Hi: 1}, Bits{Lo: 1 << 62}},
+		{Bits{Hi: 1}, 10, Bits{Hi: 1 << 10}, Bits{Lo: 1 << 54}},
+		{Bits{Lo: 0xff << 56, Hi: 0xf}, 8, Bits{Hi: 0xfff}, Bits{Lo: 0xf<<56 | 0xff<<48}},
+		{Bits{Lo: 3}, 64, Bits{Hi: 3}, Bits{}},
+	}
+	for _, tc := range cases {
+		if got := tc.in.Shl(tc.n); got != tc.shl {
+			t.Errorf("%v.Shl(%d) = %v != %v", tc.in, tc.n, got, tc.shl)
+		}
+		if got := tc.in.Shr(tc.n); got != tc.shr {
+			t.Errorf("%v.Shr(%d) = %v != %v", tc.in, tc.n, got, tc.shr)
+		}
+	}
+}
+
+func TestBitOps(t *testing.T) {
+	for _, i := range []uint{0, 1, 63, 64, 65, 127} {
+		b := Bit(i)
+		if b.Popcount() != 1 || b.TrailingZeros() != i || !b.Has(i) {
+			t.Errorf("Bit(%d) = %v", i, b)
+		}
+		if b.Lowest() != b || !b.ClearLowest().IsZero() {
+			t.Errorf("Bit(%d): lowest=%v clear=%v", i, b.Lowest(), b.ClearLowest())
+		}
+	}
+	b := Bit(3).Or(Bit(70))
+	if b.Lowest() != Bit(3) || b.ClearLowest() != Bit(70) {
+		t.Errorf("%v: lowest=%v clear=%v", b, b.Lowest(), b.ClearLowest())
+	}
+	if (Bits{}).TrailingZeros() != 128 {
+		t.Errorf("TrailingZeros(0) = %d", (Bits{}).TrailingZeros())
 	}
 }
 
 func TestFlood(t *testing.T) {
 	cases := []struct {
 		size  uint
-		bound uint64
-		seed  uint64
-		out   uint64
+		bound Bits
+		seed  Bits
+		out   Bits
 	}{
 		{
 			5,
-			0x108423c,
-			0x4,
-			0x108421c,
+			Bits{Lo: 0x108423c},
+			Bits{Lo: 0x4},
+			Bits{Lo: 0x108421c},
+		},
+		{
+			// column c of a 10x10 board, plus a1
+			10,
+			column(10, 2).Or(Bit(0)),
+			Bit(2),
+			column(10, 2),
+		},
+		{
+			// the a1-j10 diagonal is not connected
+			10,
+			diagonal(10),
+			Bit(0),
+			Bit(0),
 		},
 	}
 	for _, tc := range cases {
 		c := Precompute(tc.size)
 		got := Flood(&c, tc.bound, tc.seed)
 		if got != tc.out {
-			t.Errorf("Flood[%d](%s, %s)=%s !=%s",
-				tc.size,
-				strconv.FormatUint(tc.bound, 2),
-				strconv.FormatUint(tc.seed, 2),
-				strconv.FormatUint(got, 2),
-				strconv.FormatUint(tc.out, 2))
+			t.Errorf("Flood[%d](%v, %v)=%v !=%v",
+				tc.size, tc.bound, tc.seed, got, tc.out)
+		}
+	}
+}
+
+func column(size, x uint) Bits {
+	var b Bits
+	for y := uint(0); y < size; y++ {
+		b = b.Or(Bit(x + y*size))
+	}
+	return b
+}
+
+func diagonal(size uint) Bits {
+	var b Bits
+	for i := uint(0); i < size; i++ {
+		b = b.Or(Bit(i + i*size))
+	}
+	return b
+}
+
+func TestFloodGroups(t *testing.T) {
+	for _, size := range []uint{5, 8, 10} {
+		c := Precompute(size)
+		bits := column(size, 0).Or(column(size, size-1)).Or(Bit(size + 2))
+		gs := FloodGroups(&c, bits, nil)
+		if len(gs) != 2 {
+			t.Errorf("FloodGroups[%d]: got %d groups", size, len(gs))
+			continue
+		}
+		if gs[0] != column(size, 0) || gs[1] != column(size, size-1) {
+			t.Errorf("FloodGroups[%d]: got %v", size, gs)
 		}
 	}
 }
@@ -72,14 +171,16 @@ func TestFlood(t *testing.T) {
 func TestDimensions(t *testing.T) {
 	cases := []struct {
 		size uint
-		bits uint64
+		bits Bits
 		w    int
 		h    int
 	}{
-		{5, 0x108421c, 3, 5},
-		{5, 0, 0, 0},
-		{5, 0x843800, 3, 3},
-		{5, 0x08000, 1, 1},
+		{5, Bits{Lo: 0x108421c}, 3, 5},
+		{5, Bits{}, 0, 0},
+		{5, Bits{Lo: 0x843800}, 3, 3},
+		{5, Bits{Lo: 0x08000}, 1, 1},
+		{10, column(10, 4), 1, 10},
+		{10, Bit(95).Or(Bit(96)), 2, 1},
 	}
 	for _, tc := range cases {
 		c := Precompute(tc.size)
@@ -104,10 +205,12 @@ func TestBitCoords(t *testing.T) {
 		{3, 2, 2},
 		{5, 3, 1},
 		{5, 0, 1},
+		{10, 9, 9},
+		{10, 3, 6},
 	}
 	for _, tc := range cases {
 		c := Precompute(tc.size)
-		bit := uint64(1) << (c.Size*tc.y + tc.x)
+		bit := Bit(c.Size*tc.y + tc.x)
 		x, y := BitCoords(&c, bit)
 		if x != tc.x || y != tc.y {
 			t.Errorf("BitCoords(Precompute(%d), (%d,%d)) = (%d, %d)",
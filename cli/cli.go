@@ -122,7 +122,7 @@ func RenderBoard(g *Glyphs, out io.Writer, p *tak.Position) {
 	fmt.Fprintf(out, "[%s to play]\n", p.ToMove())
 	w := tabwriter.NewWriter(out, 4, 8, 1, '\t', 0)
 	for y := p.Size() - 1; y >= 0; y-- {
-		fmt.Fprintf(w, "%c.\t", '1'+y)
+		fmt.Fprintf(w, "%d.\t", y+1)
 		for x := 0; x < p.Size(); x++ {
 			var stk []string
 			for _, stone := range p.At(x, y) {
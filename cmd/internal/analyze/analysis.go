@@ -39,16 +39,30 @@ func (m *minimaxAnalysis) Analyze(ctx context.Context, p *tak.Position) {
 		fmt.Printf(" Val=%d\n", val)
 		return
 	}
-	pvs, val, _ := m.ai.AnalyzeAll(ctx, p)
-	fmt.Printf("AI analysis:\n")
-	for _, pv := range pvs {
-		fmt.Printf(" pv=")
-		for _, m := range pv {
-			fmt.Printf("%s ", ptn.FormatMove(m))
+	var pvs [][]tak.Move
+	var val int64
+	if m.cmd.multiPV > 1 {
+		lines, _ := m.ai.AnalyzeLines(ctx, p)
+		fmt.Printf("AI analysis:\n")
+		for i, l := range lines {
+			fmt.Printf(" %d. value=%d pv=%s\n", i+1, l.Value, formatPV(l.PV))
+			pvs = append(pvs, l.PV)
+		}
+		if len(lines) > 0 {
+			val = lines[0].Value
+		}
+	} else {
+		pvs, val, _ = m.ai.AnalyzeAll(ctx, p)
+		fmt.Printf("AI analysis:\n")
+		for _, pv := range pvs {
+			fmt.Printf(" pv=")
+			for _, m := range pv {
+				fmt.Printf("%s ", ptn.FormatMove(m))
+			}
+			fmt.Printf("\n")
 		}
-		fmt.Printf("\n")
+		fmt.Printf(" value=%d\n", val)
 	}
-	fmt.Printf(" value=%d\n", val)
 	if m.cmd.tps {
 		fmt.Printf("[TPS \"%s\"]\n", ptn.FormatTPS(p))
 	}
@@ -171,6 +185,7 @@ func (a *dfpnAnalysis) Analyze(ctx context.Context, p *tak.Position) {
 		Debug:    a.cmd.mmopt.Debug,
 		TableMem: a.cmd.mmopt.TableMem,
 		Attacker: attacker,
+		Threads:  a.cmd.mmopt.Threads,
 	})
 
 	if !a.cmd.quiet {
@@ -208,4 +223,8 @@ func (a *dfpnAnalysis) Analyze(ctx context.Context, p *tak.Position) {
 		stats.Hits+stats.Miss,
 		100*float64(stats.Hits)/float64(stats.Hits+stats.Miss),
 	)
+	fmt.Printf(" overwrites=%d solved-overwrites=%d\n",
+		stats.Overwrites,
+		stats.SolvedOverwrites,
+	)
 }
@@ -0,0 +1,198 @@
+package analyze
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nelhage/taktician/ai"
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+const (
+	// A move that loses at least mistakeLoss relative to the
+	// engine's best move is marked "?", and one that loses
+	// blunderLoss is marked "??". A best move that is better
+	// than any alternative by onlyMoveMargin is marked "!".
+	// For scale, a flat on top of the board is worth 400.
+	mistakeLoss    = 400
+	blunderLoss    = 1200
+	onlyMoveMargin = 800
+)
+
+// moveEval is the engine's assessment of a position, from the point
+// of view of the player to move. If there is more than one legal
+// move, `second` is the value of the best alternative to pv[0].
+type moveEval struct {
+	value     int64
+	pv        []tak.Move
+	second    int64
+	hasSecond bool
+}
+
+// clampValue limits a value to the range in which differences are
+// meaningful: all wins are equally good.
+func clampValue(v int64) int64 {
+	if v > ai.WinThreshold {
+		return ai.WinThreshold
+	}
+	if v < -ai.WinThreshold {
+		return -ai.WinThreshold
+	}
+	return v
+}
+
+// formatEval renders a value from White's point of view.
+func formatEval(v int64) string {
+	switch {
+	case v > ai.WinThreshold:
+		return "+win"
+	case v < -ai.WinThreshold:
+		return "-win"
+	}
+	return fmt.Sprintf("%+d", v)
+}
+
+func (c *Command) evaluateMove(player *ai.MinimaxAI, p *tak.Position) moveEval {
+	ctx := context.Background()
+	if c.timeLimit != 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, c.timeLimit)
+		defer cancel()
+	}
+	lines, _ := player.AnalyzeLines(ctx, p)
+	var e moveEval
+	if len(lines) > 0 {
+		e.value, e.pv = lines[0].Value, lines[0].PV
+	}
+	if len(lines) > 1 {
+		e.second, e.hasSecond = lines[1].Value, true
+	}
+	return e
+}
+
+// terminalEval returns the value of a finished game for the player
+// to move.
+func terminalEval(p *tak.Position) moveEval {
+	switch p.WinDetails().Winner {
+	case tak.NoColor:
+		return moveEval{}
+	case p.ToMove():
+		return moveEval{value: ai.MaxEval}
+	}
+	return moveEval{value: ai.MinEval}
+}
+
+// annotateGame analyzes every move in the main line of `g`, and adds
+// the engine's verdict to it: a comment after each move with the
+// evaluation of the resulting position, the move the engine would
+// have played and its principal variation; a "?", "??" or "!"
+// annotation where one is deserved; and an "Accuracy" tag, giving
+// the share of each player's moves that were not mistakes.
+func (c *Command) annotateGame(g *ptn.PTN) error {
+	p, e := g.InitialPosition()
+	if e != nil {
+		return fmt.Errorf("initial: %w", e)
+	}
+	player := c.makeAI(p)
+	player.Cfg.MultiPV = 2
+
+	type step struct {
+		op     *ptn.Move
+		before *tak.Position
+	}
+	var steps []step
+	for _, o := range g.Ops {
+		mo, ok := o.(*ptn.Move)
+		if !ok {
+			continue
+		}
+		if over, _ := p.GameOver(); over {
+			break
+		}
+		next, e := p.Move(mo.Move)
+		if e != nil {
+			return fmt.Errorf("%s: %w", ptn.FormatMove(mo.Move), e)
+		}
+		steps = append(steps, step{mo, p})
+		p = next
+	}
+
+	evals := make([]moveEval, len(steps)+1)
+	for i, s := range steps {
+		evals[i] = c.evaluateMove(player, s.before)
+	}
+	if over, _ := p.GameOver(); over {
+		evals[len(steps)] = terminalEval(p)
+	} else {
+		evals[len(steps)] = c.evaluateMove(player, p)
+	}
+
+	comments := make(map[*ptn.Move]*ptn.Comment)
+	var good, total [2]int
+	for i, s := range steps {
+		e := evals[i]
+		after := -evals[i+1].value
+		loss := clampValue(e.value) - clampValue(after)
+
+		var mark string
+		switch {
+		case loss >= blunderLoss:
+			mark = "??"
+		case loss >= mistakeLoss:
+			mark = "?"
+		case e.hasSecond && len(e.pv) > 0 && e.pv[0].Equal(s.op.Move) &&
+			clampValue(e.value)-clampValue(e.second) >= onlyMoveMargin:
+			mark = "!"
+		}
+		s.op.Modifiers = strings.Trim(s.op.Modifiers, "?!") + mark
+
+		who := 0
+		if s.before.ToMove() == tak.Black {
+			who = 1
+			after = -after
+		}
+		total[who]++
+		if loss < mistakeLoss {
+			good[who]++
+		}
+
+		text := "eval " + formatEval(after)
+		if len(e.pv) > 0 {
+			text += fmt.Sprintf(", best %s, pv %s", ptn.FormatMove(e.pv[0]), formatPV(e.pv))
+		}
+		comments[s.op] = &ptn.Comment{Comment: text}
+	}
+
+	ops := make([]ptn.Op, 0, len(g.Ops)+len(comments))
+	for _, o := range g.Ops {
+		ops = append(ops, o)
+		if mo, ok := o.(*ptn.Move); ok && comments[mo] != nil {
+			ops = append(ops, comments[mo])
+		}
+	}
+	g.Ops = ops
+
+	accuracy := fmt.Sprintf("white %s, black %s",
+		formatAccuracy(good[0], total[0]), formatAccuracy(good[1], total[1]))
+	setTag(g, "Accuracy", accuracy)
+	return nil
+}
+
+func formatAccuracy(good, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(good)/float64(total))
+}
+
+func setTag(g *ptn.PTN, name, value string) {
+	for i := range g.Tags {
+		if g.Tags[i].Name == name {
+			g.Tags[i].Value = value
+			return
+		}
+	}
+	g.Tags = append(g.Tags, ptn.Tag{Name: name, Value: value})
+}
@@ -0,0 +1,54 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nelhage/taktician/ptn"
+)
+
+func TestAnnotateGame(t *testing.T) {
+	g, err := ptn.ParsePTN(strings.NewReader(`[Size "4"]
+
+1. a1 d4
+2. d3 {hm} a2
+3. c3 a3
+4. b3? a4'
+R-0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Command{}
+	c.mmopt.Depth = 3
+	c.mmopt.Sort = true
+	if err := c.annotateGame(g); err != nil {
+		t.Fatal(err)
+	}
+
+	var moves []*ptn.Move
+	comments := 0
+	for _, o := range g.Ops {
+		switch o := o.(type) {
+		case *ptn.Move:
+			moves = append(moves, o)
+		case *ptn.Comment:
+			comments++
+		}
+	}
+	if comments != len(moves)+1 {
+		t.Errorf("%d comments for %d moves", comments, len(moves))
+	}
+	if m := moves[6]; m.Modifiers != "??" {
+		t.Errorf("%s%s: expected a blunder", ptn.FormatMove(m.Move), m.Modifiers)
+	}
+	if m := moves[7]; !strings.HasPrefix(m.Modifiers, "'") {
+		t.Errorf("%s%s: lost the tak mark", ptn.FormatMove(m.Move), m.Modifiers)
+	}
+	if acc := g.FindTag("Accuracy"); acc != "white 50.0%, black 100.0%" {
+		t.Errorf("accuracy=%q", acc)
+	}
+	if _, err := ptn.ParsePTN(strings.NewReader(g.Render())); err != nil {
+		t.Errorf("annotated PTN does not parse: %v", err)
+	}
+}
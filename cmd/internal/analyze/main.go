@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"time"
 
@@ -30,14 +31,22 @@ type Command struct {
 	black     bool
 	white     bool
 	variation string
+	annotate  string
 
 	/* Options which apply to all engines  */
 	timeLimit time.Duration
 
 	/* Options for the minimax engine  */
-	eval    bool
-	explain bool
-	mmopt   opt.Minimax
+	eval     bool
+	explain  bool
+	progress bool
+	multiPV  int
+	ttFile   string
+	mmopt    opt.Minimax
+
+	// players are the minimax AIs we have built since we last
+	// saved their tables to ttFile.
+	players []*ai.MinimaxAI
 
 	/* MCTS options */
 	dumpTree string
@@ -56,11 +65,21 @@ func (*Command) Synopsis() string { return "Evaluate a position from a PTN file"
 func (*Command) Usage() string {
 	return `analyze [options] FILE.ptn
 
-Evaluate a position from a PTN file using a configurable engine.
+Evaluate a position from a PTN file using a configurable engine. If FILE.ptn
+contains several games, each of them is analyzed in turn.
 
 By default evaluates the final position in the file; Use -move and -white/-black
 to select a different position, and -variation to play additional moves prior
 to analysis.
+
+-variation may instead name a side-line in the PTN, as a path of 1-based
+indexes: "2" is the second variation in the main line, and "2.1" the first
+variation inside that one. -move, -white/-black and -all then apply to that
+line.
+
+-annotate writes the game(s) back out as PTN, with the engine's evaluation,
+preferred move and principal variation after every move, "?", "??" and "!"
+marks for mistakes, blunders and only-moves, and an Accuracy tag.
 `
 }
 
@@ -75,11 +94,15 @@ func (c *Command) SetFlags(flags *flag.FlagSet) {
 	flags.BoolVar(&c.all, "all", false, "analyze all positions in the PTN")
 	flags.BoolVar(&c.black, "black", false, "only analyze black's move")
 	flags.BoolVar(&c.white, "white", false, "only analyze white's move")
-	flags.StringVar(&c.variation, "variation", "", "apply the listed moves after the given position")
+	flags.StringVar(&c.annotate, "annotate", "", "analyze every move with the minimax engine, and write the annotated PTN to PATH (- for stdout)")
+	flags.StringVar(&c.variation, "variation", "", "apply the listed moves after the given position, or follow the side-line at PATH")
 
 	flags.DurationVar(&c.timeLimit, "limit", time.Minute, "limit of how much time to use")
 	flags.BoolVar(&c.eval, "evaluate", false, "only show static evaluation")
 	flags.BoolVar(&c.explain, "explain", false, "explain scoring")
+	flags.BoolVar(&c.progress, "progress", false, "print the principal variation after each search depth")
+	flags.IntVar(&c.multiPV, "multipv", 0, "show the N best moves, with their own principal variations")
+	flags.StringVar(&c.ttFile, "tt-file", "", "load the transposition table from PATH, if it exists, and save it there when done")
 
 	c.mmopt.AddFlags(flags)
 
@@ -93,9 +116,12 @@ func (c *Command) SetFlags(flags *flag.FlagSet) {
 }
 
 func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	parsed, e := ptn.ParseFile(flag.Arg(0))
-	if e != nil {
-		log.Fatal("parse:", e)
+	games, errs := ptn.ParseArchive(flag.Arg(0))
+	for _, e := range errs {
+		if len(games) == 0 {
+			log.Fatal("parse:", e)
+		}
+		log.Print("parse:", e)
 	}
 	color := tak.NoColor
 	switch {
@@ -109,47 +135,129 @@ func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interfac
 		color = tak.White
 	}
 
+	var path []int
+	moves := c.variation
+	if moves != "" {
+		var e error
+		if path, e = ptn.ParseVariationPath(moves); e == nil {
+			moves = ""
+		}
+	}
+
+	if c.annotate != "" {
+		if c.monteCarlo || c.prove || c.dfpn {
+			log.Fatal("-annotate requires the minimax engine")
+		}
+		if c.variation != "" || c.move != 0 || color != tak.NoColor {
+			log.Fatal("-annotate analyzes the whole main line")
+		}
+		c.writeAnnotated(games)
+		return subcommands.ExitSuccess
+	}
+
+	for i, g := range games {
+		if len(games) > 1 {
+			fmt.Printf("Game %d: %s vs %s\n", i+1, g.FindTag("Player1"), g.FindTag("Player2"))
+		}
+		if e := c.analyzeGame(g, color, path, moves); e != nil {
+			if len(games) == 1 {
+				log.Fatal(e)
+			}
+			log.Printf("game %d: %v", i+1, e)
+		}
+		c.saveTable()
+	}
+	return subcommands.ExitSuccess
+}
+
+func (c *Command) writeAnnotated(games []*ptn.PTN) {
+	out := os.Stdout
+	if c.annotate != "-" {
+		f, e := os.Create(c.annotate)
+		if e != nil {
+			log.Fatal("-annotate: ", e)
+		}
+		defer f.Close()
+		out = f
+	}
+	w := ptn.NewWriter(out)
+	for i, g := range games {
+		e := c.annotateGame(g)
+		c.saveTable()
+		if e != nil {
+			log.Printf("game %d: %v", i+1, e)
+			continue
+		}
+		if e := w.Write(g); e != nil {
+			log.Fatal("-annotate: ", e)
+		}
+	}
+}
+
+// saveTable merges the tables of the AIs we have built into one,
+// and saves it to ttFile, so that the next AI starts with all of
+// their results.
+func (c *Command) saveTable() {
+	if c.ttFile == "" || len(c.players) == 0 {
+		return
+	}
+	t := c.players[0]
+	for _, p := range c.players[1:] {
+		if err := t.MergeTable(p); err != nil {
+			log.Printf("merge table: %v", err)
+		}
+	}
+	c.players = nil
+	if err := t.SaveTable(c.ttFile); err != nil {
+		log.Fatal("save table: ", err)
+	}
+}
+
+// analyzeGame analyzes the selected position(s) in one game,
+// following the side-line at `path`. If `moves` is nonempty, they
+// are played before analyzing.
+func (c *Command) analyzeGame(parsed *ptn.PTN, color tak.Color, path []int, moves string) error {
 	if !c.all {
-		p, e := parsed.PositionAtMove(c.move, color)
+		p, e := parsed.VariationPositionAtMove(path, c.move, color)
 		if e != nil {
-			log.Fatal("find move:", e)
+			return fmt.Errorf("find move: %w", e)
 		}
 
-		if c.variation != "" {
-			p, e = applyVariation(p, c.variation)
+		if moves != "" {
+			p, e = applyVariation(p, moves)
 			if e != nil {
-				log.Fatal("-variation:", e)
+				return fmt.Errorf("-variation: %w", e)
 			}
 		}
 
 		c.analyze(p)
-	} else {
-		p, e := parsed.InitialPosition()
-		if e != nil {
-			log.Fatal("initial:", e)
-		}
-		w, b := c.buildAnalysis(p), c.buildAnalysis(p)
-		it := parsed.Iterator()
-		for it.Next() {
-			p := it.Position()
-			m := it.PeekMove()
-			if over, _ := p.GameOver(); over {
-				break
-			}
-			switch {
-			case p.ToMove() == tak.White && color != tak.Black:
-				fmt.Printf("%d. %s\n", p.MoveNumber()/2+1, ptn.FormatMove(m))
-				c.analyzeWith(w, p)
-			case p.ToMove() == tak.Black && color != tak.White:
-				fmt.Printf("%d. ... %s\n", p.MoveNumber()/2+1, ptn.FormatMove(m))
-				c.analyzeWith(b, p)
-			}
+		return nil
+	}
+	p, e := parsed.InitialPosition()
+	if e != nil {
+		return fmt.Errorf("initial: %w", e)
+	}
+	w, b := c.buildAnalysis(p), c.buildAnalysis(p)
+	it := parsed.VariationIterator(path)
+	for it.Next() {
+		p := it.Position()
+		m := it.PeekMove()
+		if over, _ := p.GameOver(); over {
+			break
 		}
-		if e := it.Err(); e != nil {
-			log.Fatalf("%d: %v", it.PTNMove(), e)
+		switch {
+		case p.ToMove() == tak.White && color != tak.Black:
+			fmt.Printf("%d. %s\n", p.MoveNumber()/2+1, ptn.FormatMove(m))
+			c.analyzeWith(w, p)
+		case p.ToMove() == tak.Black && color != tak.White:
+			fmt.Printf("%d. ... %s\n", p.MoveNumber()/2+1, ptn.FormatMove(m))
+			c.analyzeWith(b, p)
 		}
 	}
-	return subcommands.ExitSuccess
+	if e := it.Err(); e != nil {
+		return fmt.Errorf("%d: %w", it.PTNMove(), e)
+	}
+	return nil
 }
 
 func applyVariation(p *tak.Position, variant string) (*tak.Position, error) {
@@ -169,7 +277,38 @@ func applyVariation(p *tak.Position, variant string) (*tak.Position, error) {
 
 func (c *Command) makeAI(p *tak.Position) *ai.MinimaxAI {
 	cfg := c.mmopt.BuildConfig(p.Size())
-	return ai.NewMinimax(cfg)
+	cfg.MultiPV = c.multiPV
+	player := ai.NewMinimax(cfg)
+	if c.ttFile != "" {
+		err := player.LoadTable(c.ttFile, p.Config())
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("load table: %v; starting with an empty table", err)
+		}
+	}
+	if c.ttFile != "" {
+		c.players = append(c.players, player)
+	}
+	if c.progress {
+		player.Cfg.OnIteration = func(pv []tak.Move, v int64, st ai.Stats) {
+			lines := player.Lines()
+			if c.multiPV < 2 {
+				lines = []ai.Line{{PV: pv, Value: v}}
+			}
+			for _, l := range lines {
+				fmt.Printf(" depth=%d value=%d nodes=%d time=%s pv=%s\n",
+					st.Depth, l.Value, st.Visited, st.Elapsed, formatPV(l.PV))
+			}
+		}
+	}
+	return player
+}
+
+func formatPV(pv []tak.Move) string {
+	var ms []string
+	for _, m := range pv {
+		ms = append(ms, ptn.FormatMove(m))
+	}
+	return strings.Join(ms, " ")
 }
 
 func (c *Command) buildAnalysis(p *tak.Position) Analyzer {
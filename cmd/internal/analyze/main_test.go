@@ -0,0 +1,53 @@
+package analyze
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nelhage/taktician/ai"
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+func TestAnalyzeAllTable(t *testing.T) {
+	g, err := ptn.ParsePTN(strings.NewReader(`[Size "4"]
+
+1. a1 d4
+2. d3 a2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Command{all: true, quiet: true}
+	c.mmopt.Depth = 2
+	c.mmopt.Sort = true
+	c.ttFile = filepath.Join(t.TempDir(), "table")
+	if err := c.analyzeGame(g, tak.NoColor, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.players) != 2 || c.players[0] == c.players[1] {
+		t.Fatalf("built %d players; want one per color", len(c.players))
+	}
+	c.saveTable()
+
+	// Both colors' results must have been saved.
+	for _, m := range []int{1, 2} {
+		for _, color := range []tak.Color{tak.White, tak.Black} {
+			p, err := g.PositionAtMove(m, color)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := c.mmopt.BuildConfig(4)
+			cfg.Depth = 1
+			player := ai.NewMinimax(cfg)
+			if err := player.LoadTable(c.ttFile, p.Config()); err != nil {
+				t.Fatal("load: ", err)
+			}
+			if _, _, st := player.Analyze(context.Background(), p); st.Depth != 2 {
+				t.Errorf("%d. %s: depth=%d, not from the saved table", m, color, st.Depth)
+			}
+		}
+	}
+}
@@ -144,10 +144,10 @@ func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interfac
 				continue
 			}
 			pos := g.positions[idx]
-			if _, ok := seen[pos.Hash()]; ok {
+			if _, ok := seen[pos.CanonicalHash()]; ok {
 				continue
 			}
-			seen[pos.Hash()] = struct{}{}
+			seen[pos.CanonicalHash()] = struct{}{}
 			select {
 			case positions <- pos:
 			case <-ctx.Done():
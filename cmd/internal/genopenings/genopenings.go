@@ -50,25 +50,17 @@ func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interfac
 generate:
 	for len(positions) < c.n {
 		pos := c.generate(init, c.depth)
-		if c.allowSymmetries {
-			if got, ok := seen[pos.Hash()]; ok {
-				if !got.Equal(pos) {
-					log.Fatalf("hash collision seen=%q new=%q", ptn.FormatTPS(got), ptn.FormatTPS(pos))
-				}
-				continue generate
-			}
-		} else {
-			syms, _ := symmetry.Symmetries(pos)
-			for _, sym := range syms {
-				if got, ok := seen[sym.P.Hash()]; ok {
-					if !got.Equal(sym.P) {
-						log.Fatalf("hash collision seen=%q new=%q", ptn.FormatTPS(got), ptn.FormatTPS(sym.P))
-					}
-					continue generate
-				}
+		key := pos.Hash()
+		if !c.allowSymmetries {
+			key = pos.CanonicalHash()
+		}
+		if got, ok := seen[key]; ok {
+			if !c.same(got, pos) {
+				log.Fatalf("hash collision seen=%q new=%q", ptn.FormatTPS(got), ptn.FormatTPS(pos))
 			}
+			continue generate
 		}
-		seen[pos.Hash()] = pos
+		seen[key] = pos
 		positions = append(positions, pos)
 	}
 
@@ -78,6 +70,24 @@ generate:
 	return subcommands.ExitSuccess
 }
 
+// same reports whether `p` and `q` are the same opening: equal, or,
+// unless we allow symmetries, symmetries of each other.
+func (c *Command) same(p, q *tak.Position) bool {
+	if c.allowSymmetries {
+		return p.Equal(q)
+	}
+	syms, err := symmetry.Symmetries(p)
+	if err != nil {
+		log.Fatalf("symmetries: %v", err)
+	}
+	for _, sym := range syms {
+		if sym.P.Equal(q) {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Command) generate(pos *tak.Position, depth int) *tak.Position {
 	var buf [100]tak.Move
 	for d := 0; d < depth; d++ {
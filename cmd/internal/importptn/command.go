@@ -155,6 +155,9 @@ func formatTags(g *gameRow) []ptn.Tag {
 		}
 		tags = append(tags, ptn.Tag{"Clock", timestr})
 	}
+	if g.Komi != 0 {
+		tags = append(tags, ptn.Tag{Name: "Komi", Value: ptn.FormatKomi(g.Komi)})
+	}
 	return tags
 }
 
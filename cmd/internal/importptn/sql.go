@@ -42,6 +42,8 @@ type gameRow struct {
 
 	TimerTime int `db:"timertime"`
 	TimerInc  int `db:"timerinc"`
+
+	Komi int `db:"komi"`
 }
 
 type ptnRow struct {
@@ -50,7 +52,7 @@ type ptnRow struct {
 }
 
 const selectTODO = `
-SELECT g.id, g.date, g.size, g.player_white, g.player_black, g.notation, g.result, g.timertime, g.timerinc
+SELECT g.id, g.date, g.size, g.player_white, g.player_black, g.notation, g.result, g.timertime, g.timerinc, g.komi
 FROM games g LEFT OUTER JOIN ptns p
   ON (g.id = p.id)
 WHERE p.id is NULL
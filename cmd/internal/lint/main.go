@@ -0,0 +1,79 @@
+package lint
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/subcommands"
+	"github.com/nelhage/taktician/ptn"
+)
+
+type Command struct{}
+
+func (*Command) Name() string     { return "lint" }
+func (*Command) Synopsis() string { return "Check PTN files for errors" }
+func (*Command) Usage() string {
+	return `lint FILE.ptn...
+
+Check every game in the listed PTN files for malformed or missing tags, a
+Result that does not match the game, out-of-sequence move numbers, TPS
+positions with more pieces than the reserves hold, and illegal moves.
+
+Exits with status 1 if any problems are found.
+`
+}
+
+func (c *Command) SetFlags(flags *flag.FlagSet) {
+}
+
+func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if len(flag.Args()) == 0 {
+		flag.Usage()
+		return subcommands.ExitUsageError
+	}
+	status := subcommands.ExitSuccess
+	for _, path := range flag.Args() {
+		n, err := lintFile(os.Stdout, path)
+		if err != nil {
+			fmt.Fprintf(os.Stdout, "%s: %v\n", path, err)
+			status = subcommands.ExitFailure
+		}
+		if n > 0 {
+			status = subcommands.ExitFailure
+		}
+	}
+	return status
+}
+
+// lintFile writes the problems with each game in `path` to `out`, and
+// returns how many it found.
+func lintFile(out io.Writer, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r := ptn.NewReader(f)
+	n := 0
+	for {
+		g, err := r.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if pe, ok := err.(*ptn.ParseError); ok {
+			fmt.Fprintf(out, "%s:%d: %v\n", path, pe.Line, pe.Err)
+			n++
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		for _, d := range ptn.Validate(g) {
+			fmt.Fprintf(out, "%s:%d:%d: %s\n", path, d.Line+r.Line()-1, d.Col, d.Message)
+			n++
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/google/subcommands"
@@ -28,7 +29,11 @@ type Command struct {
 func (*Command) Name() string     { return "openings" }
 func (*Command) Synopsis() string { return "Analyze openings from the playtak DB" }
 func (*Command) Usage() string {
-	return `openings [flags] GAMES.db
+	return `openings [flags] GAMES.db|GAMES.ptn
+
+Build a tree of common openings, from the playtak DB or from a PTN
+file containing any number of games. Ratings are not known for games
+from a PTN file, so -rating does not apply to them.
 `
 }
 
@@ -40,9 +45,52 @@ func (c *Command) SetFlags(flags *flag.FlagSet) {
 }
 
 func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	repo, e := logs.Open(flag.Arg(0))
+	tree := &tree{}
+	if strings.HasSuffix(flag.Arg(0), ".ptn") {
+		games, errs := ptn.ParseArchive(flag.Arg(0))
+		for _, e := range errs {
+			log.Print(e)
+		}
+		for i, g := range games {
+			if g.FindTag("Size") != strconv.Itoa(c.size) {
+				continue
+			}
+			c.addGame(tree, strconv.Itoa(i+1), g)
+		}
+	} else {
+		c.readDB(tree, flag.Arg(0))
+	}
+
+	bs, _ := json.Marshal(tree)
+	ioutil.WriteFile("gametree.json", bs, 0644)
+	f, e := os.Create("gametree.dot")
+	if e != nil {
+		log.Fatal("create: ", e)
+	}
+	defer f.Close()
+	c.writeTree(f, tree)
+	fmt.Printf("Common openings:\n")
+	c.printLines(tree)
+
+	line := c.minmax(tree)
+	fmt.Printf("Best line:\n")
+	for i, t := range line {
+		dots := ""
+		if i%2 == 1 {
+			dots = ".. "
+		}
+		fmt.Printf("%d. %s%s %d-%d %0.0f%%\n",
+			i/2+1, dots, t.Move, t.White, t.Black,
+			100*float64(t.White)/float64(t.Count))
+	}
+
+	return subcommands.ExitSuccess
+}
+
+func (c *Command) readDB(tree *tree, path string) {
+	repo, e := logs.Open(path)
 	if e != nil {
-		log.Fatalf("open %s: %v", flag.Arg(0), e)
+		log.Fatalf("open %s: %v", path, e)
 	}
 	defer repo.Close()
 	sql := repo.DB()
@@ -65,8 +113,6 @@ WHERE r1.name = g.player_white
 	}
 	defer rows.Close()
 
-	tree := &tree{}
-
 	for rows.Next() {
 		var id int
 		var notation string
@@ -80,58 +126,41 @@ WHERE r1.name = g.player_white
 			log.Printf("parse %d: %v", id, e)
 			continue
 		}
+		c.addGame(tree, strconv.Itoa(id), g)
+	}
+}
 
-		p, e := g.PositionAtMove(0, tak.NoColor)
-		if e != nil {
-			log.Printf("parse %d: %v", id, e)
-			continue
-		}
+// addGame adds the opening of `g` to the tree. `id` identifies the
+// game in error messages.
+func (c *Command) addGame(tree *tree, id string, g *ptn.PTN) {
+	p, e := g.PositionAtMove(0, tak.NoColor)
+	if e != nil {
+		log.Printf("parse %s: %v", id, e)
+		return
+	}
 
-		var ms []tak.Move
-		for _, o := range g.Ops {
-			if m, ok := o.(*ptn.Move); ok {
-				ms = append(ms, m.Move)
-				if len(ms) >= 10 {
-					break
-				}
+	var ms []tak.Move
+	for _, o := range g.Ops {
+		if m, ok := o.(*ptn.Move); ok {
+			ms = append(ms, m.Move)
+			if len(ms) >= 10 {
+				break
 			}
 		}
-		ms, e = symmetry.Canonical(p.Size(), ms)
-		if e != nil {
-			log.Printf("%d: %v", id, e)
-			continue
-		}
-
-		if len(ms) > c.maxDepth {
-			ms = ms[:c.maxDepth]
-		}
-
-		result := ptn.Result{Result: g.FindTag("Result")}
-
-		insertTree(tree, ms, result.Winner())
+	}
+	ms, e = symmetry.Canonical(p.Size(), ms)
+	if e != nil {
+		log.Printf("%s: %v", id, e)
+		return
 	}
 
-	bs, _ := json.Marshal(tree)
-	ioutil.WriteFile("gametree.json", bs, 0644)
-	f, e := os.Create("gametree.dot")
-	defer f.Close()
-	c.writeTree(f, tree)
-	fmt.Printf("Common openings:\n")
-	c.printLines(tree)
-
-	line := c.minmax(tree)
-	fmt.Printf("Best line:\n")
-	for i, t := range line {
-		dots := ""
-		if i%2 == 1 {
-			dots = ".. "
-		}
-		fmt.Printf("%d. %s%s %d-%d %0.0f%%\n",
-			i/2+1, dots, t.Move, t.White, t.Black,
-			100*float64(t.White)/float64(t.Count))
+	if len(ms) > c.maxDepth {
+		ms = ms[:c.maxDepth]
 	}
 
-	return subcommands.ExitSuccess
+	result := ptn.Result{Result: g.FindTag("Result")}
+
+	insertTree(tree, ms, result.Winner())
 }
 
 type tree struct {
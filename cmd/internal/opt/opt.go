@@ -24,6 +24,7 @@ type Minimax struct {
 	ModWeights   string
 	LogCuts      string
 	Symmetry     bool
+	Threads      int
 }
 
 func (o *Minimax) AddFlags(flags *flag.FlagSet) {
@@ -42,6 +43,7 @@ func (o *Minimax) AddFlags(flags *flag.FlagSet) {
 	flags.StringVar(&o.ModWeights, "mod-weights", "", "JSON-encoded evaluation weights applied on top of defaults")
 	flags.StringVar(&o.LogCuts, "log-cuts", "", "log all cuts")
 	flags.BoolVar(&o.Symmetry, "symmetry", false, "ignore symmetries")
+	flags.IntVar(&o.Threads, "threads", 1, "number of search threads")
 }
 
 func (o *Minimax) BuildConfig(size int) ai.MinimaxConfig {
@@ -77,8 +79,9 @@ func (o *Minimax) BuildConfig(size int) ai.MinimaxConfig {
 
 		CutLog:        o.LogCuts,
 		DedupSymmetry: o.Symmetry,
+		Threads:       o.Threads,
 
-		Evaluate: ai.MakeEvaluator(size, &w),
+		Weights: &w,
 	}
 	if o.Precise {
 		cfg.MakePrecise()
@@ -0,0 +1,135 @@
+package perft
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+type Command struct {
+	size    int
+	depth   int
+	threads int
+	divide  bool
+}
+
+func (*Command) Name() string     { return "perft" }
+func (*Command) Synopsis() string { return "Count the move sequences from a position" }
+func (*Command) Usage() string {
+	return `perft [flags] [TPS]
+
+Count the legal move sequences of length -depth from the position given
+in TPS, or from the start of a game of size -size, to check the move
+generator against other engines. A finished game has no moves.
+
+With -divide, also print the count after each legal first move.
+`
+}
+
+func (c *Command) SetFlags(flags *flag.FlagSet) {
+	flags.IntVar(&c.size, "size", 5, "board size, if no TPS is given")
+	flags.IntVar(&c.depth, "depth", 3, "number of moves")
+	flags.IntVar(&c.threads, "threads", runtime.NumCPU(), "number of threads")
+	flags.BoolVar(&c.divide, "divide", false, "print the count after each first move")
+}
+
+func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	var p *tak.Position
+	if flag.NArg() > 0 {
+		var err error
+		p, err = ptn.ParseTPS(strings.Join(flag.Args(), " "))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "tps: %v\n", err)
+			return subcommands.ExitUsageError
+		}
+	} else {
+		if c.size < 3 || c.size > tak.MaxSize {
+			fmt.Fprintf(os.Stderr, "bad size: %d\n", c.size)
+			return subcommands.ExitUsageError
+		}
+		p = tak.New(tak.Config{Size: c.size})
+	}
+
+	start := time.Now()
+	counts := c.divideCounts(p)
+	elapsed := time.Since(start)
+
+	var total uint64
+	for _, d := range counts {
+		total += d.count
+	}
+	if c.depth <= 0 {
+		total = 1
+	}
+	if c.divide {
+		for _, d := range counts {
+			fmt.Printf("%s %d\n", d.move, d.count)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("perft(%d) = %d\n", c.depth, total)
+	fmt.Fprintf(os.Stderr, "%d positions in %s (%.0f/s)\n",
+		total, elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds())
+	return subcommands.ExitSuccess
+}
+
+type division struct {
+	move  string
+	count uint64
+}
+
+// divideCounts returns the perft count after each legal move from
+// `p`, sorted by move, sharing the moves out among c.threads
+// workers.
+func (c *Command) divideCounts(p *tak.Position) []division {
+	if c.depth <= 0 {
+		return nil
+	}
+	if over, _ := p.GameOver(); over {
+		return nil
+	}
+	var children []*tak.Position
+	var out []division
+	for _, m := range p.AllMoves(nil) {
+		child, err := p.Move(m)
+		if err != nil {
+			continue
+		}
+		children = append(children, child)
+		out = append(out, division{move: ptn.FormatMove(m)})
+	}
+
+	threads := c.threads
+	if threads < 1 {
+		threads = 1
+	}
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range work {
+				out[j].count = children[j].Perft(c.depth - 1)
+			}
+		}()
+	}
+	for j := range children {
+		work <- j
+	}
+	close(work)
+	wg.Wait()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].move < out[j].move })
+	return out
+}
@@ -25,6 +25,7 @@ type Command struct {
 	white string
 	black string
 	size  int
+	komi  string
 	debug int
 	limit time.Duration
 	out   string
@@ -45,6 +46,7 @@ func (c *Command) SetFlags(flags *flag.FlagSet) {
 	flags.StringVar(&c.white, "white", "human", "white player")
 	flags.StringVar(&c.black, "black", "human", "white player")
 	flags.IntVar(&c.size, "size", 5, "game size")
+	flags.StringVar(&c.komi, "komi", "", "komi, in flats (e.g. 2.5)")
 	flags.IntVar(&c.debug, "debug", 0, "debug level")
 	flags.DurationVar(&c.limit, "limit", time.Minute, "ai time limit")
 	flags.StringVar(&c.out, "out", "", "write ptn to file")
@@ -55,7 +57,7 @@ func (c *Command) SetFlags(flags *flag.FlagSet) {
 func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
 	in := bufio.NewReader(os.Stdin)
 	st := &cli.CLI{
-		Config: tak.Config{Size: c.size},
+		Config: c.gameConfig(),
 		Out:    os.Stdout,
 		White:  c.parsePlayer(in, c.white),
 		Black:  c.parsePlayer(in, c.black),
@@ -69,6 +71,9 @@ func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interfac
 			{Name: "Player1", Value: c.white},
 			{Name: "Player2", Value: c.black},
 		}
+		if c.komi != "" {
+			p.Tags = append(p.Tags, ptn.Tag{Name: "Komi", Value: c.komi})
+		}
 		p.AddMoves(st.Moves())
 		ioutil.WriteFile(c.out, []byte(p.Render()), 0644)
 	}
@@ -76,6 +81,18 @@ func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interfac
 	return subcommands.ExitSuccess
 }
 
+func (c *Command) gameConfig() tak.Config {
+	cfg := tak.Config{Size: c.size}
+	if c.komi != "" {
+		var err error
+		cfg.Komi, err = ptn.ParseKomi(c.komi)
+		if err != nil {
+			log.Fatalf("-komi: %v", err)
+		}
+	}
+	return cfg
+}
+
 func glyphs(unicode bool) *cli.Glyphs {
 	if unicode {
 		return &cli.UnicodeGlyphs
@@ -150,7 +167,7 @@ func (c *Command) parsePlayer(in *bufio.Reader, s string) cli.Player {
 		if err != nil {
 			log.Fatalf("%s: %v", s, err)
 		}
-		player, err := client.NewGame(c.size)
+		player, err := client.NewGame(c.gameConfig())
 		if err != nil {
 			log.Fatalf("%s: %v", s, err)
 		}
@@ -253,6 +253,7 @@ func (f *Friendly) AIConfig() ai.MinimaxConfig {
 		NoSort:   !f.cmd.sort,
 		TableMem: f.cmd.tableMem,
 		MultiCut: f.cmd.multicut,
+		Threads:  f.cmd.threads,
 	}
 	cfg.Depth, cfg.Evaluate = f.levelSettings(f.g.Size, f.level)
 
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/google/subcommands"
+	"github.com/nelhage/taktician/experience"
 	"github.com/nelhage/taktician/playtak"
 	"github.com/nelhage/taktician/playtak/bot"
 )
@@ -38,10 +39,15 @@ type Command struct {
 	limit           time.Duration
 	sort            bool
 	tableMem        int64
+	threads         int
 	useOpponentTime bool
+	ponder          bool
 
 	book bool
 
+	experienceFile string
+	experience     *experience.Store
+
 	debugClient bool
 }
 
@@ -74,9 +80,12 @@ func (c *Command) SetFlags(flags *flag.FlagSet) {
 	flags.DurationVar(&c.limit, "limit", time.Minute, "time limit per move")
 	flags.BoolVar(&c.sort, "sort", true, "sort moves via history heuristic")
 	flags.Int64Var(&c.tableMem, "table-mem", 0, "set table size")
+	flags.IntVar(&c.threads, "threads", 1, "number of search threads")
 	flags.BoolVar(&c.useOpponentTime, "use-opponent-time", true, "think on opponent's time")
+	flags.BoolVar(&c.ponder, "ponder", false, "on opponent's time, search our reply to their expected move")
 
 	flags.BoolVar(&c.book, "book", true, "use built-in opening book")
+	flags.StringVar(&c.experienceFile, "experience", "", "learn from previous games using the experience file at PATH")
 
 	flags.BoolVar(&c.debugClient, "debug-client", false, "log debug output for playtak connection")
 }
@@ -94,6 +103,13 @@ func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interfac
 		log.Printf("Fatal: Must specify -pass= or $TAKTICIAN_PLAYTAK_PASSWORD")
 		return subcommands.ExitFailure
 	}
+	if c.experienceFile != "" {
+		var err error
+		c.experience, err = experience.Load(c.experienceFile)
+		if err != nil {
+			log.Fatalf("load experience: %v", err)
+		}
+	}
 	var fpaRuleset FPARule
 	if c.fpa != "" {
 		c.friendly = true
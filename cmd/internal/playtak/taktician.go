@@ -10,7 +10,9 @@ import (
 	"github.com/nelhage/taktician/ai"
 	"github.com/nelhage/taktician/playtak"
 	"github.com/nelhage/taktician/playtak/bot"
+	"github.com/nelhage/taktician/ptn"
 	"github.com/nelhage/taktician/tak"
+	"github.com/nelhage/taktician/timemanager"
 )
 
 type Taktician struct {
@@ -19,21 +21,48 @@ type Taktician struct {
 	g      *bot.Game
 	client *playtak.Commands
 	ai     ai.TakPlayer
+	mm     *ai.MinimaxAI
+
+	// pv, value and depth are the results of the latest search
+	// iteration.
+	pv    []tak.Move
+	value int64
+	depth int
+
+	// If the opponent is to move in the position with hash
+	// ponderFrom, we expect them to play `expect`, and ponder
+	// the position with hash ponderHash.
+	expect     *tak.Move
+	ponderFrom uint64
+	ponderHash uint64
 }
 
 func (t *Taktician) NewGame(g *bot.Game) {
 	t.g = g
-	t.ai = t.cmd.wrapWithBook(
-		g.Size,
-		ai.NewMinimax(ai.MinimaxConfig{
-			Size:  g.Size,
-			Depth: t.cmd.depth,
-			Debug: t.cmd.debug,
-
-			NoSort:   !t.cmd.sort,
-			TableMem: t.cmd.tableMem,
-			MultiCut: t.cmd.multicut,
-		}))
+	t.pv = nil
+	t.expect = nil
+	t.ponderHash = 0
+	cfg := ai.MinimaxConfig{
+		Size:  g.Size,
+		Depth: t.cmd.depth,
+		Debug: t.cmd.debug,
+
+		NoSort:   !t.cmd.sort,
+		TableMem: t.cmd.tableMem,
+		MultiCut: t.cmd.multicut,
+		Threads:  t.cmd.threads,
+
+		OnIteration: func(pv []tak.Move, v int64, st ai.Stats) {
+			t.pv = append(t.pv[:0], pv...)
+			t.value = v
+			t.depth = st.Depth
+		},
+	}
+	if t.cmd.experience != nil {
+		cfg.Experience = t.cmd.experience
+	}
+	t.mm = ai.NewMinimax(cfg)
+	t.ai = t.cmd.wrapWithBook(g.Size, t.mm)
 }
 
 func (t *Taktician) GetMove(
@@ -42,26 +71,92 @@ func (t *Taktician) GetMove(
 	mine, theirs time.Duration) tak.Move {
 	if p.ToMove() == t.g.Color {
 		var cancel context.CancelFunc
-		timeout := t.timeBound(mine)
-		if p.MoveNumber() < 2 {
-			timeout = 20 * time.Second
-		}
-		ctx, cancel = context.WithTimeout(ctx, timeout)
+		ctx, cancel = timemanager.WithBudget(ctx, t.budget(p, mine))
 		defer cancel()
-	} else if !t.cmd.useOpponentTime {
+		if t.ponderHash != 0 && p.Hash() == t.ponderHash {
+			log.Printf("ponderhit game-id=%s ply=%d", t.g.ID, p.MoveNumber())
+		}
+		return t.getMove(ctx, p)
+	}
+	if t.cmd.ponder && t.ponder(ctx, p) {
+		return tak.Move{}
+	}
+	if !t.cmd.useOpponentTime {
 		return tak.Move{}
 	}
 	return t.ai.GetMove(ctx, p)
 }
 
-func (t *Taktician) timeBound(remaining time.Duration) time.Duration {
-	if t.g.Size == 4 {
-		return t.cmd.limit
+// getMove picks our move in `p`, and remembers the reply our
+// search expects for pondering.
+func (t *Taktician) getMove(ctx context.Context, p *tak.Position) tak.Move {
+	t.pv = t.pv[:0]
+	t.expect = nil
+	m := t.ai.GetMove(ctx, p)
+	if exp := t.cmd.experience; exp != nil && len(t.pv) > 0 && t.pv[0].Equal(m) {
+		if err := exp.RecordSearch(p, m, t.value, t.depth); err != nil {
+			log.Printf("experience: %v", err)
+		}
+	}
+	if len(t.pv) > 1 && t.pv[0].Equal(m) {
+		if next, err := p.Move(m); err == nil {
+			expect := t.pv[1]
+			t.expect = &expect
+			t.ponderFrom = next.Hash()
+		}
 	}
-	return t.cmd.limit
+	return m
+}
+
+// ponder searches our reply to the opponent's expected move in `p`
+// until `ctx` is canceled, leaving the results in the
+// transposition table for when they play it. It returns false if we
+// have no expectation for `p`.
+func (t *Taktician) ponder(ctx context.Context, p *tak.Position) bool {
+	if t.expect == nil || p.Hash() != t.ponderFrom {
+		return false
+	}
+	next, err := p.Move(*t.expect)
+	if err != nil {
+		return false
+	}
+	t.ponderHash = next.Hash()
+	if t.cmd.debug > 0 {
+		log.Printf("ponder game-id=%s ply=%d expect=%s",
+			t.g.ID, p.MoveNumber(), ptn.FormatMove(*t.expect))
+	}
+	t.mm.Analyze(ctx, next)
+	return true
+}
+
+// budget allocates time for our move in `p`, given `remaining` on
+// our clock. We never spend more than -limit on a move.
+func (t *Taktician) budget(p *tak.Position, remaining time.Duration) timemanager.Budget {
+	clock := timemanager.Clock{
+		Remaining: remaining,
+		MoveTime:  t.cmd.limit,
+	}
+	if p.MoveNumber() < 2 {
+		clock = timemanager.Clock{MoveTime: 20 * time.Second}
+	}
+	b := timemanager.Allocate(clock, p)
+	if t.cmd.debug > 1 {
+		log.Printf("budget game-id=%s ply=%d remaining=%s soft=%s hard=%s",
+			t.g.ID, p.MoveNumber(), remaining, b.Soft, b.Hard)
+	}
+	return b
 }
 
 func (t *Taktician) GameOver() {
+	if exp := t.cmd.experience; exp != nil && t.g.Result != "" {
+		result := ptn.Result{Result: t.g.Result}
+		if err := exp.RecordGame(t.g.Positions, t.g.Moves, result.Winner()); err != nil {
+			log.Printf("experience: %v", err)
+		}
+		if err := exp.Save(t.cmd.experienceFile); err != nil {
+			log.Printf("save experience: %v", err)
+		}
+	}
 	t.ai = nil
 	t.g = nil
 }
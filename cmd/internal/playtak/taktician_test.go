@@ -0,0 +1,51 @@
+package playtak
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nelhage/taktician/playtak"
+	"github.com/nelhage/taktician/playtak/bot"
+	"github.com/nelhage/taktician/tak"
+	"github.com/nelhage/taktician/taktest"
+)
+
+func TestPonder(t *testing.T) {
+	player := &Taktician{
+		cmd: &Command{
+			depth:  3,
+			limit:  time.Minute,
+			sort:   true,
+			ponder: true,
+		},
+		client: &playtak.Commands{Client: &mockClient{}},
+	}
+	game := &bot.Game{
+		ID:    "123",
+		Color: tak.White,
+		Size:  5,
+	}
+	player.NewGame(game)
+
+	p := taktest.Position(5, "a1 e5 e4 d5")
+	m := player.GetMove(context.Background(), p, time.Minute, time.Minute)
+	if player.expect == nil {
+		t.Fatal("no expected reply")
+	}
+	next, err := p.Move(m)
+	if err != nil {
+		t.Fatal("illegal move: ", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	player.GetMove(ctx, next, time.Minute, time.Minute)
+	expected, err := next.Move(*player.expect)
+	if err != nil {
+		t.Fatal("illegal expectation: ", err)
+	}
+	if player.ponderHash != expected.Hash() {
+		t.Fatal("did not ponder the expected reply")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"os"
 	"path"
 	"runtime/pprof"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -23,11 +24,14 @@ import (
 )
 
 type Command struct {
-	size int
-	zero bool
-	p1   string
-	p2   string
-	seed int64
+	size  int
+	komi  string
+	flats int
+	caps  int
+	zero  bool
+	p1    string
+	p2    string
+	seed  int64
 
 	games  int
 	cutoff int
@@ -62,6 +66,9 @@ func (*Command) Usage() string {
 
 func (c *Command) SetFlags(flags *flag.FlagSet) {
 	flags.IntVar(&c.size, "size", 5, "board size")
+	flags.StringVar(&c.komi, "komi", "", "komi, in flats (e.g. 2.5)")
+	flags.IntVar(&c.flats, "flats", 0, "stones in each reserve (default: standard for the size)")
+	flags.IntVar(&c.caps, "caps", -1, "capstones in each reserve (default: standard for the size)")
 	flags.StringVar(&c.p1, "p1", "taktician tei", "player1 TIE driver")
 	flags.StringVar(&c.p2, "p2", "taktician tei", "player2 TIE driver")
 
@@ -69,7 +76,7 @@ func (c *Command) SetFlags(flags *flag.FlagSet) {
 	flags.IntVar(&c.games, "games", 1, "number of games to play per opening/color")
 	flags.IntVar(&c.cutoff, "cutoff", 80, "cut games off after how many plies")
 	flags.BoolVar(&c.swap, "swap", true, "swap colors each game")
-	flags.StringVar(&c.prefix, "prefix", "", "ptn file to start games at the end of")
+	flags.StringVar(&c.prefix, "prefix", "", "ptn file to start games at the end of; if it holds several games, each is used as an opening")
 	flags.StringVar(&c.openings, "openings", "", "File of openings, 1/line in TPS")
 	flags.IntVar(&c.debug, "debug", 0, "debug level")
 	flags.DurationVar(&c.limit, "limit", 0, "amount of time to search each move")
@@ -83,7 +90,7 @@ func (c *Command) SetFlags(flags *flag.FlagSet) {
 	flags.BoolVar(&c.merge, "merge", false, "merge+analyze multiple summary files")
 }
 
-func readOpenings(path string) ([]*tak.Position, error) {
+func readOpenings(path string, cfg tak.Config) ([]*tak.Position, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -93,7 +100,7 @@ func readOpenings(path string) ([]*tak.Position, error) {
 	r := bufio.NewScanner(f)
 	for r.Scan() {
 		line := r.Text()
-		pos, err := ptn.ParseTPS(line)
+		pos, err := ptn.ParseTPSWithConfig(line, cfg)
 		if err != nil {
 			return nil, fmt.Errorf("parse TPS: %q: %w", line, err)
 		}
@@ -157,27 +164,50 @@ func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interfac
 		c.seed = time.Now().Unix()
 	}
 
+	gameCfg := tak.Config{Size: c.size}
+	if c.komi != "" {
+		var err error
+		gameCfg.Komi, err = ptn.ParseKomi(c.komi)
+		if err != nil {
+			log.Fatalf("-komi: %v", err)
+		}
+	}
+	if c.flats != 0 {
+		if c.flats < 0 || c.flats > tak.MaxPieces {
+			log.Fatalf("-flats: bad reserve: %d", c.flats)
+		}
+		gameCfg.Pieces = c.flats
+	}
+	switch {
+	case c.caps == 0:
+		gameCfg.Capstones = tak.NoCapstones
+	case c.caps > 0:
+		gameCfg.Capstones = c.caps
+	}
+
 	var openings []*tak.Position
 	if c.prefix != "" {
-		pt, e := ptn.ParseFile(c.prefix)
-		if e != nil {
+		games, errs := ptn.ParseArchive(c.prefix)
+		for _, e := range errs {
 			log.Fatalf("Parse PTN: %v", e)
 		}
-		p, e := pt.PositionAtMove(0, tak.NoColor)
-		if e != nil {
-			log.Fatalf("PTN: %v", e)
+		for _, pt := range games {
+			p, e := pt.PositionAtMove(0, tak.NoColor)
+			if e != nil {
+				log.Fatalf("PTN: %v", e)
+			}
+			openings = append(openings, p)
 		}
-		openings = []*tak.Position{p}
 	}
 	if c.openings != "" {
 		var e error
-		openings, e = readOpenings(c.openings)
+		openings, e = readOpenings(c.openings, gameCfg)
 		if e != nil {
 			log.Fatalf("-openings: %v", e)
 		}
 	}
 	if len(openings) == 0 {
-		openings = []*tak.Position{tak.New(tak.Config{Size: c.size})}
+		openings = []*tak.Position{tak.New(gameCfg)}
 	}
 
 	cfg := &Config{
@@ -285,6 +315,17 @@ func writeGame(d string, r *Result) {
 		{Name: "Player1", Value: joinCmd(white)},
 		{Name: "Player2", Value: joinCmd(black)},
 	}
+	if komi := r.Position.Config().Komi; komi != 0 {
+		p.Tags = append(p.Tags, ptn.Tag{Name: "Komi", Value: ptn.FormatKomi(komi)})
+	}
+	pieces, caps := r.Position.Config().Reserves()
+	defPieces, defCaps := tak.DefaultReserves(r.Position.Size())
+	if pieces != defPieces {
+		p.Tags = append(p.Tags, ptn.Tag{Name: "Flats", Value: strconv.Itoa(pieces)})
+	}
+	if caps != defCaps {
+		p.Tags = append(p.Tags, ptn.Tag{Name: "Caps", Value: strconv.Itoa(caps)})
+	}
 	var result ptn.Result
 	if over, _ := r.Position.GameOver(); over {
 		result = ptn.ResultFromGame(r.Position)
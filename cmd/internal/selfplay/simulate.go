@@ -11,6 +11,7 @@ import (
 	"github.com/nelhage/taktician/ptn"
 	"github.com/nelhage/taktician/tak"
 	"github.com/nelhage/taktician/tei"
+	"github.com/nelhage/taktician/timemanager"
 )
 
 type Config struct {
@@ -205,11 +206,11 @@ func worker(c *Config, wid int, games <-chan gameSpec, out chan<- Result) {
 	for g := range games {
 		var white, black *tei.Player
 
-		white, err = c1.NewGame(g.opening.Size())
+		white, err = c1.NewGame(g.opening.Config())
 		if err != nil {
 			log.Fatalf("starting game[%v]: %v", c.P1, err)
 		}
-		black, err = c2.NewGame(g.opening.Size())
+		black, err = c2.NewGame(g.opening.Config())
 		if err != nil {
 			log.Fatalf("starting game[%v]: %v", c.P2, err)
 		}
@@ -220,13 +221,9 @@ func worker(c *Config, wid int, games <-chan gameSpec, out chan<- Result) {
 		var ms []tak.Move
 		p := g.opening
 		var tc *tei.TimeControl
-		if c.GameTime != 0 {
-			tc = &tei.TimeControl{
-				White: c.GameTime,
-				Black: c.GameTime,
-				WInc:  c.Increment,
-				BInc:  c.Increment,
-			}
+		clocks := map[tak.Color]*timemanager.Clock{
+			tak.White: {Remaining: c.GameTime, Increment: c.Increment},
+			tak.Black: {Remaining: c.GameTime, Increment: c.Increment},
 		}
 		var winner tak.Color
 		for i := 0; i < g.c.Cutoff; i++ {
@@ -236,6 +233,14 @@ func worker(c *Config, wid int, games <-chan gameSpec, out chan<- Result) {
 			if g.c.Limit != 0 {
 				ctx, cancel = context.WithTimeout(ctx, g.c.Limit)
 			}
+			if c.GameTime != 0 {
+				tc = &tei.TimeControl{
+					White: clocks[tak.White].Remaining,
+					Black: clocks[tak.Black].Remaining,
+					WInc:  c.Increment,
+					BInc:  c.Increment,
+				}
+			}
 			var err error
 			before := time.Now()
 			if p.ToMove() == tak.White {
@@ -247,22 +252,9 @@ func worker(c *Config, wid int, games <-chan gameSpec, out chan<- Result) {
 			if cancel != nil {
 				cancel()
 			}
-			if tc != nil {
-				var tm *time.Duration
-				var inc time.Duration
-				if p.ToMove() == tak.White {
-					tm = &tc.White
-					inc = tc.WInc
-				} else {
-					tm = &tc.Black
-					inc = tc.BInc
-				}
-				*tm = *tm - duration
-				if *tm <= time.Millisecond {
-					winner = p.ToMove().Flip()
-					break
-				}
-				*tm += inc
+			if tc != nil && !clocks[p.ToMove()].Spend(duration) {
+				winner = p.ToMove().Flip()
+				break
 			}
 
 			if err != nil {
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 
 	"context"
 
@@ -20,7 +23,8 @@ import (
 )
 
 type Command struct {
-	port int
+	port   int
+	ttFile string
 }
 
 func (*Command) Name() string     { return "serve" }
@@ -32,6 +36,7 @@ func (*Command) Usage() string {
 
 func (c *Command) SetFlags(flags *flag.FlagSet) {
 	flags.IntVar(&c.port, "port", 55430, "bind port")
+	flags.StringVar(&c.ttFile, "tt-file", "", "load analysis transposition tables from PATH.<size>, and save them there on exit")
 }
 
 type cache struct {
@@ -39,6 +44,10 @@ type cache struct {
 	player  *ai.MinimaxAI
 	cfg     ai.MinimaxConfig
 	precise bool
+
+	// If set, we load and save player's transposition table in
+	// files named by appending the configuration to ttFile.
+	ttFile string
 }
 
 type server struct {
@@ -49,6 +58,7 @@ type server struct {
 
 func (c *cache) getPlayer(size int, depth int, precise bool) *ai.MinimaxAI {
 	if c.cfg.Size != size || c.cfg.Depth != int(depth) || c.precise != precise {
+		c.saveTable()
 		c.cfg = ai.MinimaxConfig{
 			Size:  size,
 			Depth: depth,
@@ -59,10 +69,39 @@ func (c *cache) getPlayer(size int, depth int, precise bool) *ai.MinimaxAI {
 		}
 		c.player = ai.NewMinimax(c.cfg)
 		c.precise = precise
+		if c.ttFile != "" {
+			err := c.player.LoadTable(c.tableFile(), tak.Config{Size: size})
+			if err != nil && !os.IsNotExist(err) {
+				log.Printf("load table: %v", err)
+			}
+		}
 	}
 	return c.player
 }
 
+// tableFile returns the file holding the transposition table for
+// the current configuration. Tables for different sizes, or with
+// and without precise analysis, can't be loaded into each other's
+// players, so each has its own file.
+func (c *cache) tableFile() string {
+	path := fmt.Sprintf("%s.%d", c.ttFile, c.cfg.Size)
+	if c.precise {
+		path += ".precise"
+	}
+	return path
+}
+
+// saveTable saves the current player's transposition table to its
+// tableFile, if ttFile is configured.
+func (c *cache) saveTable() {
+	if c.ttFile == "" || c.player == nil {
+		return
+	}
+	if err := c.player.SaveTable(c.tableFile()); err != nil {
+		log.Printf("save table: %v", err)
+	}
+}
+
 func (s *server) Analyze(ctx context.Context, req *pb.AnalyzeRequest) (*pb.AnalyzeResponse, error) {
 	p, e := ptn.ParseTPS(req.Position)
 	if e != nil {
@@ -72,15 +111,72 @@ func (s *server) Analyze(ctx context.Context, req *pb.AnalyzeRequest) (*pb.Analy
 	s.analyzeCache.Lock()
 	defer s.analyzeCache.Unlock()
 	player := s.analyzeCache.getPlayer(p.Size(), int(req.Depth), req.Precise)
+	player.Cfg.MultiPV = int(req.MultiPv)
 
-	var resp pb.AnalyzeResponse
-	pv, value, _ := player.Analyze(ctx, p)
+	pv, value, st := player.Analyze(ctx, p)
+	return analyzeResponse(player, pv, value, st), nil
+}
+
+func formatPV(pv []tak.Move) []string {
+	var out []string
 	for _, m := range pv {
-		resp.Pv = append(resp.Pv, ptn.FormatMove(m))
+		out = append(out, ptn.FormatMove(m))
 	}
+	return out
+}
+
+func analyzeResponse(player *ai.MinimaxAI, pv []tak.Move, value int64, st ai.Stats) *pb.AnalyzeResponse {
+	var resp pb.AnalyzeResponse
+	resp.Pv = formatPV(pv)
 	resp.Value = value
+	resp.Depth = int32(st.Depth)
+	if player.Cfg.MultiPV > 1 {
+		for _, l := range player.Lines() {
+			resp.Lines = append(resp.Lines, &pb.Line{
+				Pv:    formatPV(l.PV),
+				Value: l.Value,
+			})
+		}
+	}
+	return &resp
+}
 
-	return &resp, nil
+func (s *server) AnalyzeStream(req *pb.AnalyzeRequest, stream pb.Taktician_AnalyzeStreamServer) error {
+	p, e := ptn.ParseTPS(req.Position)
+	if e != nil {
+		return e
+	}
+
+	s.analyzeCache.Lock()
+	defer s.analyzeCache.Unlock()
+	player := s.analyzeCache.getPlayer(p.Size(), int(req.Depth), req.Precise)
+	player.Cfg.MultiPV = int(req.MultiPv)
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	var sent int
+	var sendErr error
+	player.Cfg.OnIteration = func(pv []tak.Move, value int64, st ai.Stats) {
+		if sendErr == nil {
+			sendErr = stream.Send(analyzeResponse(player, pv, value, st))
+		}
+		if sendErr != nil {
+			cancel()
+		}
+		sent++
+	}
+	defer func() { player.Cfg.OnIteration = nil }()
+
+	pv, value, st := player.Analyze(ctx, p)
+	if sendErr != nil {
+		return sendErr
+	}
+	if sent == 0 {
+		// The table already held a deep enough result, so
+		// no iterations ran.
+		return stream.Send(analyzeResponse(player, pv, value, st))
+	}
+	return nil
 }
 
 func (s *server) Canonicalize(ctx context.Context, req *pb.CanonicalizeRequest) (*pb.CanonicalizeResponse, error) {
@@ -136,8 +232,23 @@ func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interfac
 		log.Fatalf("failed to listen: %v", err)
 	}
 	grpcServer := grpc.NewServer()
-	pb.RegisterTakticianServer(grpcServer, &server{})
+	srv := &server{}
+	srv.analyzeCache.ttFile = c.ttFile
+	pb.RegisterTakticianServer(grpcServer, srv)
+
+	if c.ttFile != "" {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sigs
+			log.Printf("Shutting down...")
+			grpcServer.GracefulStop()
+		}()
+	}
 
 	grpcServer.Serve(lis)
+	srv.analyzeCache.Lock()
+	defer srv.analyzeCache.Unlock()
+	srv.analyzeCache.saveTable()
 	return subcommands.ExitSuccess
 }
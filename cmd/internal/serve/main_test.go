@@ -0,0 +1,119 @@
+package serve
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/nelhage/taktician/pb"
+	"github.com/nelhage/taktician/ptn"
+)
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	resp []*pb.AnalyzeResponse
+}
+
+func (f *fakeStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeStream) Send(r *pb.AnalyzeResponse) error {
+	f.resp = append(f.resp, r)
+	return nil
+}
+
+func TestAnalyzeStream(t *testing.T) {
+	var s server
+	stream := &fakeStream{ctx: context.Background()}
+	req := &pb.AnalyzeRequest{
+		Position: "x5/x5/x5/x5/x5 1 1",
+		Depth:    3,
+	}
+	if err := s.AnalyzeStream(req, stream); err != nil {
+		t.Fatal("AnalyzeStream: ", err)
+	}
+	if len(stream.resp) != 3 {
+		t.Fatalf("got %d responses, want 3", len(stream.resp))
+	}
+	for i, r := range stream.resp {
+		if int(r.Depth) != i+1 {
+			t.Errorf("response %d: depth=%d", i, r.Depth)
+		}
+		if len(r.Pv) == 0 {
+			t.Errorf("response %d: empty pv", i)
+		}
+	}
+
+	resp, err := s.Analyze(context.Background(), req)
+	if err != nil {
+		t.Fatal("Analyze: ", err)
+	}
+	if resp.Depth != 3 || len(resp.Pv) == 0 {
+		t.Fatalf("Analyze: depth=%d pv=%v", resp.Depth, resp.Pv)
+	}
+}
+
+func TestAnalyzeMultiPV(t *testing.T) {
+	var s server
+	resp, err := s.Analyze(context.Background(), &pb.AnalyzeRequest{
+		Position: "x5/x5/x5/x5/x5 1 1",
+		Depth:    2,
+		MultiPv:  3,
+	})
+	if err != nil {
+		t.Fatal("Analyze: ", err)
+	}
+	if len(resp.Lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(resp.Lines))
+	}
+	if resp.Lines[0].Pv[0] != resp.Pv[0] || resp.Lines[0].Value != resp.Value {
+		t.Fatalf("first line %v does not match pv %v", resp.Lines[0], resp.Pv)
+	}
+	for i := 1; i < len(resp.Lines); i++ {
+		if resp.Lines[i].Value > resp.Lines[i-1].Value {
+			t.Errorf("lines out of order: %v", resp.Lines)
+		}
+	}
+}
+
+func TestCacheTable(t *testing.T) {
+	var c cache
+	c.ttFile = filepath.Join(t.TempDir(), "table")
+	p, err := ptn.ParseTPS("x5/x5/x5/x5/x5 1 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pv, _, _ := c.getPlayer(5, 3, false).Analyze(context.Background(), p)
+	if _, err := os.Stat(c.ttFile + ".5"); !os.IsNotExist(err) {
+		t.Fatal("saved table too soon: ", err)
+	}
+
+	// Changing the size replaces the player, which saves the
+	// table; its successor starts with a table of its own.
+	p6, err := ptn.ParseTPS("x6/x6/x6/x6/x6/x6 1 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.getPlayer(6, 2, false).Analyze(context.Background(), p6)
+	if _, err := os.Stat(c.ttFile + ".5"); err != nil {
+		t.Fatal("did not save table: ", err)
+	}
+	c.getPlayer(6, 2, true).Analyze(context.Background(), p6)
+
+	// Returning to 5x5 loads the first table.
+	player := c.getPlayer(5, 2, false)
+	for _, f := range []string{".6", ".6.precise"} {
+		if _, err := os.Stat(c.ttFile + f); err != nil {
+			t.Fatalf("did not save table%s: %v", f, err)
+		}
+	}
+	got, _, st := player.Analyze(context.Background(), p)
+	if st.Depth != 3 || !got[0].Equal(pv[0]) {
+		t.Fatalf("did not use the saved table: depth=%d pv=%v", st.Depth, got)
+	}
+}
@@ -0,0 +1,147 @@
+package suite
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+// A problem is one position of a suite, and what we expect of it.
+type problem struct {
+	id   string
+	line int
+	tps  string
+	p    *tak.Position
+
+	best   []tak.Move
+	avoid  []tak.Move
+	result string
+}
+
+const (
+	resultTinue   = "tinue"
+	resultNoTinue = "no-tinue"
+)
+
+// parseSuite reads a suite. Like EPD for chess, a suite has one
+// position per line: a TPS, followed by operations, each ending in a
+// semicolon:
+//
+//	2,x2,121C,1/x2,2,12,1/x2,2,12S,2/x3,1,1/x4,1 1 2 bm 2d5-11; result tinue; id "puzzle1";
+//
+// The operations are
+//
+//	bm MOVE...   the best moves; the engine should play one of them
+//	am MOVE...   moves the engine should avoid
+//	result R     "tinue" if the side to move can force a win, and
+//	             "no-tinue" if they cannot
+//	id "NAME"    a name for the position
+//
+// Blank lines, and lines starting with #, are ignored. A position
+// without an id is named for `name`, the name of the suite, and its
+// line number.
+func parseSuite(r io.Reader, name string) ([]*problem, error) {
+	var out []*problem
+	s := bufio.NewScanner(r)
+	n := 0
+	for s.Scan() {
+		n++
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pr, err := parseProblem(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		pr.line = n
+		if pr.id == "" {
+			pr.id = fmt.Sprintf("%s:%d", name, n)
+		}
+		out = append(out, pr)
+	}
+	return out, s.Err()
+}
+
+func parseProblem(line string) (*problem, error) {
+	ops := strings.Split(line, ";")
+	words := strings.Fields(ops[0])
+	if len(words) < 3 {
+		return nil, fmt.Errorf("bad TPS: %q", ops[0])
+	}
+	// The TPS is the board, the player to move and the move
+	// number, and perhaps some reserves.
+	tpsLen := 3
+	for tpsLen < len(words) && strings.Contains(words[tpsLen], "=") {
+		tpsLen++
+	}
+	pr := &problem{tps: strings.Join(words[:tpsLen], " ")}
+	var err error
+	if pr.p, err = ptn.ParseTPS(pr.tps); err != nil {
+		return nil, err
+	}
+	ops[0] = strings.Join(words[tpsLen:], " ")
+
+	for _, op := range ops {
+		words := strings.Fields(op)
+		if len(words) == 0 {
+			continue
+		}
+		args := words[1:]
+		switch words[0] {
+		case "bm", "am":
+			if len(args) == 0 {
+				return nil, fmt.Errorf("%s: no moves", words[0])
+			}
+			for _, a := range args {
+				m, err := ptn.ParseMove(a)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", words[0], err)
+				}
+				if int(m.X) >= pr.p.Size() || int(m.Y) >= pr.p.Size() {
+					return nil, fmt.Errorf("%s: %s: off the board", words[0], a)
+				}
+				if _, err := pr.p.Move(m); err != nil {
+					return nil, fmt.Errorf("%s: %s: %v", words[0], a, err)
+				}
+				if words[0] == "bm" {
+					pr.best = append(pr.best, m)
+				} else {
+					pr.avoid = append(pr.avoid, m)
+				}
+			}
+		case "result":
+			if len(args) != 1 || (args[0] != resultTinue && args[0] != resultNoTinue) {
+				return nil, fmt.Errorf("bad result: %q", strings.Join(args, " "))
+			}
+			pr.result = args[0]
+		case "id":
+			pr.id = strings.Trim(strings.Join(args, " "), `"`)
+		default:
+			return nil, fmt.Errorf("unknown operation: %q", words[0])
+		}
+	}
+	return pr, nil
+}
+
+func hasMove(ms []tak.Move, m tak.Move) bool {
+	for _, o := range ms {
+		if o.Equal(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// goodMove reports whether `m` is one of the best moves, if we know
+// them, and not a move to avoid.
+func (pr *problem) goodMove(m tak.Move) bool {
+	if len(pr.best) > 0 && !hasMove(pr.best, m) {
+		return false
+	}
+	return !hasMove(pr.avoid, m)
+}
@@ -0,0 +1,122 @@
+package suite
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nelhage/taktician/ai"
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+func TestParseSuite(t *testing.T) {
+	problems, err := parseSuite(strings.NewReader(`# a comment
+
+2,x2,121C,1/x2,2,12,1/x2,2,12S,2/x3,1,1/x4,1 1 2 bm 2d5-11; result tinue; id "puzzle1";
+x3/x3/x3 1 1 flats=5 caps=1 am a1 c3;
+`), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("got %d problems", len(problems))
+	}
+	p := problems[0]
+	if p.id != "puzzle1" || p.result != resultTinue || len(p.best) != 1 ||
+		ptn.FormatMove(p.best[0]) != "2d5-11" || p.line != 3 {
+		t.Errorf("bad problem: %+v", p)
+	}
+	p = problems[1]
+	if p.id != "test:4" || p.tps != "x3/x3/x3 1 1 flats=5 caps=1" || len(p.avoid) != 2 {
+		t.Errorf("bad problem: %+v", p)
+	}
+	if pieces, caps := p.p.Config().Reserves(); pieces != 5 || caps != 1 {
+		t.Errorf("reserves=%d/%d", pieces, caps)
+	}
+
+	for _, bad := range []string{
+		"x3/x3/x3",
+		"x3/x3/x3 1 1 bm;",
+		"x3/x3/x3 1 1 bm a4;",
+		"x3/x3/x3 1 1 result maybe;",
+		"x3/x3/x3 1 1 pv a1;",
+	} {
+		if _, err := parseSuite(strings.NewReader(bad), "test"); err == nil {
+			t.Errorf("parsed %q", bad)
+		}
+	}
+}
+
+func TestSolvedBy(t *testing.T) {
+	problems, err := parseSuite(strings.NewReader(`x3/x3/x3 1 1 bm a1 b1; am c1; result tinue; id "bm";
+x3/x3/x3 1 1 am a1;
+x3/x3/x3 1 1 result no-tinue;
+`), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	move := func(s string) tak.Move {
+		m, err := ptn.ParseMove(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	cases := []struct {
+		pr   int
+		o    outcome
+		want bool
+	}{
+		{0, outcome{move: move("a1"), result: resultTinue}, true},
+		{0, outcome{move: move("b1")}, true},
+		{0, outcome{move: move("a1"), result: resultNoTinue}, false},
+		{0, outcome{move: move("b2"), result: resultTinue}, false},
+		{0, outcome{result: resultTinue}, false},
+		{1, outcome{move: move("b2")}, true},
+		{1, outcome{move: move("a1")}, false},
+		{2, outcome{result: resultNoTinue}, true},
+		{2, outcome{result: resultTinue}, false},
+		{2, outcome{move: move("a1")}, false},
+	}
+	for i, tc := range cases {
+		if got := problems[tc.pr].solvedBy(&tc.o); got != tc.want {
+			t.Errorf("%d: solved=%v, want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestPuzzleSuite(t *testing.T) {
+	f, err := os.Open("../../../testdata/puzzles/tinue.epd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	problems, err := parseSuite(f, "tinue.epd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) == 0 {
+		t.Fatal("no problems")
+	}
+}
+
+func TestMinimaxResult(t *testing.T) {
+	if r := minimaxResult(ai.WinThreshold); r != resultTinue {
+		t.Errorf("win: result=%q", r)
+	}
+	for _, v := range []int64{0, -ai.WinThreshold, ai.WinThreshold - 1} {
+		if r := minimaxResult(v); r != "" {
+			t.Errorf("v=%d: result=%q, want no claim", v, r)
+		}
+	}
+}
+
+func TestMissing(t *testing.T) {
+	baseline := map[string]bool{"a": true, "c": true, "b": true}
+	ids := map[string]bool{"b": true, "d": true}
+	got := missing(baseline, ids)
+	if strings.Join(got, ",") != "a,c" {
+		t.Errorf("missing=%v, want [a c]", got)
+	}
+}
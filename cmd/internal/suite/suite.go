@@ -0,0 +1,335 @@
+package suite
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/nelhage/taktician/ai"
+	"github.com/nelhage/taktician/ai/mcts"
+	"github.com/nelhage/taktician/cmd/internal/opt"
+	"github.com/nelhage/taktician/prove"
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+type Command struct {
+	engine   string
+	limit    time.Duration
+	maxNodes uint64
+	baseline string
+	mmopt    opt.Minimax
+}
+
+func (*Command) Name() string     { return "suite" }
+func (*Command) Synopsis() string { return "Run an engine over a suite of test positions" }
+func (*Command) Usage() string {
+	return `suite [flags] FILE...
+
+Run an engine over each position in the suites in FILE..., and report,
+as one line of JSON per position, the move it chose, whether that
+solves the problem, and how long the engine took to settle on a
+solution. A final line holds a summary.
+
+A suite holds one position per line, in an EPD-like format: a TPS,
+then operations, each ending in a semicolon:
+
+  2,x2,121C,1/x2,2,12,1/x2,2,12S,2/x3,1,1/x4,1 1 2 bm 2d5-11; result tinue; id "puzzle1";
+
+"bm" lists the best moves, any of which solves the problem; "am" lists
+moves to avoid; "result" is "tinue" or "no-tinue", and is checked for
+the engines that claim a result (dfpn, and minimax when it finds a
+forced win); and "id" names the position.
+
+-engine selects minimax, mcts or dfpn. Each position is searched for at
+most -limit, and, with dfpn, -max-nodes. With -baseline, compare with
+the output of an earlier run, and exit with failure if any problem it
+solved is no longer solved, or is missing. Positions without an id
+are named FILE:LINE, and ids must be unique.
+`
+}
+
+func (c *Command) SetFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.engine, "engine", "minimax", "engine to run: minimax, mcts or dfpn")
+	flags.DurationVar(&c.limit, "limit", 10*time.Second, "time limit per position")
+	flags.Uint64Var(&c.maxNodes, "max-nodes", 0, "limit the dfpn search by number of nodes")
+	flags.StringVar(&c.baseline, "baseline", "", "report regressions from the results in FILE")
+	c.mmopt.AddFlags(flags)
+}
+
+// A Result is the outcome of one problem. The JSON encoding of
+// Results is the command's output format.
+type Result struct {
+	Id     string
+	TPS    string
+	Engine string
+	Move   string `json:",omitempty"`
+	Result string `json:",omitempty"`
+	Solved bool
+
+	// Seconds is the time the engine took over the position, and
+	// SolveSeconds the time at which it settled on a solution.
+	Seconds      float64
+	SolveSeconds float64 `json:",omitempty"`
+	Nodes        uint64  `json:",omitempty"`
+
+	Regression bool `json:",omitempty"`
+}
+
+type Summary struct {
+	Engine      string
+	Positions   int
+	Solved      int
+	Seconds     float64
+	Regressions []string
+}
+
+func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if flag.NArg() == 0 {
+		fmt.Fprint(os.Stderr, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	switch c.engine {
+	case "minimax", "mcts", "dfpn":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown engine: %q\n", c.engine)
+		return subcommands.ExitUsageError
+	}
+
+	var problems []*problem
+	ids := make(map[string]bool)
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return subcommands.ExitFailure
+		}
+		ps, err := parseSuite(f, path)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return subcommands.ExitFailure
+		}
+		for _, pr := range ps {
+			if ids[pr.id] {
+				fmt.Fprintf(os.Stderr, "%s:%d: duplicate id %q\n", path, pr.line, pr.id)
+				return subcommands.ExitFailure
+			}
+			ids[pr.id] = true
+		}
+		problems = append(problems, ps...)
+	}
+
+	var baseline map[string]bool
+	if c.baseline != "" {
+		var err error
+		if baseline, err = readBaseline(c.baseline); err != nil {
+			fmt.Fprintf(os.Stderr, "baseline: %v\n", err)
+			return subcommands.ExitFailure
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	sum := Summary{Engine: c.engine, Regressions: []string{}}
+	for _, pr := range problems {
+		r := c.run(pr)
+		if baseline[pr.id] && !r.Solved {
+			r.Regression = true
+			sum.Regressions = append(sum.Regressions, pr.id)
+		}
+		sum.Positions++
+		if r.Solved {
+			sum.Solved++
+		}
+		sum.Seconds += r.Seconds
+		enc.Encode(&r)
+	}
+	sum.Regressions = append(sum.Regressions, missing(baseline, ids)...)
+	enc.Encode(map[string]*Summary{"Summary": &sum})
+
+	fmt.Fprintf(os.Stderr, "%s: solved %d/%d in %.1fs\n",
+		c.engine, sum.Solved, sum.Positions, sum.Seconds)
+	if len(sum.Regressions) > 0 {
+		fmt.Fprintf(os.Stderr, "%d regressions: %v\n", len(sum.Regressions), sum.Regressions)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// readBaseline reads the output of an earlier run, and returns the
+// ids of the problems it solved.
+func readBaseline(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	solved := make(map[string]bool)
+	dec := json.NewDecoder(f)
+	for {
+		var r Result
+		err := dec.Decode(&r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if r.Id != "" && r.Solved {
+			solved[r.Id] = true
+		}
+	}
+	return solved, nil
+}
+
+// missing returns, in order, the problems the baseline solved, but
+// that are not among `ids`, those we ran: they are regressions too.
+func missing(baseline, ids map[string]bool) []string {
+	var out []string
+	for id := range baseline {
+		if !ids[id] {
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// An outcome is what an engine made of a problem. result is empty if
+// the engine does not claim a result, and solveAt is negative if it
+// never settled on a solution.
+type outcome struct {
+	move    tak.Move
+	result  string
+	elapsed time.Duration
+	solveAt time.Duration
+	nodes   uint64
+}
+
+func (c *Command) run(pr *problem) Result {
+	var o outcome
+	switch c.engine {
+	case "minimax":
+		o = c.runMinimax(pr)
+	case "mcts":
+		o = c.runMCTS(pr)
+	case "dfpn":
+		o = c.runDFPN(pr)
+	}
+	r := Result{
+		Id:      pr.id,
+		TPS:     pr.tps,
+		Engine:  c.engine,
+		Result:  o.result,
+		Seconds: o.elapsed.Seconds(),
+		Nodes:   o.nodes,
+	}
+	if o.move.Type != 0 {
+		r.Move = ptn.FormatMove(o.move)
+	}
+	r.Solved = pr.solvedBy(&o)
+	if r.Solved && o.solveAt >= 0 {
+		r.SolveSeconds = o.solveAt.Seconds()
+	}
+	return r
+}
+
+// solvedBy reports whether the outcome solves the problem.
+func (pr *problem) solvedBy(o *outcome) bool {
+	if pr.result != "" && o.result != "" && pr.result != o.result {
+		return false
+	}
+	if len(pr.best) == 0 && len(pr.avoid) == 0 {
+		return pr.result == "" || o.result != ""
+	}
+	return o.move.Type != 0 && pr.goodMove(o.move)
+}
+
+// minimaxResult claims tinue if minimax has found a forced win. A
+// depth-limited search cannot prove that there is none, so it
+// otherwise claims nothing.
+func minimaxResult(v int64) string {
+	if v >= ai.WinThreshold {
+		return resultTinue
+	}
+	return ""
+}
+
+func (c *Command) runMinimax(pr *problem) outcome {
+	cfg := c.mmopt.BuildConfig(pr.p.Size())
+	start := time.Now()
+	solveAt := time.Duration(-1)
+	// The engine has settled on a solution at the start of the
+	// run of iterations, continuing to the end, whose move and
+	// value solve the problem.
+	cfg.OnIteration = func(pv []tak.Move, v int64, st ai.Stats) {
+		o := outcome{result: minimaxResult(v)}
+		if len(pv) > 0 {
+			o.move = pv[0]
+		}
+		if !pr.solvedBy(&o) {
+			solveAt = -1
+		} else if solveAt < 0 {
+			solveAt = time.Since(start)
+		}
+	}
+	player := ai.NewMinimax(cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), c.limit)
+	defer cancel()
+	pv, v, st := player.Analyze(ctx, pr.p)
+	o := outcome{
+		result:  minimaxResult(v),
+		elapsed: time.Since(start),
+		solveAt: solveAt,
+		nodes:   st.Evaluated,
+	}
+	if len(pv) > 0 {
+		o.move = pv[0]
+	}
+	return o
+}
+
+func (c *Command) runMCTS(pr *problem) outcome {
+	player := mcts.NewMonteCarlo(mcts.MCTSConfig{
+		Seed:  c.mmopt.Seed,
+		Debug: c.mmopt.Debug,
+		Size:  pr.p.Size(),
+		Limit: c.limit,
+	})
+	start := time.Now()
+	m := player.GetMove(context.Background(), pr.p)
+	elapsed := time.Since(start)
+	return outcome{move: m, elapsed: elapsed, solveAt: elapsed}
+}
+
+func (c *Command) runDFPN(pr *problem) outcome {
+	solver := prove.NewDFPN(&prove.DFPNConfig{
+		Debug:    c.mmopt.Debug,
+		Attacker: pr.p.ToMove(),
+		TableMem: c.mmopt.TableMem,
+		MaxWork:  c.maxNodes,
+		MaxTime:  c.limit,
+		Threads:  c.mmopt.Threads,
+	})
+	out, stats := solver.Prove(pr.p)
+	o := outcome{
+		elapsed: out.Duration,
+		solveAt: out.Duration,
+		nodes:   stats.Work,
+	}
+	switch out.Result {
+	case prove.EvalTrue:
+		o.result = resultTinue
+		o.move = out.Move
+	case prove.EvalFalse:
+		o.result = resultNoTinue
+	}
+	return o
+}
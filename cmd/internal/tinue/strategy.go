@@ -0,0 +1,66 @@
+package tinue
+
+import (
+	"sort"
+
+	"github.com/nelhage/taktician/prove"
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+// sortDefences orders the defences at each turn of `t` longest
+// first, so that the longest defence becomes the main line.
+func sortDefences(t *prove.ProofTree) {
+	sort.SliceStable(t.Defences, func(i, j int) bool {
+		return t.Defences[i].Plies() > t.Defences[j].Plies()
+	})
+	for _, d := range t.Defences {
+		if d.Reply != nil {
+			sortDefences(d.Reply)
+		}
+	}
+}
+
+// line renders the proof, played from `p`, as a PTN line: the first
+// defence at each turn is the main line, and the others follow it as
+// variations. It returns the position at the end of the main line.
+func line(t *prove.ProofTree, p *tak.Position) ([]ptn.Op, *tak.Position) {
+	var ops []ptn.Op
+	if p.ToMove() == tak.Black {
+		ops = append(ops, &ptn.MoveNumber{Number: p.MoveNumber()/2 + 1})
+	}
+	return appendLine(ops, t, p)
+}
+
+// appendLine appends the proof, played from `p`, to `ops`, which
+// already hold a move number if `p` is black's move.
+func appendLine(ops []ptn.Op, t *prove.ProofTree, p *tak.Position) ([]ptn.Op, *tak.Position) {
+	if p.ToMove() == tak.White {
+		ops = append(ops, &ptn.MoveNumber{Number: p.MoveNumber()/2 + 1})
+	}
+	ops = append(ops, &ptn.Move{Move: t.Move})
+	p, _ = p.Move(t.Move)
+	if len(t.Defences) == 0 {
+		return ops, p
+	}
+	if p.ToMove() == tak.White {
+		ops = append(ops, &ptn.MoveNumber{Number: p.MoveNumber()/2 + 1})
+	}
+	ops = append(ops, &ptn.Move{Move: t.Defences[0].Move})
+	for _, d := range t.Defences[1:] {
+		alt := []ptn.Op{
+			&ptn.MoveNumber{Number: p.MoveNumber()/2 + 1},
+			&ptn.Move{Move: d.Move},
+		}
+		if d.Reply != nil {
+			next, _ := p.Move(d.Move)
+			alt, _ = appendLine(alt, d.Reply, next)
+		}
+		ops = append(ops, &ptn.Variation{Ops: alt})
+	}
+	next, _ := p.Move(t.Defences[0].Move)
+	if t.Defences[0].Reply == nil {
+		return ops, next
+	}
+	return appendLine(ops, t.Defences[0].Reply, next)
+}
@@ -0,0 +1,50 @@
+package tinue
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nelhage/taktician/prove"
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+func TestLine(t *testing.T) {
+	p, err := ptn.ParseTPS("2,x2,121C,1/x2,2,12,1/x2,2,12S,2/x3,1,1/x4,1 1 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := prove.NewDFPN(&prove.DFPNConfig{Attacker: tak.White})
+	if out, _ := solver.Prove(p); out.Result != prove.EvalTrue {
+		t.Fatalf("prove: %s", out.Result)
+	}
+	tree, _, err := solver.ProofTree(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortDefences(tree)
+
+	g := &ptn.PTN{Tags: []ptn.Tag{
+		{Name: "Size", Value: "5"},
+		{Name: "Player1", Value: "white"},
+		{Name: "Player2", Value: "black"},
+		{Name: "TPS", Value: ptn.FormatTPS(p)},
+	}}
+	var final *tak.Position
+	g.Ops, final = line(tree, p)
+	if n := final.MoveNumber() - p.MoveNumber(); n != tree.Plies() {
+		t.Errorf("main line has %d plies, want %d", n, tree.Plies())
+	}
+	parsed, err := ptn.ParsePTN(strings.NewReader(g.Render()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diags bytes.Buffer
+	for _, d := range ptn.Validate(parsed) {
+		diags.WriteString(d.String() + "\n")
+	}
+	if diags.Len() != 0 {
+		t.Errorf("strategy does not validate:\n%s", diags.String())
+	}
+}
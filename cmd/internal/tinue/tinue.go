@@ -0,0 +1,225 @@
+package tinue
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/nelhage/taktician/prove"
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+type Command struct {
+	move     int
+	black    bool
+	maxWork  uint64
+	limit    time.Duration
+	tableMem int64
+	threads  int
+	json     bool
+	debug    int
+}
+
+func (*Command) Name() string     { return "tinue" }
+func (*Command) Synopsis() string { return "Prove or refute a forced win for the side to move" }
+func (*Command) Usage() string {
+	return `tinue [flags] FILE.ptn|TPS
+
+Search for tinue -- a win the side to move can force, whatever the
+defence -- in the final position of FILE.ptn, or the position given in
+TPS, with the DFPN prover. Use -move and -black to select an earlier
+position from the PTN.
+
+If there is tinue, print the winning strategy: the attacker's move at
+each turn, and every defence with its refutation. The longest defence
+is the main line, and the others are variations. With -json, print the
+result and the strategy tree as JSON instead. The strategy is checked
+by replaying it, independently of the prover, before it is printed.
+`
+}
+
+func (c *Command) SetFlags(flags *flag.FlagSet) {
+	flags.IntVar(&c.move, "move", 0, "PTN move number to analyze")
+	flags.BoolVar(&c.black, "black", false, "analyze black's move")
+	flags.Uint64Var(&c.maxWork, "max-nodes", 0, "limit the search by number of nodes (0 for no limit)")
+	flags.DurationVar(&c.limit, "limit", time.Minute, "limit of how much time to use (0 for no limit)")
+	flags.Int64Var(&c.tableMem, "table-mem", 0, "set table size")
+	flags.IntVar(&c.threads, "threads", 1, "number of search threads")
+	flags.BoolVar(&c.json, "json", false, "print the result as JSON")
+	flags.IntVar(&c.debug, "debug", 0, "debug level")
+}
+
+func (c *Command) position(args []string) (*tak.Position, error) {
+	if len(args) == 1 && strings.HasSuffix(args[0], ".ptn") {
+		g, err := ptn.ParseFile(args[0])
+		if err != nil {
+			return nil, err
+		}
+		color := tak.NoColor
+		if c.black {
+			color = tak.Black
+		} else if c.move != 0 {
+			color = tak.White
+		}
+		return g.PositionAtMove(c.move, color)
+	}
+	if c.move != 0 || c.black {
+		return nil, fmt.Errorf("-move and -black need a PTN file")
+	}
+	return ptn.ParseTPS(strings.Join(args, " "))
+}
+
+func (c *Command) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if flag.NArg() == 0 {
+		fmt.Fprint(os.Stderr, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	p, err := c.position(flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "position: %v\n", err)
+		return subcommands.ExitUsageError
+	}
+	if over, _ := p.GameOver(); over {
+		fmt.Fprintln(os.Stderr, "position: the game is over")
+		return subcommands.ExitUsageError
+	}
+
+	attacker := p.ToMove()
+	solver := prove.NewDFPN(&prove.DFPNConfig{
+		Debug:    c.debug,
+		Attacker: attacker,
+		TableMem: c.tableMem,
+		MaxWork:  c.maxWork,
+		MaxTime:  c.limit,
+		Threads:  c.threads,
+	})
+	start := time.Now()
+	out, stats := solver.Prove(p)
+
+	var t *prove.ProofTree
+	if out.Result == prove.EvalTrue {
+		var extra prove.DFPNStats
+		t, extra, err = solver.ProofTree(p)
+		stats.Add(&extra)
+		if err == nil {
+			err = prove.VerifyProof(p, attacker, t)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "proof: %v\n", err)
+			return subcommands.ExitFailure
+		}
+		sortDefences(t)
+	}
+	elapsed := time.Since(start)
+
+	if c.json {
+		c.printJSON(p, attacker, out.Result, t, stats.Work, elapsed)
+	} else {
+		c.printPTN(p, attacker, out.Result, t)
+	}
+	fmt.Fprintf(os.Stderr, "%d nodes in %s (%d table overwrites, %d of them solved)\n",
+		stats.Work, elapsed.Round(time.Millisecond), stats.Overwrites, stats.SolvedOverwrites)
+	return subcommands.ExitSuccess
+}
+
+func describe(result prove.Evaluation) string {
+	switch result {
+	case prove.EvalTrue:
+		return "tinue"
+	case prove.EvalFalse:
+		return "no tinue"
+	default:
+		return "unknown"
+	}
+}
+
+func (c *Command) printPTN(p *tak.Position, attacker tak.Color, result prove.Evaluation, t *prove.ProofTree) {
+	if t == nil {
+		fmt.Println(describe(result))
+		return
+	}
+	fmt.Printf("tinue: %s wins with %s in %d plies\n\n",
+		attacker, ptn.FormatMove(t.Move), t.Plies())
+
+	g := &ptn.PTN{}
+	g.Tags = []ptn.Tag{
+		{Name: "Size", Value: strconv.Itoa(p.Size())},
+	}
+	if komi := p.Config().Komi; komi != 0 {
+		g.Tags = append(g.Tags, ptn.Tag{Name: "Komi", Value: ptn.FormatKomi(komi)})
+	}
+	pieces, caps := p.Config().Reserves()
+	defPieces, defCaps := tak.DefaultReserves(p.Size())
+	if pieces != defPieces {
+		g.Tags = append(g.Tags, ptn.Tag{Name: "Flats", Value: strconv.Itoa(pieces)})
+	}
+	if caps != defCaps {
+		g.Tags = append(g.Tags, ptn.Tag{Name: "Caps", Value: strconv.Itoa(caps)})
+	}
+	g.Tags = append(g.Tags, ptn.Tag{Name: "TPS", Value: ptn.FormatTPS(p)})
+
+	var final *tak.Position
+	g.Ops, final = line(t, p)
+	r := ptn.ResultFromGame(final)
+	g.Tags = append(g.Tags, ptn.Tag{Name: "Result", Value: r.Result})
+	g.Ops = append(g.Ops, &r)
+	fmt.Print(g.Render())
+}
+
+type jsonResult struct {
+	TPS      string
+	Attacker string
+	Result   string
+	Plies    int           `json:",omitempty"`
+	Strategy *jsonStrategy `json:",omitempty"`
+	Nodes    uint64
+	Seconds  float64
+}
+
+type jsonStrategy struct {
+	Move     string
+	Defences []jsonDefence `json:",omitempty"`
+}
+
+type jsonDefence struct {
+	Move  string
+	Reply *jsonStrategy `json:",omitempty"`
+}
+
+func toJSON(t *prove.ProofTree) *jsonStrategy {
+	out := &jsonStrategy{Move: ptn.FormatMove(t.Move)}
+	for _, d := range t.Defences {
+		jd := jsonDefence{Move: ptn.FormatMove(d.Move)}
+		if d.Reply != nil {
+			jd.Reply = toJSON(d.Reply)
+		}
+		out.Defences = append(out.Defences, jd)
+	}
+	return out
+}
+
+func (c *Command) printJSON(p *tak.Position, attacker tak.Color, result prove.Evaluation,
+	t *prove.ProofTree, work uint64, elapsed time.Duration) {
+	out := jsonResult{
+		TPS:      ptn.FormatTPS(p),
+		Attacker: attacker.String(),
+		Result:   describe(result),
+		Nodes:    work,
+		Seconds:  elapsed.Seconds(),
+	}
+	if t != nil {
+		out.Plies = t.Plies()
+		out.Strategy = toJSON(t)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	enc.Encode(&out)
+}
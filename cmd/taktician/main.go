@@ -13,12 +13,16 @@ import (
 	"github.com/nelhage/taktician/cmd/internal/gencorpus"
 	"github.com/nelhage/taktician/cmd/internal/genopenings"
 	"github.com/nelhage/taktician/cmd/internal/importptn"
+	"github.com/nelhage/taktician/cmd/internal/lint"
 	"github.com/nelhage/taktician/cmd/internal/openings"
+	"github.com/nelhage/taktician/cmd/internal/perft"
 	"github.com/nelhage/taktician/cmd/internal/play"
 	"github.com/nelhage/taktician/cmd/internal/playtak"
 	"github.com/nelhage/taktician/cmd/internal/selfplay"
 	"github.com/nelhage/taktician/cmd/internal/serve"
+	"github.com/nelhage/taktician/cmd/internal/suite"
 	"github.com/nelhage/taktician/cmd/internal/tei"
+	"github.com/nelhage/taktician/cmd/internal/tinue"
 )
 
 func innerMain() int {
@@ -37,6 +41,10 @@ func innerMain() int {
 	subcommands.Register(&openings.Command{}, "")
 	subcommands.Register(&canonicalize.Command{}, "")
 	subcommands.Register(&gencorpus.Command{}, "")
+	subcommands.Register(&lint.Command{}, "")
+	subcommands.Register(&perft.Command{}, "")
+	subcommands.Register(&tinue.Command{}, "")
+	subcommands.Register(&suite.Command{}, "")
 
 	subcommands.Register(&importptn.Command{}, "")
 
@@ -0,0 +1,394 @@
+// Package experience implements a small on-disk store of what
+// Taktician has learned about positions in previous games: the
+// results of searching them, how games through them ended, and moves
+// from them which lost.
+//
+// Positions are stored in a canonical orientation, so knowledge about
+// a position applies to all of its rotations and reflections, and are
+// keyed by ai.ExperienceKey, so it does not apply to games with
+// different komi or reserves.
+package experience
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nelhage/taktician/ai"
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/symmetry"
+	"github.com/nelhage/taktician/tak"
+)
+
+// Entry is what we know about a position. Moves are relative to the
+// position they were looked up in.
+type Entry struct {
+	// Move and Value are the result of the deepest search of the
+	// position, to Depth ply. Depth is zero if we have no search
+	// result.
+	Move  tak.Move
+	Value int64
+	Depth int
+
+	// Avoid lists moves which lost games from this position.
+	Avoid []tak.Move
+
+	// Wins and Losses count games through this position that
+	// the player to move went on to win or lose.
+	Wins, Losses int
+}
+
+// entry is an Entry for a position in canonical orientation.
+type entry struct {
+	Entry
+	p *tak.Position
+}
+
+// variant is one orientation of a canonical position; `s` maps the
+// canonical position onto it.
+type variant struct {
+	e *entry
+	s symmetry.Symmetry
+}
+
+// Store is a collection of Entries. It is safe for concurrent use,
+// and implements ai.Experience.
+type Store struct {
+	mu      sync.RWMutex
+	entries map[uint64]*entry
+	index   map[uint64]variant
+
+	// known holds a snapshot, which is never modified, of what
+	// Lookup and Avoid return. MinimaxAI calls them from the
+	// search, so they read it without taking mu; writers publish
+	// a new one.
+	known atomic.Value // snapshot
+}
+
+// snapshot maps the key of every orientation of a position we know
+// something about to what Lookup and Avoid return for it.
+type snapshot map[uint64]*known
+
+type known struct {
+	move  tak.Move
+	value int64
+	depth int
+	avoid []tak.Move
+}
+
+var _ ai.Experience = &Store{}
+
+func New() *Store {
+	s := &Store{
+		entries: make(map[uint64]*entry),
+		index:   make(map[uint64]variant),
+	}
+	s.known.Store(snapshot{})
+	return s
+}
+
+// publish replaces the snapshot read by Lookup and Avoid. The caller
+// must hold s.mu for writing. Writes are rare next to lookups, so we
+// simply rebuild the whole thing.
+func (s *Store) publish() {
+	snap := make(snapshot, len(s.index))
+	for h, v := range s.index {
+		if v.e.Depth == 0 && len(v.e.Avoid) == 0 {
+			continue
+		}
+		e := v.e.transform(v.s)
+		snap[h] = &known{
+			move:  e.Move,
+			value: e.Value,
+			depth: e.Depth,
+			avoid: e.Avoid,
+		}
+	}
+	s.known.Store(snap)
+}
+
+// transform returns `e` with its moves mapped by `s`.
+func (e *Entry) transform(s symmetry.Symmetry) Entry {
+	out := *e
+	if e.Depth > 0 {
+		out.Move = symmetry.TransformMove(s, e.Move)
+	}
+	out.Avoid = make([]tak.Move, len(e.Avoid))
+	for i, m := range e.Avoid {
+		out.Avoid[i] = symmetry.TransformMove(s, m)
+	}
+	return out
+}
+
+// canonical returns the canonical orientation of `p`, and the
+// symmetry mapping `p` onto it.
+func canonical(p *tak.Position) (*tak.Position, symmetry.Symmetry, error) {
+	syms, err := symmetry.Symmetries(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	best := syms[0]
+	for _, s := range syms[1:] {
+		if s.P.Hash() < best.P.Hash() {
+			best = s
+		}
+	}
+	return best.P, best.S, nil
+}
+
+// add indexes a canonical entry under every orientation of its
+// position.
+func (s *Store) add(e *entry) error {
+	syms, err := symmetry.Symmetries(e.p)
+	if err != nil {
+		return err
+	}
+	s.entries[ai.ExperienceKey(e.p)] = e
+	for _, sym := range syms {
+		s.index[ai.ExperienceKey(sym.P)] = variant{e, sym.S}
+	}
+	return nil
+}
+
+// entry returns the canonical entry for `p`, creating it if
+// necessary, and the symmetry mapping `p` onto it. The caller must
+// hold s.mu for writing.
+func (s *Store) entry(p *tak.Position) (*entry, symmetry.Symmetry, error) {
+	c, sym, err := canonical(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	if e, ok := s.entries[ai.ExperienceKey(c)]; ok {
+		return e, sym, nil
+	}
+	e := &entry{p: c}
+	return e, sym, s.add(e)
+}
+
+// Get returns what we know about `p`.
+func (s *Store) Get(p *tak.Position) (Entry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.index[ai.ExperienceKey(p)]
+	if !ok {
+		return Entry{}, false
+	}
+	return v.e.transform(v.s), true
+}
+
+// Lookup implements ai.Experience. It does not take any locks.
+func (s *Store) Lookup(h uint64) (tak.Move, int64, int, bool) {
+	k, ok := s.known.Load().(snapshot)[h]
+	if !ok || k.depth == 0 {
+		return tak.Move{}, 0, 0, false
+	}
+	return k.move, k.value, k.depth, true
+}
+
+// Avoid implements ai.Experience. It does not take any locks.
+func (s *Store) Avoid(h uint64) []tak.Move {
+	k, ok := s.known.Load().(snapshot)[h]
+	if !ok || len(k.avoid) == 0 {
+		return nil
+	}
+	return append([]tak.Move(nil), k.avoid...)
+}
+
+// RecordSearch records that a search of `p` to `depth` ply found `m`
+// to be the best move, with value `v`. It is ignored if we already
+// have the result of a deeper search.
+func (s *Store) RecordSearch(p *tak.Position, m tak.Move, v int64, depth int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.publish()
+	e, sym, err := s.entry(p)
+	if err != nil {
+		return err
+	}
+	if depth < e.Depth {
+		return nil
+	}
+	m = symmetry.TransformMove(sym, m)
+	for _, a := range e.Avoid {
+		if a.Equal(m) {
+			return nil
+		}
+	}
+	e.Move, e.Value, e.Depth = m, v, depth
+	return nil
+}
+
+// RecordGame records the outcome of a game. positions[i] is the
+// position before moves[i], and `winner` is the winner of the game,
+// or tak.NoColor for a draw.
+//
+// In a decisive game, we also look for the loser's blunder: the last
+// move they played from a position which we searched without
+// realizing that it was lost. That move is added to the position's
+// Avoid list, and the search result that recommended it is
+// discarded.
+func (s *Store) RecordGame(positions []*tak.Position, moves []tak.Move, winner tak.Color) error {
+	if len(positions) < len(moves) {
+		return fmt.Errorf("%d positions for %d moves", len(positions), len(moves))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.publish()
+	for _, p := range positions[:len(moves)] {
+		e, _, err := s.entry(p)
+		if err != nil {
+			return err
+		}
+		switch {
+		case winner == tak.NoColor:
+		case p.ToMove() == winner:
+			e.Wins++
+		default:
+			e.Losses++
+		}
+	}
+	if winner == tak.NoColor {
+		return nil
+	}
+	for i := len(moves) - 1; i >= 0; i-- {
+		p := positions[i]
+		if p.ToMove() == winner {
+			continue
+		}
+		e, sym, err := s.entry(p)
+		if err != nil {
+			return err
+		}
+		if e.Depth == 0 || e.Value < -ai.WinThreshold {
+			continue
+		}
+		m := symmetry.TransformMove(sym, moves[i])
+		e.Avoid = append(e.Avoid, m)
+		e.Move, e.Value, e.Depth = tak.Move{}, 0, 0
+		break
+	}
+	return nil
+}
+
+// record is the on-disk representation of an entry.
+type record struct {
+	TPS           string   `json:"tps"`
+	Komi          int      `json:"komi,omitempty"`
+	BlackWinsTies bool     `json:"black_wins_ties,omitempty"`
+	Move          string   `json:"move,omitempty"`
+	Value         int64    `json:"value,omitempty"`
+	Depth         int      `json:"depth,omitempty"`
+	Avoid         []string `json:"avoid,omitempty"`
+	Wins          int      `json:"wins,omitempty"`
+	Losses        int      `json:"losses,omitempty"`
+}
+
+// Load reads a store written by Save. If `path` does not exist, it
+// returns an empty store.
+func Load(path string) (*Store, error) {
+	s := New()
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var r record
+		if err := dec.Decode(&r); err != nil {
+			return nil, err
+		}
+		e, err := r.entry()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.TPS, err)
+		}
+		if err := s.add(e); err != nil {
+			return nil, err
+		}
+	}
+	s.publish()
+	return s, nil
+}
+
+func (r *record) entry() (*entry, error) {
+	p, err := ptn.ParseTPSWithConfig(r.TPS, tak.Config{
+		Komi:          r.Komi,
+		BlackWinsTies: r.BlackWinsTies,
+	})
+	if err != nil {
+		return nil, err
+	}
+	e := &entry{p: p}
+	e.Value, e.Depth = r.Value, r.Depth
+	e.Wins, e.Losses = r.Wins, r.Losses
+	if r.Depth > 0 {
+		if e.Move, err = ptn.ParseMove(r.Move); err != nil {
+			return nil, err
+		}
+	}
+	for _, a := range r.Avoid {
+		m, err := ptn.ParseMove(a)
+		if err != nil {
+			return nil, err
+		}
+		e.Avoid = append(e.Avoid, m)
+	}
+	return e, nil
+}
+
+// Save writes the store to `path`, replacing it atomically.
+func (s *Store) Save(path string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	entries := make([]*entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return ai.ExperienceKey(entries[i].p) < ai.ExperienceKey(entries[j].p)
+	})
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		r := record{
+			TPS:           ptn.FormatExtendedTPS(e.p),
+			Komi:          e.p.Config().Komi,
+			BlackWinsTies: e.p.Config().BlackWinsTies,
+			Value:         e.Value,
+			Depth:         e.Depth,
+			Wins:          e.Wins,
+			Losses:        e.Losses,
+		}
+		if e.Depth > 0 {
+			r.Move = ptn.FormatMove(e.Move)
+		}
+		for _, a := range e.Avoid {
+			r.Avoid = append(r.Avoid, ptn.FormatMove(a))
+		}
+		if err := enc.Encode(&r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,181 @@
+package experience
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nelhage/taktician/ai"
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/symmetry"
+	"github.com/nelhage/taktician/tak"
+	"github.com/nelhage/taktician/taktest"
+)
+
+// play returns the positions before each of `moves`, followed by
+// the final position.
+func play(t *testing.T, size int, moves []tak.Move) []*tak.Position {
+	p := tak.New(tak.Config{Size: size})
+	ps := []*tak.Position{p}
+	for _, m := range moves {
+		var err error
+		p, err = p.Move(m)
+		if err != nil {
+			t.Fatalf("move %s: %v", ptn.FormatMove(m), err)
+		}
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+func TestSymmetricLookup(t *testing.T) {
+	s := New()
+	p := taktest.Position(5, "a1 e5 b2")
+	m := taktest.Move("c3")
+	if err := s.RecordSearch(p, m, 100, 4); err != nil {
+		t.Fatal(err)
+	}
+	syms, err := symmetry.Symmetries(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sym := range syms {
+		got, v, depth, ok := s.Lookup(sym.P.Hash())
+		if !ok || v != 100 || depth != 4 {
+			t.Fatalf("lookup: ok=%t v=%d depth=%d", ok, v, depth)
+		}
+		if want := symmetry.TransformMove(sym.S, m); !got.Equal(want) {
+			t.Errorf("got %s, want %s", ptn.FormatMove(got), ptn.FormatMove(want))
+		}
+	}
+
+	if err := s.RecordSearch(p, taktest.Move("d4"), 50, 3); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _, _ := s.Lookup(p.Hash()); !got.Equal(m) {
+		t.Errorf("shallower search replaced result: %s", ptn.FormatMove(got))
+	}
+}
+
+func TestKomi(t *testing.T) {
+	s := New()
+	p := taktest.Position(5, "a1 e5 b2")
+	komi, err := ptn.ParseTPSWithConfig(ptn.FormatTPS(p), tak.Config{Komi: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reserves, err := ptn.ParseTPSWithConfig(ptn.FormatTPS(p)+" flats=18", tak.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RecordSearch(p, taktest.Move("c3"), 100, 4)
+	s.RecordSearch(komi, taktest.Move("d4"), -50, 3)
+
+	path := filepath.Join(t.TempDir(), "experience")
+	if err := s.Save(path); err != nil {
+		t.Fatal("save: ", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal("load: ", err)
+	}
+	for _, s := range []*Store{s, loaded} {
+		if _, v, _, _ := s.Lookup(ai.ExperienceKey(p)); v != 100 {
+			t.Errorf("value=%d without komi", v)
+		}
+		if _, v, _, _ := s.Lookup(ai.ExperienceKey(komi)); v != -50 {
+			t.Errorf("value=%d with komi", v)
+		}
+		if _, _, _, ok := s.Lookup(ai.ExperienceKey(reserves)); ok {
+			t.Error("found a result from a game with different reserves")
+		}
+	}
+}
+
+func TestLookupLockFree(t *testing.T) {
+	s := New()
+	p := taktest.Position(5, "a1 e5 b2")
+	if err := s.RecordSearch(p, taktest.Move("c3"), 100, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	// The search calls Lookup while other goroutines record
+	// results; it must not wait for them.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	done := make(chan bool)
+	go func() {
+		_, _, depth, _ := s.Lookup(p.Hash())
+		s.Avoid(p.Hash())
+		done <- depth == 4
+	}()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Error("lookup did not find the search result")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Lookup waited for the lock")
+	}
+}
+
+func TestRecordGame(t *testing.T) {
+	moves := taktest.Moves("a1 e5 e4 a2 e3 a3 e2 a4 b3 a5")
+	ps := play(t, 5, moves)
+	if over, winner := ps[len(ps)-1].GameOver(); !over || winner != tak.Black {
+		t.Fatal("black did not win")
+	}
+
+	s := New()
+	// We saw the loss coming from e2, but not from e3.
+	s.RecordSearch(ps[4], moves[4], 100, 3)
+	s.RecordSearch(ps[6], moves[6], -ai.WinThreshold-10, 3)
+	if err := s.RecordGame(ps, moves, tak.Black); err != nil {
+		t.Fatal(err)
+	}
+
+	e, ok := s.Get(ps[4])
+	if !ok || len(e.Avoid) != 1 || !e.Avoid[0].Equal(moves[4]) {
+		t.Fatalf("did not avoid the blunder: %+v", e)
+	}
+	if e.Depth != 0 || e.Losses != 1 {
+		t.Fatalf("bad entry: %+v", e)
+	}
+	if e, _ := s.Get(ps[5]); e.Wins != 1 || len(e.Avoid) != 0 {
+		t.Fatalf("bad entry for the winner: %+v", e)
+	}
+	if e, _ := s.Get(ps[6]); e.Depth != 3 || len(e.Avoid) != 0 {
+		t.Fatalf("discarded a correct search: %+v", e)
+	}
+
+	path := filepath.Join(t.TempDir(), "experience")
+	if err := s.Save(path); err != nil {
+		t.Fatal("save: ", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal("load: ", err)
+	}
+	for i, p := range ps[:len(moves)] {
+		want, _ := s.Get(p)
+		got, ok := loaded.Get(p)
+		if !ok || got.Depth != want.Depth || got.Wins != want.Wins ||
+			got.Losses != want.Losses || len(got.Avoid) != len(want.Avoid) {
+			t.Errorf("position %d: got %+v, want %+v", i, got, want)
+		}
+	}
+
+	// The AI won't play a blunder again.
+	cfg := ai.MinimaxConfig{Size: 5, Depth: 3}
+	best := ai.NewMinimax(cfg).GetMove(context.Background(), ps[4])
+	loaded.RecordSearch(ps[4], best, 0, 3)
+	err = loaded.RecordGame(ps[:5], append(moves[:4:4], best), tak.Black)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Experience = loaded
+	if m := ai.NewMinimax(cfg).GetMove(context.Background(), ps[4]); m.Equal(best) {
+		t.Fatalf("played the blunder %s", ptn.FormatMove(m))
+	}
+}
@@ -28,6 +28,7 @@ type AnalyzeRequest struct {
 	Position string `protobuf:"bytes,1,opt,name=position,proto3" json:"position,omitempty"`
 	Depth    int32  `protobuf:"varint,2,opt,name=depth,proto3" json:"depth,omitempty"`
 	Precise  bool   `protobuf:"varint,3,opt,name=precise,proto3" json:"precise,omitempty"`
+	MultiPv  int32  `protobuf:"varint,4,opt,name=multi_pv,json=multiPv,proto3" json:"multi_pv,omitempty"`
 }
 
 func (x *AnalyzeRequest) Reset() {
@@ -83,6 +84,13 @@ func (x *AnalyzeRequest) GetPrecise() bool {
 	return false
 }
 
+func (x *AnalyzeRequest) GetMultiPv() int32 {
+	if x != nil {
+		return x.MultiPv
+	}
+	return 0
+}
+
 type AnalyzeResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -91,6 +99,7 @@ type AnalyzeResponse struct {
 	Pv    []string `protobuf:"bytes,1,rep,name=pv,proto3" json:"pv,omitempty"`
 	Value int64    `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
 	Depth int32    `protobuf:"varint,3,opt,name=depth,proto3" json:"depth,omitempty"`
+	Lines []*Line  `protobuf:"bytes,4,rep,name=lines,proto3" json:"lines,omitempty"`
 }
 
 func (x *AnalyzeResponse) Reset() {
@@ -146,6 +155,68 @@ func (x *AnalyzeResponse) GetDepth() int32 {
 	return 0
 }
 
+func (x *AnalyzeResponse) GetLines() []*Line {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+type Line struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Pv    []string `protobuf:"bytes,1,rep,name=pv,proto3" json:"pv,omitempty"`
+	Value int64    `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *Line) Reset() {
+	*x = Line{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tak_proto_taktician_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Line) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Line) ProtoMessage() {}
+
+func (x *Line) ProtoReflect() protoreflect.Message {
+	mi := &file_tak_proto_taktician_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Line.ProtoReflect.Descriptor instead.
+func (*Line) Descriptor() ([]byte, []int) {
+	return file_tak_proto_taktician_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Line) GetPv() []string {
+	if x != nil {
+		return x.Pv
+	}
+	return nil
+}
+
+func (x *Line) GetValue() int64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
 type CanonicalizeRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -158,7 +229,7 @@ type CanonicalizeRequest struct {
 func (x *CanonicalizeRequest) Reset() {
 	*x = CanonicalizeRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tak_proto_taktician_proto_msgTypes[2]
+		mi := &file_tak_proto_taktician_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -171,7 +242,7 @@ func (x *CanonicalizeRequest) String() string {
 func (*CanonicalizeRequest) ProtoMessage() {}
 
 func (x *CanonicalizeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_tak_proto_taktician_proto_msgTypes[2]
+	mi := &file_tak_proto_taktician_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -184,7 +255,7 @@ func (x *CanonicalizeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CanonicalizeRequest.ProtoReflect.Descriptor instead.
 func (*CanonicalizeRequest) Descriptor() ([]byte, []int) {
-	return file_tak_proto_taktician_proto_rawDescGZIP(), []int{2}
+	return file_tak_proto_taktician_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *CanonicalizeRequest) GetSize() int32 {
@@ -212,7 +283,7 @@ type CanonicalizeResponse struct {
 func (x *CanonicalizeResponse) Reset() {
 	*x = CanonicalizeResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tak_proto_taktician_proto_msgTypes[3]
+		mi := &file_tak_proto_taktician_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -225,7 +296,7 @@ func (x *CanonicalizeResponse) String() string {
 func (*CanonicalizeResponse) ProtoMessage() {}
 
 func (x *CanonicalizeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_tak_proto_taktician_proto_msgTypes[3]
+	mi := &file_tak_proto_taktician_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -238,7 +309,7 @@ func (x *CanonicalizeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CanonicalizeResponse.ProtoReflect.Descriptor instead.
 func (*CanonicalizeResponse) Descriptor() ([]byte, []int) {
-	return file_tak_proto_taktician_proto_rawDescGZIP(), []int{3}
+	return file_tak_proto_taktician_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *CanonicalizeResponse) GetMoves() []string {
@@ -259,7 +330,7 @@ type IsPositionInTakRequest struct {
 func (x *IsPositionInTakRequest) Reset() {
 	*x = IsPositionInTakRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tak_proto_taktician_proto_msgTypes[4]
+		mi := &file_tak_proto_taktician_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -272,7 +343,7 @@ func (x *IsPositionInTakRequest) String() string {
 func (*IsPositionInTakRequest) ProtoMessage() {}
 
 func (x *IsPositionInTakRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_tak_proto_taktician_proto_msgTypes[4]
+	mi := &file_tak_proto_taktician_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -285,7 +356,7 @@ func (x *IsPositionInTakRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use IsPositionInTakRequest.ProtoReflect.Descriptor instead.
 func (*IsPositionInTakRequest) Descriptor() ([]byte, []int) {
-	return file_tak_proto_taktician_proto_rawDescGZIP(), []int{4}
+	return file_tak_proto_taktician_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *IsPositionInTakRequest) GetPosition() string {
@@ -307,7 +378,7 @@ type IsPositionInTakResponse struct {
 func (x *IsPositionInTakResponse) Reset() {
 	*x = IsPositionInTakResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tak_proto_taktician_proto_msgTypes[5]
+		mi := &file_tak_proto_taktician_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -320,7 +391,7 @@ func (x *IsPositionInTakResponse) String() string {
 func (*IsPositionInTakResponse) ProtoMessage() {}
 
 func (x *IsPositionInTakResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_tak_proto_taktician_proto_msgTypes[5]
+	mi := &file_tak_proto_taktician_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -333,7 +404,7 @@ func (x *IsPositionInTakResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use IsPositionInTakResponse.ProtoReflect.Descriptor instead.
 func (*IsPositionInTakResponse) Descriptor() ([]byte, []int) {
-	return file_tak_proto_taktician_proto_rawDescGZIP(), []int{5}
+	return file_tak_proto_taktician_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *IsPositionInTakResponse) GetInTak() bool {
@@ -355,18 +426,25 @@ var File_tak_proto_taktician_proto protoreflect.FileDescriptor
 var file_tak_proto_taktician_proto_rawDesc = []byte{
 	0x0a, 0x19, 0x74, 0x61, 0x6b, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x74, 0x61, 0x6b, 0x74,
 	0x69, 0x63, 0x69, 0x61, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x09, 0x74, 0x61, 0x6b,
-	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x5c, 0x0a, 0x0e, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x77, 0x0a, 0x0e, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a,
 	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x6f, 0x73, 0x69,
 	0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x6f, 0x73, 0x69,
 	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x64, 0x65, 0x70, 0x74, 0x68, 0x18, 0x02, 0x20,
 	0x01, 0x28, 0x05, 0x52, 0x05, 0x64, 0x65, 0x70, 0x74, 0x68, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x72,
 	0x65, 0x63, 0x69, 0x73, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x70, 0x72, 0x65,
-	0x63, 0x69, 0x73, 0x65, 0x22, 0x4d, 0x0a, 0x0f, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x52,
-	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x70, 0x76, 0x18, 0x01, 0x20,
-	0x03, 0x28, 0x09, 0x52, 0x02, 0x70, 0x76, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x14, 0x0a,
-	0x05, 0x64, 0x65, 0x70, 0x74, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x64, 0x65,
-	0x70, 0x74, 0x68, 0x22, 0x3f, 0x0a, 0x13, 0x43, 0x61, 0x6e, 0x6f, 0x6e, 0x69, 0x63, 0x61, 0x6c,
+	0x63, 0x69, 0x73, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x6d, 0x75, 0x6c, 0x74, 0x69, 0x5f, 0x70, 0x76,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x6d, 0x75, 0x6c, 0x74, 0x69, 0x50, 0x76, 0x22,
+	0x74, 0x0a, 0x0f, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x70, 0x76, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x02,
+	0x70, 0x76, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x64, 0x65, 0x70, 0x74,
+	0x68, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x64, 0x65, 0x70, 0x74, 0x68, 0x12, 0x25,
+	0x0a, 0x05, 0x6c, 0x69, 0x6e, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0f, 0x2e,
+	0x74, 0x61, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4c, 0x69, 0x6e, 0x65, 0x52, 0x05,
+	0x6c, 0x69, 0x6e, 0x65, 0x73, 0x22, 0x2c, 0x0a, 0x04, 0x4c, 0x69, 0x6e, 0x65, 0x12, 0x0e, 0x0a,
+	0x02, 0x70, 0x76, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x02, 0x70, 0x76, 0x12, 0x14, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x22, 0x3f, 0x0a, 0x13, 0x43, 0x61, 0x6e, 0x6f, 0x6e, 0x69, 0x63, 0x61, 0x6c,
 	0x69, 0x7a, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69,
 	0x7a, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x14,
 	0x0a, 0x05, 0x6d, 0x6f, 0x76, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x6d,
@@ -381,25 +459,30 @@ var file_tak_proto_taktician_proto_rawDesc = []byte{
 	0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x54, 0x61, 0x6b, 0x18, 0x01, 0x20, 0x01,
 	0x28, 0x08, 0x52, 0x05, 0x69, 0x6e, 0x54, 0x61, 0x6b, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x61, 0x6b,
 	0x4d, 0x6f, 0x76, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x61, 0x6b, 0x4d,
-	0x6f, 0x76, 0x65, 0x32, 0xfe, 0x01, 0x0a, 0x09, 0x54, 0x61, 0x6b, 0x74, 0x69, 0x63, 0x69, 0x61,
+	0x6f, 0x76, 0x65, 0x32, 0xca, 0x02, 0x0a, 0x09, 0x54, 0x61, 0x6b, 0x74, 0x69, 0x63, 0x69, 0x61,
 	0x6e, 0x12, 0x42, 0x0a, 0x07, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x12, 0x19, 0x2e, 0x74,
 	0x61, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65,
 	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x74, 0x61, 0x6b, 0x2e, 0x70, 0x72,
 	0x6f, 0x74, 0x6f, 0x2e, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f,
-	0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x51, 0x0a, 0x0c, 0x43, 0x61, 0x6e, 0x6f, 0x6e, 0x69, 0x63,
-	0x61, 0x6c, 0x69, 0x7a, 0x65, 0x12, 0x1e, 0x2e, 0x74, 0x61, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x2e, 0x43, 0x61, 0x6e, 0x6f, 0x6e, 0x69, 0x63, 0x61, 0x6c, 0x69, 0x7a, 0x65, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x74, 0x61, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x2e, 0x43, 0x61, 0x6e, 0x6f, 0x6e, 0x69, 0x63, 0x61, 0x6c, 0x69, 0x7a, 0x65, 0x52, 0x65,
-	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x5a, 0x0a, 0x0f, 0x49, 0x73, 0x50, 0x6f,
-	0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x6e, 0x54, 0x61, 0x6b, 0x12, 0x21, 0x2e, 0x74, 0x61,
-	0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x49, 0x73, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69,
-	0x6f, 0x6e, 0x49, 0x6e, 0x54, 0x61, 0x6b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x22,
-	0x2e, 0x74, 0x61, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x49, 0x73, 0x50, 0x6f, 0x73,
-	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x6e, 0x54, 0x61, 0x6b, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
-	0x73, 0x65, 0x22, 0x00, 0x42, 0x21, 0x5a, 0x1f, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63,
-	0x6f, 0x6d, 0x2f, 0x6e, 0x65, 0x6c, 0x68, 0x61, 0x67, 0x65, 0x2f, 0x74, 0x61, 0x6b, 0x74, 0x69,
-	0x63, 0x69, 0x61, 0x6e, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x4a, 0x0a, 0x0d, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65,
+	0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x19, 0x2e, 0x74, 0x61, 0x6b, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x2e, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1a, 0x2e, 0x74, 0x61, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x41, 0x6e,
+	0x61, 0x6c, 0x79, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x30,
+	0x01, 0x12, 0x51, 0x0a, 0x0c, 0x43, 0x61, 0x6e, 0x6f, 0x6e, 0x69, 0x63, 0x61, 0x6c, 0x69, 0x7a,
+	0x65, 0x12, 0x1e, 0x2e, 0x74, 0x61, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x43, 0x61,
+	0x6e, 0x6f, 0x6e, 0x69, 0x63, 0x61, 0x6c, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1f, 0x2e, 0x74, 0x61, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x43, 0x61,
+	0x6e, 0x6f, 0x6e, 0x69, 0x63, 0x61, 0x6c, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x22, 0x00, 0x12, 0x5a, 0x0a, 0x0f, 0x49, 0x73, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69,
+	0x6f, 0x6e, 0x49, 0x6e, 0x54, 0x61, 0x6b, 0x12, 0x21, 0x2e, 0x74, 0x61, 0x6b, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x2e, 0x49, 0x73, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x6e,
+	0x54, 0x61, 0x6b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x22, 0x2e, 0x74, 0x61, 0x6b,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x49, 0x73, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f,
+	0x6e, 0x49, 0x6e, 0x54, 0x61, 0x6b, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00,
+	0x42, 0x21, 0x5a, 0x1f, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6e,
+	0x65, 0x6c, 0x68, 0x61, 0x67, 0x65, 0x2f, 0x74, 0x61, 0x6b, 0x74, 0x69, 0x63, 0x69, 0x61, 0x6e,
+	0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -414,27 +497,31 @@ func file_tak_proto_taktician_proto_rawDescGZIP() []byte {
 	return file_tak_proto_taktician_proto_rawDescData
 }
 
-var file_tak_proto_taktician_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_tak_proto_taktician_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_tak_proto_taktician_proto_goTypes = []interface{}{
 	(*AnalyzeRequest)(nil),          // 0: tak.proto.AnalyzeRequest
 	(*AnalyzeResponse)(nil),         // 1: tak.proto.AnalyzeResponse
-	(*CanonicalizeRequest)(nil),     // 2: tak.proto.CanonicalizeRequest
-	(*CanonicalizeResponse)(nil),    // 3: tak.proto.CanonicalizeResponse
-	(*IsPositionInTakRequest)(nil),  // 4: tak.proto.IsPositionInTakRequest
-	(*IsPositionInTakResponse)(nil), // 5: tak.proto.IsPositionInTakResponse
+	(*Line)(nil),                    // 2: tak.proto.Line
+	(*CanonicalizeRequest)(nil),     // 3: tak.proto.CanonicalizeRequest
+	(*CanonicalizeResponse)(nil),    // 4: tak.proto.CanonicalizeResponse
+	(*IsPositionInTakRequest)(nil),  // 5: tak.proto.IsPositionInTakRequest
+	(*IsPositionInTakResponse)(nil), // 6: tak.proto.IsPositionInTakResponse
 }
 var file_tak_proto_taktician_proto_depIdxs = []int32{
-	0, // 0: tak.proto.Taktician.Analyze:input_type -> tak.proto.AnalyzeRequest
-	2, // 1: tak.proto.Taktician.Canonicalize:input_type -> tak.proto.CanonicalizeRequest
-	4, // 2: tak.proto.Taktician.IsPositionInTak:input_type -> tak.proto.IsPositionInTakRequest
-	1, // 3: tak.proto.Taktician.Analyze:output_type -> tak.proto.AnalyzeResponse
-	3, // 4: tak.proto.Taktician.Canonicalize:output_type -> tak.proto.CanonicalizeResponse
-	5, // 5: tak.proto.Taktician.IsPositionInTak:output_type -> tak.proto.IsPositionInTakResponse
-	3, // [3:6] is the sub-list for method output_type
-	0, // [0:3] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	2, // 0: tak.proto.AnalyzeResponse.lines:type_name -> tak.proto.Line
+	0, // 1: tak.proto.Taktician.Analyze:input_type -> tak.proto.AnalyzeRequest
+	0, // 2: tak.proto.Taktician.AnalyzeStream:input_type -> tak.proto.AnalyzeRequest
+	3, // 3: tak.proto.Taktician.Canonicalize:input_type -> tak.proto.CanonicalizeRequest
+	5, // 4: tak.proto.Taktician.IsPositionInTak:input_type -> tak.proto.IsPositionInTakRequest
+	1, // 5: tak.proto.Taktician.Analyze:output_type -> tak.proto.AnalyzeResponse
+	1, // 6: tak.proto.Taktician.AnalyzeStream:output_type -> tak.proto.AnalyzeResponse
+	4, // 7: tak.proto.Taktician.Canonicalize:output_type -> tak.proto.CanonicalizeResponse
+	6, // 8: tak.proto.Taktician.IsPositionInTak:output_type -> tak.proto.IsPositionInTakResponse
+	5, // [5:9] is the sub-list for method output_type
+	1, // [1:5] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
 }
 
 func init() { file_tak_proto_taktician_proto_init() }
@@ -468,7 +555,7 @@ func file_tak_proto_taktician_proto_init() {
 			}
 		}
 		file_tak_proto_taktician_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CanonicalizeRequest); i {
+			switch v := v.(*Line); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -480,7 +567,7 @@ func file_tak_proto_taktician_proto_init() {
 			}
 		}
 		file_tak_proto_taktician_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CanonicalizeResponse); i {
+			switch v := v.(*CanonicalizeRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -492,7 +579,7 @@ func file_tak_proto_taktician_proto_init() {
 			}
 		}
 		file_tak_proto_taktician_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*IsPositionInTakRequest); i {
+			switch v := v.(*CanonicalizeResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -504,6 +591,18 @@ func file_tak_proto_taktician_proto_init() {
 			}
 		}
 		file_tak_proto_taktician_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*IsPositionInTakRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tak_proto_taktician_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*IsPositionInTakResponse); i {
 			case 0:
 				return &v.state
@@ -522,7 +621,7 @@ func file_tak_proto_taktician_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_tak_proto_taktician_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   6,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -23,6 +23,7 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type TakticianClient interface {
 	Analyze(ctx context.Context, in *AnalyzeRequest, opts ...grpc.CallOption) (*AnalyzeResponse, error)
+	AnalyzeStream(ctx context.Context, in *AnalyzeRequest, opts ...grpc.CallOption) (Taktician_AnalyzeStreamClient, error)
 	Canonicalize(ctx context.Context, in *CanonicalizeRequest, opts ...grpc.CallOption) (*CanonicalizeResponse, error)
 	IsPositionInTak(ctx context.Context, in *IsPositionInTakRequest, opts ...grpc.CallOption) (*IsPositionInTakResponse, error)
 }
@@ -44,6 +45,38 @@ func (c *takticianClient) Analyze(ctx context.Context, in *AnalyzeRequest, opts
 	return out, nil
 }
 
+func (c *takticianClient) AnalyzeStream(ctx context.Context, in *AnalyzeRequest, opts ...grpc.CallOption) (Taktician_AnalyzeStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Taktician_ServiceDesc.Streams[0], "/tak.proto.Taktician/AnalyzeStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &takticianAnalyzeStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Taktician_AnalyzeStreamClient interface {
+	Recv() (*AnalyzeResponse, error)
+	grpc.ClientStream
+}
+
+type takticianAnalyzeStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *takticianAnalyzeStreamClient) Recv() (*AnalyzeResponse, error) {
+	m := new(AnalyzeResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *takticianClient) Canonicalize(ctx context.Context, in *CanonicalizeRequest, opts ...grpc.CallOption) (*CanonicalizeResponse, error) {
 	out := new(CanonicalizeResponse)
 	err := c.cc.Invoke(ctx, "/tak.proto.Taktician/Canonicalize", in, out, opts...)
@@ -67,6 +100,7 @@ func (c *takticianClient) IsPositionInTak(ctx context.Context, in *IsPositionInT
 // for forward compatibility
 type TakticianServer interface {
 	Analyze(context.Context, *AnalyzeRequest) (*AnalyzeResponse, error)
+	AnalyzeStream(*AnalyzeRequest, Taktician_AnalyzeStreamServer) error
 	Canonicalize(context.Context, *CanonicalizeRequest) (*CanonicalizeResponse, error)
 	IsPositionInTak(context.Context, *IsPositionInTakRequest) (*IsPositionInTakResponse, error)
 	mustEmbedUnimplementedTakticianServer()
@@ -79,6 +113,9 @@ type UnimplementedTakticianServer struct {
 func (UnimplementedTakticianServer) Analyze(context.Context, *AnalyzeRequest) (*AnalyzeResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Analyze not implemented")
 }
+func (UnimplementedTakticianServer) AnalyzeStream(*AnalyzeRequest, Taktician_AnalyzeStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method AnalyzeStream not implemented")
+}
 func (UnimplementedTakticianServer) Canonicalize(context.Context, *CanonicalizeRequest) (*CanonicalizeResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Canonicalize not implemented")
 }
@@ -116,6 +153,27 @@ func _Taktician_Analyze_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Taktician_AnalyzeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AnalyzeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TakticianServer).AnalyzeStream(m, &takticianAnalyzeStreamServer{stream})
+}
+
+type Taktician_AnalyzeStreamServer interface {
+	Send(*AnalyzeResponse) error
+	grpc.ServerStream
+}
+
+type takticianAnalyzeStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *takticianAnalyzeStreamServer) Send(m *AnalyzeResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _Taktician_Canonicalize_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CanonicalizeRequest)
 	if err := dec(in); err != nil {
@@ -172,6 +230,12 @@ var Taktician_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _Taktician_IsPositionInTak_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "AnalyzeStream",
+			Handler:       _Taktician_AnalyzeStream_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "tak/proto/taktician.proto",
 }
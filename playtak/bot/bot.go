@@ -21,6 +21,7 @@ type Game struct {
 	Color    tak.Color
 	Size     int
 	Time     time.Duration
+	Komi     int
 	Result   string
 
 	times struct {
@@ -74,6 +75,10 @@ func parseGameStart(line string) *Game {
 
 	secs, _ := strconv.Atoi(bits[8])
 	g.Time = time.Duration(secs) * time.Second
+	if len(bits) > 9 {
+		// Newer servers append the komi, in half-flats
+		g.Komi, _ = strconv.Atoi(bits[9])
+	}
 	return &g
 }
 
@@ -91,10 +96,12 @@ func parseObserveStart(line string) *Game {
 }
 
 func config(b Bot, g *Game) tak.Config {
-	if cfg, ok := b.(Configger); ok {
-		return cfg.Config(g.Size)
+	cfg := tak.Config{Size: g.Size}
+	if c, ok := b.(Configger); ok {
+		cfg = c.Config(g.Size)
 	}
-	return tak.Config{Size: g.Size}
+	cfg.Komi = g.Komi
+	return cfg
 }
 
 func PlayGame(c Client, b Bot, line string) {
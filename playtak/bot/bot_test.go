@@ -178,3 +178,17 @@ func TestResume(t *testing.T) {
 	assertPosition(t, bot.game.Positions[len(bot.game.Positions)-1],
 		`x4,1/x4,1C/x4,1/2,2,x2,1/2,2,x2,1 2 5`)
 }
+
+func TestParseGameStart(t *testing.T) {
+	g := parseGameStart(startLine)
+	if g.Size != 5 || g.Color != tak.White || g.Opponent != "HonestJoe" || g.Komi != 0 {
+		t.Fatalf("bad game: %#v", g)
+	}
+	g = parseGameStart("Game Start 100 6 HonestJoe vs Taktician black 900 4 30 1")
+	if g.Size != 6 || g.Color != tak.Black || g.Opponent != "HonestJoe" || g.Komi != 4 {
+		t.Fatalf("bad game: %#v", g)
+	}
+	if cfg := config(&TestBotStatic{}, g); cfg.Komi != 4 {
+		t.Fatalf("config komi=%d", cfg.Komi)
+	}
+}
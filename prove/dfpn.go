@@ -2,6 +2,8 @@ package prove
 
 import (
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -26,15 +28,49 @@ type DFPNStats struct {
 	Solved   uint64
 	Hits     uint64
 	Miss     uint64
+
+	// Overwrites counts the table entries replaced by an entry
+	// for a different position, and SolvedOverwrites those of them
+	// that held a proof or disproof. Many of the latter suggest the
+	// table is too small for the search.
+	Overwrites       uint64
+	SolvedOverwrites uint64
+}
+
+// Add adds the counts in `o` to `s`.
+func (s *DFPNStats) Add(o *DFPNStats) {
+	s.Work += o.Work
+	s.Repetition += o.Repetition
+	s.Terminal += o.Terminal
+	s.Solved += o.Solved
+	s.Hits += o.Hits
+	s.Miss += o.Miss
+	s.Overwrites += o.Overwrites
+	s.SolvedOverwrites += o.SolvedOverwrites
 }
 
 type DFPNSolver struct {
 	attacker tak.Color
-	table    dfpnTable
+	table    *dfpnTable
 	debug    int
 
 	stats DFPNStats
 
+	// work counts the nodes this thread has searched, against
+	// maxWork; after a parallel search, stats.Work also counts the
+	// helpers'.
+	work     uint64
+	maxWork  uint64
+	maxTime  time.Duration
+	deadline time.Time
+	stopped  bool
+
+	// helpers search the same position on other threads, sharing
+	// our table; cancel, if set, is raised by the first thread to
+	// finish, to stop the others.
+	helpers []*DFPNSolver
+	cancel  *int32
+
 	stack   []dfpnFrame
 	killers []tak.Move
 
@@ -52,6 +88,21 @@ type DFPNConfig struct {
 	Debug    int
 	Attacker tak.Color
 	TableMem int64
+
+	// MaxWork and MaxTime, if nonzero, bound the nodes searched
+	// and the time taken by each call to Prove. A search that
+	// runs out returns EvalUnknown.
+	MaxWork uint64
+	MaxTime time.Duration
+
+	// Threads is the number of threads to search with. Each
+	// searches from the root, sharing the table; proof numbers of
+	// positions that other threads are searching are inflated,
+	// steering the threads to different parts of the tree. The
+	// first thread to prove or disprove the root stops the rest.
+	// MaxWork is shared out evenly between the threads. Values
+	// less than 2 search on a single thread.
+	Threads int
 }
 
 type proofNumbers struct {
@@ -68,32 +119,214 @@ func (pn *proofNumbers) solved() bool {
 	return pn.phi == 0 || pn.delta == 0
 }
 
+// dfpnSlot is the in-memory representation of an entry. The table
+// is shared, without locks, between all of the threads of a
+// DFPNSolver, so, like the minimax table, it uses the "lockless
+// hashing" scheme of Hyatt and Mann: each word is read and written
+// atomically, and `check` stores the position hash XORed with the
+// data words, so that a slot torn by concurrent writers reads as a
+// miss.
+type dfpnSlot struct {
+	check  uint64
+	bounds uint64
+	work   uint64
+	move   uint64
+}
+
+func packMove(m tak.Move) uint64 {
+	return uint64(uint8(m.X)) |
+		uint64(uint8(m.Y))<<8 |
+		uint64(m.Type)<<16 |
+		uint64(m.Slides)<<24
+}
+
+func unpackMove(v uint64) tak.Move {
+	return tak.Move{
+		X:      int8(uint8(v)),
+		Y:      int8(uint8(v >> 8)),
+		Type:   tak.MoveType(v >> 16),
+		Slides: tak.Slides(v >> 24),
+	}
+}
+
+// The work word of a slot holds the entry's work in its low bits,
+// and the generation in which it was stored in the top byte.
+const (
+	genShift = 56
+	workMask = 1<<genShift - 1
+)
+
+func (s *dfpnSlot) load(out *entry) {
+	check := atomic.LoadUint64(&s.check)
+	bounds := atomic.LoadUint64(&s.bounds)
+	work := atomic.LoadUint64(&s.work)
+	move := atomic.LoadUint64(&s.move)
+
+	out.hash = check ^ bounds ^ work ^ move
+	out.bounds = proofNumbers{phi: uint32(bounds), delta: uint32(bounds >> 32)}
+	out.work = work & workMask
+	out.gen = uint8(work >> genShift)
+	out.pv = unpackMove(move)
+}
+
+func (s *dfpnSlot) store(e *entry) {
+	bounds := uint64(e.bounds.phi) | uint64(e.bounds.delta)<<32
+	work := e.work
+	if work > workMask {
+		work = workMask
+	}
+	work |= uint64(e.gen) << genShift
+	move := packMove(e.pv)
+	atomic.StoreUint64(&s.bounds, bounds)
+	atomic.StoreUint64(&s.work, work)
+	atomic.StoreUint64(&s.move, move)
+	atomic.StoreUint64(&s.check, e.hash^bounds^work^move)
+}
+
+// dfpnTable is a fixed-size table of two-slot buckets. The first
+// slot of a bucket holds whichever of its entries is more valuable
+// to keep (see `keep`), and the second slot the most recently
+// stored of the rest, so that the table holds on to expensive and
+// solved positions while still caching the positions the search is
+// working on.
 type dfpnTable struct {
-	entries []entry
+	entries []dfpnSlot
+
+	// gen is the generation of the table, which Prove advances,
+	// so that the unsolved entries of past searches are the first
+	// to be replaced.
+	gen uint32
+
+	// busy, if non-nil, counts the threads searching beneath the
+	// position in each slot, which guides the threads apart.
+	busy []int32
+}
+
+func newTable(mem int64) *dfpnTable {
+	n := mem / int64(unsafe.Sizeof(dfpnSlot{}))
+	n &^= 1
+	if n < 2 {
+		n = 2
+	}
+	return &dfpnTable{entries: make([]dfpnSlot, n)}
+}
+
+func (t *dfpnTable) bucket(h uint64) []dfpnSlot {
+	i := 2 * (h % uint64(len(t.entries)/2))
+	return t.entries[i : i+2]
 }
 
 func (t *dfpnTable) lookup(g *tak.Position) (entry, bool) {
-	e := t.entries[g.Hash()%uint64(len(t.entries))]
-	if e.hash == g.Hash() {
-		return e, true
+	var e entry
+	b := t.bucket(g.Hash())
+	for i := range b {
+		b[i].load(&e)
+		if e.hash == g.Hash() {
+			return e, true
+		}
 	}
 	return entry{}, false
 }
 
-func (t *dfpnTable) store(e *entry) bool {
-	target := &t.entries[e.hash%uint64(len(t.entries))]
-	if target.work <= e.work {
-		*target = *e
-		return true
+// advance starts a new generation.
+func (t *dfpnTable) advance() {
+	atomic.AddUint32(&t.gen, 1)
+}
+
+// keep reports whether `a` is more valuable to keep than `b`: a
+// solved entry over an unsolved one, an unsolved entry from this
+// generation over one from an earlier search, and otherwise the
+// entry that took more work.
+func (t *dfpnTable) keep(a, b *entry) bool {
+	if a.bounds.solved() != b.bounds.solved() {
+		return a.bounds.solved()
 	}
+	if !a.bounds.solved() {
+		gen := uint8(atomic.LoadUint32(&t.gen))
+		if (a.gen == gen) != (b.gen == gen) {
+			return a.gen == gen
+		}
+	}
+	return a.work >= b.work
+}
 
-	return false
+func (t *dfpnTable) store(e *entry, stats *DFPNStats) {
+	e.gen = uint8(atomic.LoadUint32(&t.gen))
+	b := t.bucket(e.hash)
+	var first, second entry
+	b[0].load(&first)
+	b[1].load(&second)
+
+	for i, old := range [...]*entry{&first, &second} {
+		if old.hash != e.hash {
+			continue
+		}
+		if old.bounds.solved() && !e.bounds.solved() {
+			// Another thread has solved this position.
+			return
+		}
+		if i == 1 && t.keep(e, &first) {
+			// Promote the entry, and demote the first.
+			b[0].store(e)
+			b[1].store(&first)
+			return
+		}
+		b[i].store(e)
+		return
+	}
+
+	if first.hash == 0 {
+		b[0].store(e)
+		return
+	}
+	victim := &second
+	if t.keep(e, &first) {
+		b[0].store(e)
+		if first.hash != 0 {
+			b[1].store(&first)
+		}
+	} else {
+		b[1].store(e)
+	}
+	if victim.hash != 0 {
+		stats.Overwrites++
+		if victim.bounds.solved() {
+			stats.SolvedOverwrites++
+		}
+	}
+}
+
+// enter and leave count a thread in or out of the search beneath
+// the position with hash `h`.
+func (t *dfpnTable) enter(h uint64) {
+	atomic.AddInt32(&t.busy[h%uint64(len(t.busy))], 1)
+}
+
+func (t *dfpnTable) leave(h uint64) {
+	atomic.AddInt32(&t.busy[h%uint64(len(t.busy))], -1)
+}
+
+// virtualDelta returns the delta of a child for the purposes of
+// selecting which child to search: inflated, as in Hoki et al's
+// SPDFPN, by the number of other threads already searching it, so
+// that threads prefer to work on different parts of the tree.
+func (t *dfpnTable) virtualDelta(ch *dfpnChild) uint32 {
+	delta := ch.data.bounds.delta
+	if t.busy == nil || delta == 0 || delta >= INFINITY {
+		return delta
+	}
+	n := atomic.LoadInt32(&t.busy[ch.data.hash%uint64(len(t.busy))])
+	if n <= 0 {
+		return delta
+	}
+	return min(delta+uint32(n)*(delta/4+1), INFINITY-1)
 }
 
 type entry struct {
 	bounds proofNumbers
 	hash   uint64
 	work   uint64
+	gen    uint8
 	pv     tak.Move
 	//	child  uint8
 }
@@ -110,30 +343,72 @@ func NewDFPN(cfg *DFPNConfig) *DFPNSolver {
 	if cfg.TableMem <= 0 {
 		cfg.TableMem = defaultTableMem
 	}
-	return &DFPNSolver{
+	table := newTable(cfg.TableMem)
+	if cfg.Threads > 1 {
+		table.busy = make([]int32, len(table.entries))
+	}
+	d := &DFPNSolver{
 		attacker: cfg.Attacker,
-		table: dfpnTable{
-			entries: make([]entry, cfg.TableMem/int64(unsafe.Sizeof(entry{}))),
-		},
-		debug: cfg.Debug,
+		table:    table,
+		debug:    cfg.Debug,
+		maxWork:  cfg.MaxWork,
+		maxTime:  cfg.MaxTime,
+	}
+	for i := 1; i < cfg.Threads; i++ {
+		d.helpers = append(d.helpers, &DFPNSolver{
+			attacker: cfg.Attacker,
+			table:    table,
+			maxTime:  cfg.MaxTime,
+		})
 	}
+	if cfg.MaxWork > 0 && cfg.Threads > 1 {
+		d.maxWork = cfg.MaxWork / uint64(cfg.Threads)
+		for _, h := range d.helpers {
+			h.maxWork = d.maxWork
+		}
+	}
+	return d
 }
 
-func (d *DFPNSolver) Prove(g *tak.Position) (ProofResult, DFPNStats) {
-	if d.attacker == tak.NoColor {
-		d.attacker = g.ToMove()
-	}
+var rootBounds = proofNumbers{phi: INFINITY / 2, delta: INFINITY / 2}
+
+// reset prepares the solver to search `g`.
+func (d *DFPNSolver) reset(g *tak.Position, start time.Time) {
 	d.c = bitboard.Precompute(uint(g.Size()))
 	d.stats = DFPNStats{}
-
+	d.work = 0
 	d.stack = nil
-	start := time.Now()
-	entry, work := d.mid(g, proofNumbers{phi: INFINITY / 2, delta: INFINITY / 2}, entry{
+	d.stopped = false
+	if d.maxTime > 0 {
+		d.deadline = start.Add(d.maxTime)
+	}
+}
+
+// search runs the search from the root, `g`.
+func (d *DFPNSolver) search(g *tak.Position) entry {
+	e, _ := d.mid(g, rootBounds, entry{
 		hash:   g.Hash(),
 		work:   0,
 		bounds: proofNumbers{phi: 1, delta: 1},
 	})
-	d.stats.Work = work
+	return e
+}
+
+func (d *DFPNSolver) Prove(g *tak.Position) (ProofResult, DFPNStats) {
+	if d.attacker == tak.NoColor {
+		d.attacker = g.ToMove()
+	}
+	start := time.Now()
+	d.reset(g, start)
+	d.table.advance()
+
+	var entry entry
+	if len(d.helpers) == 0 {
+		d.cancel = nil
+		entry = d.search(g)
+	} else {
+		entry = d.parallelSearch(g, start)
+	}
 	duration := time.Since(start)
 	var result Evaluation = EvalUnknown
 	if entry.bounds.phi == 0 {
@@ -150,6 +425,57 @@ func (d *DFPNSolver) Prove(g *tak.Position) (ProofResult, DFPNStats) {
 	}, d.stats
 }
 
+// parallelSearch searches `g` on this thread and every helper, until
+// one of them proves or disproves it, or they run out of budget, and
+// returns the root's entry.
+func (d *DFPNSolver) parallelSearch(g *tak.Position, start time.Time) entry {
+	var stop int32
+	var wg sync.WaitGroup
+	d.cancel = &stop
+	for _, h := range d.helpers {
+		h.attacker = d.attacker
+		h.reset(g, start)
+		h.cancel = &stop
+		wg.Add(1)
+		go func(h *DFPNSolver, g *tak.Position) {
+			defer wg.Done()
+			h.search(g)
+			atomic.StoreInt32(&stop, 1)
+		}(h, g.Clone())
+	}
+	out := d.search(g)
+	atomic.StoreInt32(&stop, 1)
+	wg.Wait()
+	for _, h := range d.helpers {
+		d.stats.Add(&h.stats)
+	}
+	if !out.bounds.solved() {
+		if e, ok := d.table.lookup(g); ok && e.bounds.solved() {
+			out = e
+		}
+	}
+	return out
+}
+
+// outOfBudget reports whether the search has used up its node or
+// time budget, or another thread has finished. mid calls it after
+// each child it searches, whether or not that did any work.
+func (d *DFPNSolver) outOfBudget() bool {
+	if d.stopped {
+		return true
+	}
+	if d.cancel != nil && atomic.LoadInt32(d.cancel) != 0 {
+		d.stopped = true
+		return true
+	}
+	if d.maxWork > 0 && d.work >= d.maxWork {
+		d.stopped = true
+	} else if d.maxTime > 0 && time.Now().After(d.deadline) {
+		d.stopped = true
+	}
+	return d.stopped
+}
+
 func (d *DFPNSolver) checkRepetition() bool {
 	if len(d.stack) == 0 {
 		return false
@@ -235,6 +561,8 @@ func (d *DFPNSolver) mid(g *tak.Position, bounds proofNumbers, current entry) (e
 	}
 
 	localWork := uint64(1)
+	d.stats.Work++
+	d.work++
 	// compute children
 	var allocChildren [100]dfpnChild
 	children := allocChildren[:0]
@@ -288,6 +616,7 @@ func (d *DFPNSolver) mid(g *tak.Position, bounds proofNumbers, current entry) (e
 		}
 
 		if childEntry.bounds.delta == 0 {
+			current.pv = m
 			break
 		}
 	}
@@ -303,12 +632,22 @@ func (d *DFPNSolver) mid(g *tak.Position, bounds proofNumbers, current entry) (e
 		current.pv = children[best_idx].move
 
 		d.stack = append(d.stack, dfpnFrame{children[best_idx].g, children[best_idx].move})
+		if d.table.busy != nil {
+			d.table.enter(children[best_idx].data.hash)
+		}
 		newEntry, work := d.mid(children[best_idx].g, childBounds, children[best_idx].data)
+		if d.table.busy != nil {
+			d.table.leave(children[best_idx].data.hash)
+		}
 		d.stack = d.stack[:len(d.stack)-1]
 
 		children[best_idx].data = newEntry
 		localWork += work
 		current.work += work
+		if d.outOfBudget() {
+			current.bounds = computePNs(children)
+			break
+		}
 	}
 
 	if current.bounds.phi == 0 {
@@ -318,7 +657,7 @@ func (d *DFPNSolver) mid(g *tak.Position, bounds proofNumbers, current entry) (e
 		d.killers[depth] = current.pv
 	}
 
-	d.table.store(&current)
+	d.table.store(&current, &d.stats)
 
 	return current, uint64(localWork)
 }
@@ -350,19 +689,42 @@ func min(l, r uint32) uint32 {
 	return r
 }
 
+// selectChild picks the child to search next, and returns the
+// bounds to search it within.
 func (d *DFPNSolver) selectChild(children []dfpnChild,
 	bounds proofNumbers,
 	pns proofNumbers) (int, proofNumbers) {
+	best, childBounds := childThresholds(children, bounds, pns, d.table.virtualDelta)
+	if children[best].data.bounds.exceeded(childBounds) {
+		// The inflated deltas chose a child already past its
+		// threshold, whose search would do no work. The child
+		// with the smallest real delta is always within it.
+		best, childBounds = childThresholds(children, bounds, pns, realDelta)
+	}
+	return best, childBounds
+}
+
+func realDelta(ch *dfpnChild) uint32 {
+	return ch.data.bounds.delta
+}
+
+// childThresholds picks the child with the smallest delta, as
+// measured by `delta`, and computes its thresholds.
+func childThresholds(children []dfpnChild,
+	bounds proofNumbers,
+	pns proofNumbers,
+	delta func(*dfpnChild) uint32) (int, proofNumbers) {
 	delta1, delta2 := INFINITY, INFINITY
 
 	best := -1
-	for i, ch := range children {
-		if ch.data.bounds.delta < delta1 {
+	for i := range children {
+		delta := delta(&children[i])
+		if delta < delta1 {
 			best = i
 			delta2 = delta1
-			delta1 = ch.data.bounds.delta
-		} else if ch.data.bounds.delta < delta2 {
-			delta2 = ch.data.bounds.delta
+			delta1 = delta
+		} else if delta < delta2 {
+			delta2 = delta
 		}
 	}
 
@@ -0,0 +1,169 @@
+package prove
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nelhage/taktician/ptn"
+)
+
+func TestTableReplacement(t *testing.T) {
+	table := newTable(0)
+	var stats DFPNStats
+	lookup := func(h uint64) (entry, bool) {
+		for i := range table.entries {
+			var e entry
+			table.entries[i].load(&e)
+			if e.hash == h {
+				return e, true
+			}
+		}
+		return entry{}, false
+	}
+
+	// Every hash falls into the table's one bucket.
+	table.store(&entry{hash: 1, work: 100, bounds: proofNumbers{3, 4}}, &stats)
+	table.store(&entry{hash: 2, work: 1, bounds: proofNumbers{0, INFINITY}}, &stats)
+	if stats.Overwrites != 0 {
+		t.Fatalf("overwrites=%d, want 0", stats.Overwrites)
+	}
+	if e, _ := lookup(2); !e.bounds.solved() {
+		t.Fatalf("lost the solved entry")
+	}
+
+	// A cheap unsolved entry replaces the expensive one, not the
+	// solved one.
+	table.store(&entry{hash: 3, work: 5, bounds: proofNumbers{1, 1}}, &stats)
+	if _, ok := lookup(2); !ok {
+		t.Errorf("replaced the solved entry")
+	}
+	if _, ok := lookup(3); !ok {
+		t.Errorf("did not store the new entry")
+	}
+	if stats.Overwrites != 1 || stats.SolvedOverwrites != 0 {
+		t.Errorf("overwrites=%d/%d, want 1/0", stats.Overwrites, stats.SolvedOverwrites)
+	}
+
+	// An unsolved entry does not replace a proof of the same
+	// position.
+	table.store(&entry{hash: 2, work: 50, bounds: proofNumbers{2, 2}}, &stats)
+	if e, _ := lookup(2); !e.bounds.solved() {
+		t.Errorf("replaced a proof with an unsolved entry")
+	}
+
+	// An unsolved entry from this generation is worth more than
+	// one from an earlier search, however expensive.
+	table = newTable(0)
+	table.store(&entry{hash: 1, work: 1000, bounds: proofNumbers{3, 4}}, &stats)
+	table.advance()
+	table.store(&entry{hash: 2, work: 10, bounds: proofNumbers{3, 4}}, &stats)
+	table.store(&entry{hash: 3, work: 1, bounds: proofNumbers{3, 4}}, &stats)
+	if _, ok := lookup(1); ok {
+		t.Errorf("kept the entry from the last generation")
+	}
+	if e, _ := lookup(2); e.work != 10 {
+		t.Errorf("work=%d, want 10", e.work)
+	}
+}
+
+func TestSmallTable(t *testing.T) {
+	p, err := ptn.ParseTPS("1S,2,x3/1212C,2,x2,1/2,x2,1C,x/2221,x2,1,112/x4,1 2 14")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := NewDFPN(&DFPNConfig{TableMem: 16 << 10})
+	out, stats := solver.Prove(p)
+	if out.Result != EvalTrue {
+		t.Fatalf("prove: %s", out.Result)
+	}
+	if stats.Overwrites == 0 {
+		t.Errorf("no overwrites in a %d-entry table", len(solver.table.entries))
+	}
+	tree, _, err := solver.ProofTree(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyProof(p, p.ToMove(), tree); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+}
+
+func TestSelectChildBusy(t *testing.T) {
+	d := &DFPNSolver{table: newTable(0)}
+	d.table.busy = make([]int32, 16)
+	children := []dfpnChild{
+		{data: entry{hash: 1, bounds: proofNumbers{1, 10}}},
+		{data: entry{hash: 2, bounds: proofNumbers{1, 12}}},
+	}
+	// Other threads searching the first child inflate its delta
+	// past the second's, but the second is already past the
+	// threshold the first's real delta sets.
+	d.table.busy[1] = 3
+	bounds := proofNumbers{phi: 11, delta: 100}
+	pns := computePNs(children)
+	best, childBounds := d.selectChild(children, bounds, pns)
+	if best != 0 {
+		t.Errorf("selected child %d, want 0", best)
+	}
+	if children[best].data.bounds.exceeded(childBounds) {
+		t.Errorf("selected child (%d,%d) exceeds its bounds (%d,%d)",
+			children[best].data.bounds.phi, children[best].data.bounds.delta,
+			childBounds.phi, childBounds.delta)
+	}
+}
+
+func TestParallelSmallTable(t *testing.T) {
+	p, err := ptn.ParseTPS("2,x4,1/2,x2,1,2C,x/2,x,2S,21C,1,x/1,x,12,112S,1,1/x2,21,1221,2,x/x3,1,2,x 1 8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := NewDFPN(&DFPNConfig{
+		TableMem: 64 << 10,
+		Threads:  2,
+		MaxTime:  time.Second,
+	})
+	done := make(chan struct{})
+	go func() {
+		solver.Prove(p)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("search ran past its time limit")
+	}
+}
+
+func TestParallelProofTreeBudget(t *testing.T) {
+	p, err := ptn.ParseTPS("1S,2,x3/1212C,2,x2,1/2,x2,1C,x/2221,x2,1,112/x4,1 2 14")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := NewDFPN(&DFPNConfig{
+		TableMem: 16 << 10,
+		Threads:  2,
+		MaxWork:  1 << 20,
+	})
+	out, stats := solver.Prove(p)
+	if out.Result != EvalTrue {
+		t.Fatalf("prove: %s", out.Result)
+	}
+	if stats.Overwrites == 0 {
+		t.Errorf("no overwrites in a %d-entry table", len(solver.table.entries))
+	}
+	// The merged stats count the helper's work as well as ours,
+	// which must not count against our share of the budget when
+	// ProofTree, with an empty table, proves each position again.
+	solver.stats.Work = solver.maxWork
+	solver.table = newTable(16 << 10)
+	tree, extra, err := solver.ProofTree(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extra.Work == 0 {
+		t.Errorf("ProofTree reported no work")
+	}
+	if err := VerifyProof(p, p.ToMove(), tree); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package prove
+
+import (
+	"fmt"
+
+	"github.com/nelhage/taktician/bitboard"
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+// A ProofTree is a proof that the attacker wins from a position: a
+// move for the attacker, and a refutation of each defence to it.
+type ProofTree struct {
+	Move tak.Move
+	// Defences holds every legal reply to Move, unless Move ends
+	// the game.
+	Defences []Defence
+}
+
+type Defence struct {
+	Move tak.Move
+	// Reply is nil if Move ends the game.
+	Reply *ProofTree
+}
+
+// Plies returns the length, in plies, of the longest line in the
+// tree.
+func (t *ProofTree) Plies() int {
+	n := 1
+	for _, d := range t.Defences {
+		if l := 1 + d.Plies(); 1+l > n {
+			n = 1 + l
+		}
+	}
+	return n
+}
+
+// Plies returns the length, in plies, of the longest line after the
+// defence.
+func (d *Defence) Plies() int {
+	if d.Reply == nil {
+		return 0
+	}
+	return d.Reply.Plies()
+}
+
+// ProofTree reconstructs the proof that the attacker wins from `g`,
+// which Prove has proven. It takes the attacker's moves from the
+// table, proving again any position whose entry has been
+// overwritten; those searches count against the budget of the call
+// to Prove, and ProofTree returns their stats.
+func (d *DFPNSolver) ProofTree(g *tak.Position) (*ProofTree, DFPNStats, error) {
+	if d.attacker == tak.NoColor {
+		d.attacker = g.ToMove()
+	}
+	d.c = bitboard.Precompute(uint(g.Size()))
+	d.stats = DFPNStats{}
+	if g.ToMove() != d.attacker {
+		return nil, d.stats, fmt.Errorf("%s: not the attacker's move", ptn.FormatTPS(g))
+	}
+	t, err := d.proofTree(g, make(map[uint64]bool))
+	return t, d.stats, err
+}
+
+// proofMove returns the attacker's winning move from `g`.
+func (d *DFPNSolver) proofMove(g *tak.Position) (tak.Move, bool) {
+	if e, ok := d.table.lookup(g); ok && e.bounds.phi == 0 && e.pv.Type != 0 {
+		return e.pv, true
+	}
+	d.stack = d.stack[:0]
+	d.stopped = false
+	d.cancel = nil
+	e := d.search(g)
+	return e.pv, e.bounds.phi == 0
+}
+
+func (d *DFPNSolver) proofTree(g *tak.Position, path map[uint64]bool) (*ProofTree, error) {
+	if path[g.Hash()] {
+		return nil, fmt.Errorf("%s: proof repeats a position", ptn.FormatTPS(g))
+	}
+	path[g.Hash()] = true
+	defer delete(path, g.Hash())
+
+	m, ok := d.proofMove(g)
+	if !ok {
+		return nil, fmt.Errorf("%s: not proven", ptn.FormatTPS(g))
+	}
+	child, err := g.Move(m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %v", ptn.FormatTPS(g), ptn.FormatMove(m), err)
+	}
+	t := &ProofTree{Move: m}
+	if over, winner := child.GameOver(); over {
+		if winner != d.attacker {
+			return nil, fmt.Errorf("%s: %s does not win", ptn.FormatTPS(g), ptn.FormatMove(m))
+		}
+		return t, nil
+	}
+
+	var it tak.MoveIterator
+	it.Reset(child, tak.Move{})
+	for m, ok := it.Next(); ok; m, ok = it.Next() {
+		next, err := child.Move(m)
+		if err != nil {
+			continue
+		}
+		def := Defence{Move: m}
+		if over, winner := next.GameOver(); over {
+			if winner != d.attacker {
+				return nil, fmt.Errorf("%s: defence %s holds", ptn.FormatTPS(child), ptn.FormatMove(m))
+			}
+		} else if def.Reply, err = d.proofTree(next, path); err != nil {
+			return nil, err
+		}
+		t.Defences = append(t.Defences, def)
+	}
+	return t, nil
+}
+
+// VerifyProof checks, independently of the solver, that `t` proves
+// that `attacker` wins from `p`: that each of its moves is legal,
+// that it answers every legal defence, and that every line ends in
+// a win for the attacker.
+func VerifyProof(p *tak.Position, attacker tak.Color, t *ProofTree) error {
+	if p.ToMove() != attacker {
+		return fmt.Errorf("%s: not the attacker's move", ptn.FormatTPS(p))
+	}
+	child, err := p.Move(t.Move)
+	if err != nil {
+		return fmt.Errorf("%s: %s: %v", ptn.FormatTPS(p), ptn.FormatMove(t.Move), err)
+	}
+	if over, winner := child.GameOver(); over {
+		if winner != attacker {
+			return fmt.Errorf("%s: %s does not win", ptn.FormatTPS(p), ptn.FormatMove(t.Move))
+		}
+		if len(t.Defences) != 0 {
+			return fmt.Errorf("%s: %s ends the game, but has defences", ptn.FormatTPS(p), ptn.FormatMove(t.Move))
+		}
+		return nil
+	}
+
+	answered := make(map[string]*Defence, len(t.Defences))
+	for i := range t.Defences {
+		answered[ptn.FormatMove(t.Defences[i].Move)] = &t.Defences[i]
+	}
+	legal := 0
+	for _, m := range child.AllMoves(nil) {
+		next, err := child.Move(m)
+		if err != nil {
+			continue
+		}
+		legal++
+		d, ok := answered[ptn.FormatMove(m)]
+		if !ok {
+			return fmt.Errorf("%s: no answer to %s", ptn.FormatTPS(child), ptn.FormatMove(m))
+		}
+		if over, winner := next.GameOver(); over {
+			if winner != attacker {
+				return fmt.Errorf("%s: defence %s holds", ptn.FormatTPS(child), ptn.FormatMove(m))
+			}
+			continue
+		}
+		if d.Reply == nil {
+			return fmt.Errorf("%s: no reply to %s", ptn.FormatTPS(child), ptn.FormatMove(m))
+		}
+		if err := VerifyProof(next, attacker, d.Reply); err != nil {
+			return err
+		}
+	}
+	if legal != len(answered) || legal != len(t.Defences) {
+		return fmt.Errorf("%s: %d defences, but %d legal moves", ptn.FormatTPS(child), len(t.Defences), legal)
+	}
+	return nil
+}
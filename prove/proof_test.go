@@ -0,0 +1,80 @@
+package prove
+
+import (
+	"testing"
+
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+func TestProofTree(t *testing.T) {
+	cases := []struct {
+		tps   string
+		move  string
+		plies int
+	}{
+		{"2,x2,121C,1/x2,2,12,1/x2,2,12S,2/x3,1,1/x4,1 1 2", "2d5-11", 7},
+		{"1,1,x3/1,1,x,2,1/x,1,x3/x,2,x2,2/2,1C,2S,2,2 1 3", "b3+", 5},
+		{"1S,2,x3/1212C,2,x2,1/2,x2,1C,x/2221,x2,1,112/x4,1 2 14", "4a4-22", 5},
+	}
+	for _, tc := range cases {
+		p, err := ptn.ParseTPS(tc.tps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		solver := NewDFPN(&DFPNConfig{})
+		if out, _ := solver.Prove(p); out.Result != EvalTrue {
+			t.Errorf("%s: %s", tc.tps, out.Result)
+			continue
+		}
+		tree, _, err := solver.ProofTree(p)
+		if err != nil {
+			t.Errorf("%s: %v", tc.tps, err)
+			continue
+		}
+		if got := ptn.FormatMove(tree.Move); got != tc.move {
+			t.Errorf("%s: move=%s, want %s", tc.tps, got, tc.move)
+		}
+		if tree.Plies() != tc.plies {
+			t.Errorf("%s: plies=%d, want %d", tc.tps, tree.Plies(), tc.plies)
+		}
+		if err := VerifyProof(p, p.ToMove(), tree); err != nil {
+			t.Errorf("%s: verify: %v", tc.tps, err)
+		}
+
+		// A proof missing a defence, or with a losing move, is
+		// rejected.
+		bad := *tree
+		bad.Defences = bad.Defences[1:]
+		if err := VerifyProof(p, p.ToMove(), &bad); err == nil {
+			t.Errorf("%s: verified a proof missing %s", tc.tps, ptn.FormatMove(tree.Defences[0].Move))
+		}
+		bad = *tree
+		bad.Move = tak.Move{Type: tak.Pass}
+		if err := VerifyProof(p, p.ToMove(), &bad); err == nil {
+			t.Errorf("%s: verified a proof that passes", tc.tps)
+		}
+	}
+}
+
+func TestProofTreeOverwritten(t *testing.T) {
+	p, err := ptn.ParseTPS("2,x2,121C,1/x2,2,12,1/x2,2,12S,2/x3,1,1/x4,1 1 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := NewDFPN(&DFPNConfig{})
+	if out, _ := solver.Prove(p); out.Result != EvalTrue {
+		t.Fatalf("prove: %s", out.Result)
+	}
+	// Lose the proof; ProofTree must search again.
+	for i := range solver.table.entries {
+		solver.table.entries[i] = dfpnSlot{}
+	}
+	tree, _, err := solver.ProofTree(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyProof(p, p.ToMove(), tree); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+}
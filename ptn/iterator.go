@@ -1,10 +1,17 @@
 package ptn
 
-import "github.com/nelhage/taktician/tak"
+import (
+	"errors"
+
+	"github.com/nelhage/taktician/tak"
+)
 
 type Iterator struct {
-	ptn *PTN
-	i   int
+	// ops is the line we are walking, and path selects the
+	// side-lines we have yet to branch into.
+	ops  []Op
+	i    int
+	path []int
 
 	err  error
 	over bool
@@ -15,16 +22,54 @@ type Iterator struct {
 	lastMove, move tak.Move
 }
 
+// Iterator returns an Iterator over the main line of the game.
 func (p *PTN) Iterator() *Iterator {
+	return p.VariationIterator(nil)
+}
+
+// VariationIterator returns an Iterator over a side-line. path[0]
+// is the 0-based index of a Variation among those in the main line;
+// the iterator follows the main line up to the move it replaces, and
+// continues into it. path[1] then selects a Variation within that
+// one, and so on. Once the path is exhausted, the iterator follows
+// the chosen line to its end.
+func (p *PTN) VariationIterator(path []int) *Iterator {
 	pos, err := p.InitialPosition()
 	return &Iterator{
-		ptn:      p,
+		ops:      p.Ops,
+		path:     path,
 		position: pos,
 		err:      err,
 		initial:  true,
 	}
 }
 
+// branch returns the Variation selected by i.path[0], if it is an
+// alternative to the move we have just read.
+func (i *Iterator) branch() *Variation {
+	if len(i.path) == 0 {
+		return nil
+	}
+	for j := i.i; j < len(i.ops); j++ {
+		switch o := i.ops[j].(type) {
+		case *Comment:
+		case *Variation:
+			n := 0
+			for _, op := range i.ops[:j] {
+				if _, ok := op.(*Variation); ok {
+					n++
+				}
+			}
+			if n == i.path[0] {
+				return o
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
 func (i *Iterator) Err() error {
 	return i.err
 }
@@ -56,17 +101,25 @@ func (i *Iterator) Next() bool {
 		}
 	}
 
-	for i.i < len(i.ptn.Ops) {
-		op := i.ptn.Ops[i.i]
+	for i.i < len(i.ops) {
+		op := i.ops[i.i]
 		i.i++
 		switch o := op.(type) {
 		case *MoveNumber:
 			i.ptnMove = o.Number
 		case *Move:
+			if v := i.branch(); v != nil {
+				i.ops, i.i, i.path = v.Ops, 0, i.path[1:]
+				continue
+			}
 			i.move = o.Move
 			return true
 		}
 	}
+	if len(i.path) != 0 {
+		i.err = errors.New("variation not found")
+		return false
+	}
 	i.over = true
 	if i.move.Type != 0 {
 		return i.apply()
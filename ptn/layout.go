@@ -0,0 +1,118 @@
+package ptn
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// layout records the formatting of a parsed PTN file: the source
+// text of each tag, and the whitespace around each op.
+type layout struct {
+	bom  bool
+	tags map[Tag]string
+	// pre holds the whitespace preceding each op, and close the
+	// whitespace preceding each variation's closing parenthesis.
+	pre     map[Op]string
+	close   map[*Variation]string
+	trailer string
+
+	// pos and tagPos record where each op and tag began; `at` is
+	// the position of the parser.
+	pos    map[Op]position
+	tagPos []position
+	at     position
+}
+
+// position is a 1-based line and column in a PTN file.
+type position struct {
+	line, col int
+}
+
+// advance moves l.at past `text`.
+func (l *layout) advance(text string) {
+	for _, r := range text {
+		if r == '\n' {
+			l.at.line++
+			l.at.col = 1
+		} else {
+			l.at.col++
+		}
+	}
+}
+
+func newLayout() *layout {
+	return &layout{
+		tags:  make(map[Tag]string),
+		pre:   make(map[Op]string),
+		close: make(map[*Variation]string),
+		pos:   make(map[Op]position),
+		at:    position{1, 1},
+	}
+}
+
+func (l *layout) render(p *PTN) string {
+	var out bytes.Buffer
+	if l.bom {
+		out.WriteRune(0xFEFF)
+	}
+	for _, tag := range p.Tags {
+		if src, ok := l.tags[tag]; ok {
+			out.WriteString(src)
+		} else {
+			if out.Len() > 0 {
+				out.WriteString("\n")
+			}
+			out.WriteString(formatTag(tag))
+		}
+	}
+	l.renderOps(&out, p.Ops, false)
+	out.WriteString(l.trailer)
+	return out.String()
+}
+
+func (l *layout) renderOps(out *bytes.Buffer, ops []Op, nested bool) {
+	for _, op := range ops {
+		pre, ok := l.pre[op]
+		if !ok {
+			renderOp(out, op, nested)
+			continue
+		}
+		out.WriteString(pre)
+		if v, ok := op.(*Variation); ok {
+			out.WriteString("(")
+			l.renderOps(out, v.Ops, true)
+			out.WriteString(l.close[v])
+			out.WriteString(")")
+			continue
+		}
+		out.WriteString(token(op))
+	}
+}
+
+// token returns the text of `op`: its source, if that still
+// describes it, or else a fresh rendering.
+func token(op Op) string {
+	src := op.Source()
+	switch o := op.(type) {
+	case *MoveNumber:
+		if n, e := strconv.Atoi(strings.TrimRight(src, ".")); e != nil || n != o.Number {
+			return strconv.Itoa(o.Number) + "."
+		}
+	case *Move:
+		trimmed := strings.TrimRight(src, "?!'")
+		m, e := ParseMove(trimmed)
+		if e != nil || !m.Equal(o.Move) || src[len(trimmed):] != o.Modifiers {
+			return FormatMove(o.Move) + o.Modifiers
+		}
+	case *Comment:
+		if src != "{"+o.Comment+"}" {
+			return "{" + o.Comment + "}"
+		}
+	case *Result:
+		if src != o.Result {
+			return o.Result
+		}
+	}
+	return src
+}
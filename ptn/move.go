@@ -4,14 +4,32 @@ import (
 	"errors"
 	"github.com/nelhage/taktician/tak"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
 var moveRE = regexp.MustCompile(
 	// [place] [carry] position [direction] [drops] [top]
-	`\A([CFS]?)([1-8]?)([a-h][1-9])([<>+-]?)([1-8]*)([CFS]?)\z`,
+	`\A([CFS]?)([1-9]|10)?([a-j](?:10|[1-9]))([<>+-]?)((?:10|[1-9])*)([CFS]?)\z`,
 )
 
+// parseCount parses a one- or two-digit count in the range
+// [1,tak.MaxSize] from the start of `s`, returning the count and the
+// number of bytes consumed, or 0 if `s` does not start with a count.
+func parseCount(s string) (int, int) {
+	if len(s) == 0 || s[0] < '1' || s[0] > '9' {
+		return 0, 0
+	}
+	n := int(s[0] - '0')
+	if len(s) > 1 && s[1] >= '0' && s[1] <= '9' {
+		if n*10+int(s[1]-'0') > tak.MaxSize {
+			return n, 1
+		}
+		return n*10 + int(s[1]-'0'), 2
+	}
+	return n, 1
+}
+
 func ParseMove(move string) (tak.Move, error) {
 	if len(move) < 2 {
 		return tak.Move{}, errors.New("move too short")
@@ -31,9 +49,9 @@ func ParseMove(move string) (tak.Move, error) {
 		m.Type = tak.PlaceCapstone
 		i++
 	default:
-		if move[i] >= '1' && move[i] <= '8' {
-			stack = int(move[i] - '0')
-			i++
+		if n, l := parseCount(move); l > 0 {
+			stack = n
+			i += l
 		} else {
 			// provisional, may be updated if we see a
 			// slide
@@ -44,15 +62,15 @@ func ParseMove(move string) (tak.Move, error) {
 		return tak.Move{}, errors.New("move too short")
 	}
 
-	if move[i] >= 'a' && move[i] <= 'h' {
+	if move[i] >= 'a' && move[i] < 'a'+tak.MaxSize {
 		m.X = int8(move[i] - 'a')
 		i++
 	} else {
 		return tak.Move{}, errors.New("illegal move")
 	}
-	if move[i] >= '1' && move[i] <= '8' {
-		m.Y = int8(move[i] - '1')
-		i++
+	if y, l := parseCount(move[i:]); l > 0 {
+		m.Y = int8(y - 1)
+		i += l
 	} else {
 		return tak.Move{}, errors.New("illegal move")
 	}
@@ -78,14 +96,13 @@ func ParseMove(move string) (tak.Move, error) {
 		stack = 1
 	}
 	i++
-	j := 0
 	var slides []int
-	for ; i != len(move); i++ {
+	for i != len(move) {
 		d := move[i]
-		if d >= '1' && d <= '8' {
-			slides = append(slides, int(d-'0'))
-			j++
-			stack -= int(d - '0')
+		if n, l := parseCount(move[i:]); l > 0 {
+			slides = append(slides, n)
+			stack -= n
+			i += l
 		} else if strings.ContainsRune("!?*'", rune(d)) {
 			break
 		} else {
@@ -118,7 +135,7 @@ func formatMove(m tak.Move, long bool) string {
 			stack += it.Elem()
 		}
 		if long || stack != 1 {
-			out = append(out, byte('0'+stack))
+			out = strconv.AppendInt(out, int64(stack), 10)
 		}
 	}
 	switch m.Type {
@@ -132,7 +149,7 @@ func formatMove(m tak.Move, long bool) string {
 		out = append(out, 'S')
 	}
 	out = append(out, byte('a'+m.X))
-	out = append(out, byte('1'+m.Y))
+	out = strconv.AppendInt(out, int64(m.Y)+1, 10)
 	switch m.Type {
 	case tak.SlideLeft:
 		out = append(out, '<')
@@ -145,7 +162,7 @@ func formatMove(m tak.Move, long bool) string {
 	}
 	if !m.Slides.Empty() && (long || m.Slides.Len() != 1) {
 		for it := m.Slides.Iterator(); it.Ok(); it = it.Next() {
-			out = append(out, byte('0'+it.Elem()))
+			out = strconv.AppendInt(out, int64(it.Elem()), 10)
 		}
 	}
 	return string(out)
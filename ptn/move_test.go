@@ -92,6 +92,30 @@ func TestParseMove(t *testing.T) {
 			"3a1+111",
 			"3a1+111",
 		},
+		{
+			"j10",
+			tak.Move{X: 9, Y: 9, Type: tak.PlaceFlat},
+			"j10",
+			"Fj10",
+		},
+		{
+			"10a10>",
+			tak.Move{X: 0, Y: 9, Type: tak.SlideRight, Slides: tak.MkSlides(10)},
+			"10a10>",
+			"10a10>10",
+		},
+		{
+			"10a9>118",
+			tak.Move{X: 0, Y: 8, Type: tak.SlideRight, Slides: tak.MkSlides(1, 1, 8)},
+			"10a9>118",
+			"10a9>118",
+		},
+		{
+			"10a1+1111111111",
+			tak.Move{X: 0, Y: 0, Type: tak.SlideUp, Slides: tak.MkSlides(1, 1, 1, 1, 1, 1, 1, 1, 1, 1)},
+			"10a1+1111111111",
+			"10a1+1111111111",
+		},
 	}
 	for _, tc := range cases {
 		get, err := ParseMove(tc.in)
@@ -118,7 +142,9 @@ func TestParseMoveErrors(t *testing.T) {
 		"",
 		"a11",
 		"z3",
+		"k1",
 		"14c4>",
+		"11c4>",
 		"6a1",
 		"6a1>2222",
 		"a",
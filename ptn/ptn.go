@@ -63,6 +63,21 @@ type Result struct {
 	Result string
 }
 
+// Variation is a side-line, written in parentheses after the move it
+// is an alternative to. Its Ops replace that move and the rest of
+// the line it appears in, and may contain further Variations.
+type Variation struct {
+	opCommon
+	Ops []Op
+}
+
+func (v *Variation) clearSrc() {
+	v.src = ""
+	for _, o := range v.Ops {
+		o.clearSrc()
+	}
+}
+
 func (r *Result) Winner() tak.Color {
 	switch r.Result {
 	case "R-0", "F-0", "1-0":
@@ -101,6 +116,10 @@ func ResultFromGame(p *tak.Position) Result {
 type PTN struct {
 	Tags []Tag
 	Ops  []Op
+
+	// layout records the formatting of a parsed game, so that
+	// Render can reproduce it.
+	layout *layout
 }
 
 func ParsePTN(r io.Reader) (*PTN, error) {
@@ -109,14 +128,22 @@ func ParsePTN(r io.Reader) (*PTN, error) {
 	if err != nil {
 		return nil, err
 	}
+	l := newLayout()
 	if ch != 0xFEFF {
 		buf.UnreadRune()
+	} else {
+		l.bom = true
+	}
+	ptn := PTN{layout: l}
+	ws, err := readEvents(buf, &ptn)
+	if err == io.EOF {
+		l.trailer = ws
+		return &ptn, nil
 	}
-	var ptn PTN
-	if err := readEvents(buf, &ptn); err != nil && err != io.EOF {
+	if err != nil {
 		return nil, err
 	}
-	if err := readMoves(buf, &ptn); err != nil && err != io.EOF {
+	if err := readMoves(io.MultiReader(strings.NewReader(ws), buf), &ptn); err != nil && err != io.EOF {
 		return nil, err
 	}
 	return &ptn, nil
@@ -146,12 +173,29 @@ func (p *PTN) InitialPosition() (*tak.Position, error) {
 	if e != nil {
 		return nil, fmt.Errorf("bad size: %s", sizeTag)
 	}
+	cfg := tak.Config{Size: size}
+	if komi := p.FindTag("Komi"); komi != "" {
+		cfg.Komi, e = ParseKomi(komi)
+		if e != nil {
+			return nil, fmt.Errorf("bad komi: %s", komi)
+		}
+	}
+	if flats := p.FindTag("Flats"); flats != "" {
+		if cfg.Pieces, e = ParseFlats(flats); e != nil {
+			return nil, e
+		}
+	}
+	if caps := p.FindTag("Caps"); caps != "" {
+		if cfg.Capstones, e = ParseCaps(caps); e != nil {
+			return nil, e
+		}
+	}
 	tps := p.FindTag("TPS")
 	var out *tak.Position
 	if tps == "" {
-		out = tak.New(tak.Config{Size: size})
+		out = tak.New(cfg)
 	} else {
-		out, e = ParseTPS(tps)
+		out, e = ParseTPSWithConfig(tps, cfg)
 		if e != nil {
 			return nil, fmt.Errorf("bad TPS: %v", e)
 		}
@@ -163,16 +207,45 @@ func (p *PTN) InitialPosition() (*tak.Position, error) {
 	return out, nil
 }
 
+// ParseKomi parses the value of a PTN `Komi` tag, which is expressed
+// in flats (e.g. "2.5"), into the half-flats used by tak.Config.
+func ParseKomi(komi string) (int, error) {
+	f, e := strconv.ParseFloat(komi, 64)
+	if e != nil {
+		return 0, e
+	}
+	half := int(f * 2)
+	if float64(half) != f*2 {
+		return 0, fmt.Errorf("komi is not a multiple of 0.5: %s", komi)
+	}
+	return half, nil
+}
+
+// FormatKomi renders a komi in half-flats in the form used by the
+// PTN `Komi` tag.
+func FormatKomi(komi int) string {
+	if komi%2 == 0 {
+		return strconv.Itoa(komi / 2)
+	}
+	return strconv.FormatFloat(float64(komi)/2, 'f', 1, 64)
+}
+
 // PositionAtMove returns the position of the game after PTN move
 // marker `move`, with `color` to play.
 //
 // `move=0` will cause the code to return the final position of the
 // game.
 func (p *PTN) PositionAtMove(move int, color tak.Color) (*tak.Position, error) {
+	return p.VariationPositionAtMove(nil, move, color)
+}
+
+// VariationPositionAtMove is like PositionAtMove, but follows the
+// side-line selected by `path`, as for VariationIterator.
+func (p *PTN) VariationPositionAtMove(path []int, move int, color tak.Color) (*tak.Position, error) {
 	if color == tak.NoColor && move != 0 {
 		return nil, fmt.Errorf("can't specify NoColor and move!=0")
 	}
-	it := p.Iterator()
+	it := p.VariationIterator(path)
 	for it.Next() {
 		if move > 0 && move == it.PTNMove() && it.Position().ToMove() == color {
 			return it.Position(), nil
@@ -188,131 +261,250 @@ func (p *PTN) PositionAtMove(move int, color tak.Color) (*tak.Position, error) {
 	return it.Position(), nil
 }
 
-func readEvents(r *bufio.Reader, ptn *PTN) error {
+// readEvents reads the tags at the start of a game. It returns the
+// whitespace following them, which belongs to the moves.
+func readEvents(r *bufio.Reader, ptn *PTN) (string, error) {
 	for {
-		if e := skipWS(r); e != nil {
-			return e
+		ws, e := readWS(r)
+		if e != nil {
+			return ws, e
 		}
 		c, e := r.ReadByte()
 		if e != nil {
-			return e
+			return ws, e
 		}
 		if c != '[' {
-			return r.UnreadByte()
+			return ws, r.UnreadByte()
 		}
 		line, e := r.ReadString(']')
 		if e != nil {
-			return e
+			return "", e
 		}
+		src := ws + "[" + line
+		ptn.layout.advance(ws)
+		ptn.layout.tagPos = append(ptn.layout.tagPos, ptn.layout.at)
+		ptn.layout.advance("[" + line)
 		line = line[:len(line)-1]
 		bits := strings.SplitN(line, " ", 2)
 		if len(bits) != 2 {
-			return errors.New("bad tag")
+			return "", errors.New("bad tag")
 		}
 		tag := Tag{
 			Name:  bits[0],
 			Value: strings.Trim(bits[1], "\""),
 		}
 		ptn.Tags = append(ptn.Tags, tag)
+		if _, ok := ptn.layout.tags[tag]; !ok {
+			ptn.layout.tags[tag] = src
+		}
 	}
 }
 
 var resultRE = regexp.MustCompile(`^(F|R|1/2|1|0)-(F|R|1/2|1|0)$`)
 
-func readMoves(r *bufio.Reader, ptn *PTN) error {
+func readMoves(r io.Reader, ptn *PTN) error {
+	l := ptn.layout
 	s := bufio.NewScanner(r)
 	s.Split(splitMoves)
+	// ops is the list we are currently appending to, and stack
+	// holds the lists enclosing each open variation, and vars the
+	// variations themselves.
+	ops := &ptn.Ops
+	var stack []*[]Op
+	var vars []*Variation
 	for s.Scan() {
 		tok := s.Text()
+		pre := tok[:len(tok)-len(strings.TrimLeftFunc(tok, unicode.IsSpace))]
+		tok = tok[len(pre):]
+		l.advance(pre)
+		at := l.at
+		l.advance(tok)
+		if tok == "" {
+			l.trailer = pre
+			continue
+		}
+		if tok == ")" {
+			if len(stack) == 0 {
+				return errors.New("unbalanced ')'")
+			}
+			l.close[vars[len(vars)-1]] = pre
+			ops = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			vars = vars[:len(vars)-1]
+			continue
+		}
+		n := len(*ops)
 		common := opCommon{tok}
 		switch {
 		case tok[0] == '{':
-			ptn.Ops = append(ptn.Ops, &Comment{common, tok[1 : len(tok)-1]})
+			*ops = append(*ops, &Comment{common, tok[1 : len(tok)-1]})
+		case tok == "(":
+			v := &Variation{opCommon: common}
+			*ops = append(*ops, v)
+			stack = append(stack, ops)
+			vars = append(vars, v)
+			ops = &v.Ops
 		case tok[len(tok)-1] == '.':
-			n, e := strconv.Atoi(tok[:len(tok)-1])
+			// Black's moves in a side-line are numbered
+			// like "3...".
+			n, e := strconv.Atoi(strings.TrimRight(tok, "."))
 			if e != nil {
 				return e
 			}
-			ptn.Ops = append(ptn.Ops, &MoveNumber{common, n})
+			*ops = append(*ops, &MoveNumber{common, n})
 		case resultRE.MatchString(tok):
-			ptn.Ops = append(ptn.Ops, &Result{common, tok})
+			*ops = append(*ops, &Result{common, tok})
 		default:
 			trimmed := strings.TrimRight(tok, "?!'")
 			move, e := ParseMove(trimmed)
 			if e != nil {
 				return fmt.Errorf("bad move: %s", trimmed)
 			}
-			ptn.Ops = append(ptn.Ops, &Move{common, move, tok[len(trimmed):]})
+			*ops = append(*ops, &Move{common, move, tok[len(trimmed):]})
+		}
+		list := ops
+		if tok == "(" {
+			list = stack[len(stack)-1]
+		}
+		op := (*list)[n]
+		l.pre[op] = pre
+		l.pos[op] = at
+	}
+	if e := s.Err(); e != nil {
+		return e
+	}
+	if len(stack) != 0 {
+		return errors.New("unterminated variation")
+	}
+	return nil
+}
+
+// ParseVariationPath parses a path to a side-line, written as
+// 1-based indexes separated by dots: "2" is the second variation in
+// the main line, and "2.1" the first variation inside that one. It
+// returns the 0-based path used by VariationIterator.
+func ParseVariationPath(s string) ([]int, error) {
+	var path []int
+	for _, bit := range strings.Split(s, ".") {
+		n, e := strconv.Atoi(bit)
+		if e != nil || n < 1 {
+			return nil, fmt.Errorf("bad variation path: %s", s)
 		}
+		path = append(path, n-1)
 	}
-	return s.Err()
+	return path, nil
 }
 
+// splitMoves splits the move section of a PTN file into tokens. Each
+// token includes the whitespace preceding it, and any whitespace at
+// the end of the input is returned as a final token.
 func splitMoves(buf []byte, atEOF bool) (int, []byte, error) {
 	start := 0
 	for start < len(buf) && unicode.IsSpace(rune(buf[start])) {
 		start++
 	}
 	if start == len(buf) {
-		return start, nil, nil
+		if atEOF && start > 0 {
+			return start, buf, nil
+		}
+		return 0, nil, nil
 	}
-	if buf[start] == '{' {
+	switch buf[start] {
+	case '{':
 		for i := start; i < len(buf); i++ {
 			if buf[i] == '}' {
-				return i + 1, buf[start : i+1], nil
+				return i + 1, buf[:i+1], nil
 			}
 		}
-	} else {
+	case '(', ')':
+		return start + 1, buf[:start+1], nil
+	default:
 		for i := start; i < len(buf); i++ {
-			if unicode.IsSpace(rune(buf[i])) {
-				return i + 1, buf[start:i], nil
+			if unicode.IsSpace(rune(buf[i])) || buf[i] == '(' || buf[i] == ')' {
+				return i, buf[:i], nil
 			}
 		}
 	}
 	if atEOF {
-		return len(buf), buf[start:], nil
+		return len(buf), buf, nil
 	}
-	return start, nil, nil
+	return 0, nil, nil
 }
 
-func skipWS(r *bufio.Reader) error {
+// readWS reads and returns whitespace from `r`.
+func readWS(r *bufio.Reader) (string, error) {
+	var ws strings.Builder
 	for {
 		c, e := r.ReadByte()
 		if e != nil {
-			return e
+			return ws.String(), e
 		}
 		if !unicode.IsSpace(rune(c)) {
-			return r.UnreadByte()
+			return ws.String(), r.UnreadByte()
 		}
+		ws.WriteByte(c)
 	}
 }
 
+// Render formats the game as PTN. A game read by ParsePTN is
+// rendered exactly as it was read, except for tags and ops which
+// have since been added or changed.
 func (p *PTN) Render() string {
+	if p.layout != nil {
+		return p.layout.render(p)
+	}
 	var out bytes.Buffer
 	for _, tag := range p.Tags {
-		fmt.Fprintf(&out, "[%s \"%s\"]\n",
-			tag.Name, strings.Replace(tag.Value, "\"", "", -1),
-		)
+		out.WriteString(formatTag(tag))
+		out.WriteString("\n")
 	}
 	out.WriteString("\n")
 
-	for _, op := range p.Ops {
-		switch o := op.(type) {
-		case *MoveNumber:
-			fmt.Fprintf(&out, "\n%d.", o.Number)
-		case *Move:
-			fmt.Fprintf(&out, " %s%s", FormatMove(o.Move), o.Modifiers)
-		case *Comment:
-			fmt.Fprintf(&out, " {%s}", o.Comment)
-		case *Result:
-			fmt.Fprintf(&out, "\n%s\n", o.Result)
-		default:
-		}
-	}
+	renderOps(&out, p.Ops, false)
 	out.WriteString("\n")
 	return out.String()
 }
 
+func formatTag(tag Tag) string {
+	return fmt.Sprintf("[%s \"%s\"]",
+		tag.Name, strings.Replace(tag.Value, "\"", "", -1),
+	)
+}
+
+// renderOps renders `ops`. Inside a variation (`nested`), everything
+// is kept on one line.
+func renderOps(out *bytes.Buffer, ops []Op, nested bool) {
+	for _, op := range ops {
+		renderOp(out, op, nested)
+	}
+}
+
+func renderOp(out *bytes.Buffer, op Op, nested bool) {
+	switch o := op.(type) {
+	case *MoveNumber:
+		if nested {
+			fmt.Fprintf(out, " %d.", o.Number)
+		} else {
+			fmt.Fprintf(out, "\n%d.", o.Number)
+		}
+	case *Move:
+		fmt.Fprintf(out, " %s%s", FormatMove(o.Move), o.Modifiers)
+	case *Comment:
+		fmt.Fprintf(out, " {%s}", o.Comment)
+	case *Result:
+		if nested {
+			fmt.Fprintf(out, " %s", o.Result)
+		} else {
+			fmt.Fprintf(out, "\n%s\n", o.Result)
+		}
+	case *Variation:
+		var inner bytes.Buffer
+		renderOps(&inner, o.Ops, true)
+		fmt.Fprintf(out, " (%s)", strings.TrimLeft(inner.String(), " "))
+	default:
+	}
+}
+
 func (p *PTN) AddMoves(moves []tak.Move) {
 	for i, m := range moves {
 		if i%2 == 0 {
@@ -214,3 +214,231 @@ func TestPositionAtMove(t *testing.T) {
 	}
 
 }
+
+func TestKomi(t *testing.T) {
+	cases := []struct {
+		in   string
+		half int
+		out  string
+		err  bool
+	}{
+		{"0", 0, "0", false},
+		{"2", 4, "2", false},
+		{"2.5", 5, "2.5", false},
+		{"-0.5", -1, "-0.5", false},
+		{"1.25", 0, "", true},
+		{"lots", 0, "", true},
+	}
+	for _, tc := range cases {
+		half, e := ParseKomi(tc.in)
+		if tc.err {
+			if e == nil {
+				t.Errorf("ParseKomi(%q): expected error", tc.in)
+			}
+			continue
+		}
+		if e != nil || half != tc.half {
+			t.Errorf("ParseKomi(%q) = (%d, %v) != %d", tc.in, half, e, tc.half)
+			continue
+		}
+		if out := FormatKomi(half); out != tc.out {
+			t.Errorf("FormatKomi(%d) = %q != %q", half, out, tc.out)
+		}
+	}
+
+	g, e := ParsePTN(bytes.NewBufferString(`[Size "4"]
+[Komi "1.5"]
+[TPS "1,2,1,2/2,1,2,1/1,2,1,2/2,1,2,1 1 9"]
+`))
+	if e != nil {
+		t.Fatal("parse:", e)
+	}
+	p, e := g.InitialPosition()
+	if e != nil {
+		t.Fatal("initial:", e)
+	}
+	if p.Config().Komi != 3 {
+		t.Fatalf("komi=%d != 3", p.Config().Komi)
+	}
+	if r := ResultFromGame(p); r.Result != "0-F" {
+		t.Fatalf("result=%s != 0-F", r.Result)
+	}
+}
+
+const variationGame = `
+[Size "5"]
+
+1. a1 e5
+2. e1 (2. e4 a2 (2... e3) 3. a3) a2
+3. c3 (3. c2 {quiet} b2) b3
+`
+
+func TestParseVariations(t *testing.T) {
+	g, err := ParsePTN(bytes.NewBufferString(variationGame))
+	if err != nil {
+		t.Fatal("parse:", err)
+	}
+	var main []string
+	var vars []*Variation
+	for _, o := range g.Ops {
+		switch o := o.(type) {
+		case *Move:
+			main = append(main, FormatMove(o.Move))
+		case *Variation:
+			vars = append(vars, o)
+		}
+	}
+	if got := strings.Join(main, " "); got != "a1 e5 e1 a2 c3 b3" {
+		t.Errorf("main line: %s", got)
+	}
+	if len(vars) != 2 {
+		t.Fatalf("found %d variations", len(vars))
+	}
+	var nested *Variation
+	for _, o := range vars[0].Ops {
+		if v, ok := o.(*Variation); ok {
+			nested = v
+		}
+	}
+	if nested == nil || len(nested.Ops) != 2 {
+		t.Fatalf("nested variation: %#v", nested)
+	}
+	if n := nested.Ops[0].(*MoveNumber); n.Number != 2 {
+		t.Errorf("nested move number: %d", n.Number)
+	}
+
+	back, err := ParsePTN(bytes.NewBufferString(g.Render()))
+	if err != nil {
+		t.Fatal("parse round-tripped:", err)
+	}
+	for _, o := range g.Ops {
+		o.clearSrc()
+	}
+	for _, o := range back.Ops {
+		o.clearSrc()
+	}
+	if !reflect.DeepEqual(g.Ops, back.Ops) {
+		t.Fatalf("different ops!\n%s", g.Render())
+	}
+
+	for _, bad := range []string{
+		"1. a1 (e5",
+		"1. a1 e5)",
+	} {
+		if _, err := ParsePTN(bytes.NewBufferString("[Size \"5\"]\n\n" + bad)); err == nil {
+			t.Errorf("parse(%q): expected error", bad)
+		}
+	}
+}
+
+func TestVariationPositionAtMove(t *testing.T) {
+	g, err := ParsePTN(bytes.NewBufferString(variationGame))
+	if err != nil {
+		t.Fatal("parse:", err)
+	}
+	cases := []struct {
+		path  string
+		move  int
+		color tak.Color
+		tps   string
+	}{
+		{"1", 0, tak.NoColor, "x4,1/x4,1/1,x4/2,x4/2,x4 2 3"},
+		{"1", 2, tak.Black, "x4,1/x4,1/x5/x5/2,x4 2 2"},
+		{"1.1", 0, tak.NoColor, "x4,1/x4,1/x4,2/x5/2,x4 1 3"},
+		{"2", 0, tak.NoColor, "x4,1/x5/x5/2,2,1,x2/2,x3,1 1 4"},
+		{"3", 0, tak.NoColor, ""},
+		{"1.2", 0, tak.NoColor, ""},
+	}
+	for _, tc := range cases {
+		path, err := ParseVariationPath(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pos, err := g.VariationPositionAtMove(path, tc.move, tc.color)
+		if tc.tps == "" {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.path, err)
+			continue
+		}
+		if tps := FormatTPS(pos); tps != tc.tps {
+			t.Errorf("%s: %s != %s", tc.path, tps, tc.tps)
+		}
+	}
+
+	for _, bad := range []string{"", "0", "1.x", "a1"} {
+		if _, err := ParseVariationPath(bad); err == nil {
+			t.Errorf("ParseVariationPath(%q): expected error", bad)
+		}
+	}
+}
+
+func TestRenderByteStable(t *testing.T) {
+	cases := []string{
+		testGame,
+		variationGame,
+		emptyPTN,
+		"\uFEFF[Size \"5\"]\n\n1. a1 e5\n",
+		"[Size \"5\"]\r\n[Komi \"2\"]\r\n\r\n1. a1 e5 {x} 2. Ca3!? ( 2. c3'  (2... e4) {y} ) a4\r\n\r\n1-0",
+		"[Size 5]\n[Player1 \"Some \"quoted\" name\"]\n\n  1. a1 e5\n\t2. e4\n",
+		"[Size \"5\"]\n1. a1 e5 2. 1e5- 0-R\n\n",
+	}
+	for _, tc := range cases {
+		g, err := ParsePTN(strings.NewReader(tc))
+		if err != nil {
+			t.Errorf("parse(%q): %v", tc, err)
+			continue
+		}
+		if out := g.Render(); out != tc {
+			t.Errorf("render:\n%q\n!=\n%q", out, tc)
+		}
+	}
+}
+
+func TestRenderEdited(t *testing.T) {
+	src := "[Size \"5\"]\n[Player1 \"me\"]\n\n1. a1   e5 {first}\n2. e4? a2\n"
+	g, err := ParsePTN(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Tags[1].Value = "you"
+	g.Tags = append(g.Tags, Tag{"Result", "R-0"})
+	for _, o := range g.Ops {
+		if m, ok := o.(*Move); ok && m.Modifiers == "?" {
+			m.Modifiers = "!"
+		}
+	}
+	g.Ops = append(g.Ops, &Comment{Comment: "new"})
+	want := "[Size \"5\"]\n[Player1 \"you\"]\n[Result \"R-0\"]\n\n1. a1   e5 {first}\n2. e4! a2 {new}\n"
+	if out := g.Render(); out != want {
+		t.Errorf("render:\n%q\n!=\n%q", out, want)
+	}
+}
+
+func TestReserveTags(t *testing.T) {
+	g, e := ParsePTN(bytes.NewBufferString(`[Size "5"]
+[Flats "3"]
+[Caps "0"]
+[TPS "x5/x5/x5/x5/x5 1 2"]
+`))
+	if e != nil {
+		t.Fatal("parse:", e)
+	}
+	p, e := g.InitialPosition()
+	if e != nil {
+		t.Fatal("initial:", e)
+	}
+	if p.WhiteStones() != 3 {
+		t.Errorf("stones=%d", p.WhiteStones())
+	}
+	if _, e := p.Move(tak.Move{X: 2, Y: 2, Type: tak.PlaceCapstone}); e == nil {
+		t.Error("placed a capstone with none in reserve")
+	}
+	if tps := FormatExtendedTPS(p); tps != "x5/x5/x5/x5/x5 1 2 flats=3 caps=0" {
+		t.Errorf("tps=%s", tps)
+	}
+}
@@ -0,0 +1,179 @@
+package ptn
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ParseError is returned by Reader.Next for a game that could not be
+// parsed. Line is the line of the stream on which the game started.
+type ParseError struct {
+	Game int
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("game %d (line %d): %v", e.Game, e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads a stream of concatenated PTN games, such as a bulk
+// export.
+//
+// A new game starts at a tag line that follows the moves of the
+// previous game. Blank lines among a game's tags do not end it.
+type Reader struct {
+	r     *bufio.Reader
+	line  int
+	game  int
+	start int
+
+	// pending is a line we have read that begins the next game.
+	pending    string
+	hasPending bool
+}
+
+func NewReader(r io.Reader) *Reader {
+	br := bufio.NewReader(r)
+	if ch, _, err := br.ReadRune(); err == nil && ch != 0xFEFF {
+		br.UnreadRune()
+	}
+	return &Reader{r: br}
+}
+
+func (r *Reader) readLine() (string, error) {
+	if r.hasPending {
+		r.hasPending = false
+		return r.pending, nil
+	}
+	line, err := r.r.ReadString('\n')
+	if line == "" && err != nil {
+		return "", err
+	}
+	r.line++
+	return line, nil
+}
+
+// Next returns the next game in the stream, or io.EOF once there are
+// no more. If a game fails to parse, Next returns a *ParseError, and
+// the following call continues with the next game.
+func (r *Reader) Next() (*PTN, error) {
+	var buf bytes.Buffer
+	var start int
+	var inMoves bool
+	depth := 0
+	for {
+		line, err := r.readLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			// Blank lines never end a game.
+		} else if trimmed[0] == '[' && depth == 0 {
+			if inMoves {
+				r.pending, r.hasPending = line, true
+				break
+			}
+		} else {
+			inMoves = true
+			depth += strings.Count(line, "{") - strings.Count(line, "}")
+		}
+		if start == 0 && trimmed != "" {
+			start = r.line
+		}
+		if start != 0 {
+			buf.WriteString(line)
+		}
+	}
+	if start == 0 {
+		return nil, io.EOF
+	}
+	r.game++
+	r.start = start
+	g, err := ParsePTN(&buf)
+	if err != nil {
+		return nil, &ParseError{Game: r.game, Line: start, Err: err}
+	}
+	return g, nil
+}
+
+// Line returns the line of the stream on which the game last
+// returned by Next started. Add Line()-1 to the line of a Diagnostic
+// to locate it in the stream.
+func (r *Reader) Line() int {
+	return r.start
+}
+
+// ReadAll reads every game in the stream. Games which fail to parse
+// are skipped; their errors are returned alongside the games that
+// succeeded.
+func ReadAll(in io.Reader) ([]*PTN, []error) {
+	r := NewReader(in)
+	var games []*PTN
+	var errs []error
+	for {
+		g, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if _, ok := err.(*ParseError); ok {
+			errs = append(errs, err)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		games = append(games, g)
+	}
+	return games, errs
+}
+
+// ParseArchive reads every game from the file at `path`, as for
+// ReadAll.
+func ParseArchive(path string) ([]*PTN, []error) {
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, []error{e}
+	}
+	defer f.Close()
+	return ReadAll(f)
+}
+
+// Writer writes a stream of games that can be read back by Reader.
+// Games are separated by a blank line, unless they already end with
+// one.
+type Writer struct {
+	w    io.Writer
+	last string
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Write(p *PTN) error {
+	text := p.Render()
+	switch {
+	case w.last == "" || strings.HasSuffix(w.last, "\n\n"):
+	case strings.HasSuffix(w.last, "\n"):
+		text = "\n" + text
+	default:
+		text = "\n\n" + text
+	}
+	w.last = text
+	_, err := io.WriteString(w.w, text)
+	return err
+}
@@ -0,0 +1,119 @@
+package ptn
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+const archive = `[Size "5"]
+[Player1 "one"]
+
+1. a1 e5
+2. e4 {a comment
+[that looks like a tag]} a2
+[Size "5"]
+[Player1 "two"]
+1. a1 e5
+
+[Size "5"]
+[Player1 "three"]
+
+1. a1 bogus
+
+[Size "6"]
+
+[Player1 "four"]
+
+1. a1 f6
+
+[Size "5"]
+[Player1 "five"]
+
+1. a1 e5 (e4)
+R-0
+`
+
+func TestReader(t *testing.T) {
+	r := NewReader(strings.NewReader("\uFEFF" + archive))
+	var players, sizes []string
+	var errs []error
+	for {
+		g, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		players = append(players, g.FindTag("Player1"))
+		sizes = append(sizes, g.FindTag("Size"))
+	}
+	if got := strings.Join(players, " "); got != "one two four five" {
+		t.Errorf("games: %s", got)
+	}
+	// Blank lines between tags do not start a new game.
+	if got := strings.Join(sizes, " "); got != "5 5 6 5" {
+		t.Errorf("sizes: %s", got)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("errors: %v", errs)
+	}
+	var pe *ParseError
+	if !errors.As(errs[0], &pe) {
+		t.Fatalf("not a ParseError: %v", errs[0])
+	}
+	if pe.Game != 3 || pe.Line != 11 {
+		t.Errorf("error at game %d line %d", pe.Game, pe.Line)
+	}
+}
+
+func TestReaderBlankTags(t *testing.T) {
+	games, errs := ReadAll(strings.NewReader("[Size \"5\"]\n\n[Player1 \"a\"]\n\n1. a1 e5\n"))
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if len(games) != 1 {
+		t.Fatalf("read %d games, want 1", len(games))
+	}
+	if games[0].FindTag("Size") != "5" || games[0].FindTag("Player1") != "a" || len(games[0].Ops) == 0 {
+		t.Errorf("bad game: %q", games[0].Render())
+	}
+}
+
+func writeAll(t *testing.T, games []*PTN) string {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, g := range games {
+		if err := w.Write(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.String()
+}
+
+func TestWriter(t *testing.T) {
+	games, errs := ReadAll(strings.NewReader(archive))
+	if len(games) != 4 || len(errs) != 1 {
+		t.Fatalf("read %d games, errors=%v", len(games), errs)
+	}
+	out := writeAll(t, games)
+	back, errs := ReadAll(strings.NewReader(out))
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if len(back) != len(games) {
+		t.Fatalf("round-tripped %d games != %d", len(back), len(games))
+	}
+	for i := range games {
+		if strings.TrimSpace(back[i].Render()) != strings.TrimSpace(games[i].Render()) {
+			t.Errorf("[%d] %q != %q", i, back[i].Render(), games[i].Render())
+		}
+	}
+	if again := writeAll(t, back); again != out {
+		t.Errorf("archive is not stable:\n%s\n!=\n%s", again, out)
+	}
+}
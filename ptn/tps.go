@@ -10,11 +10,41 @@ import (
 )
 
 func ParseTPS(tpn string) (*tak.Position, error) {
+	return ParseTPSWithConfig(tpn, tak.Config{})
+}
+
+// ParseTPSWithConfig parses a TPS string into a position played
+// under the rules in `cfg`. The board size is always taken from the
+// TPS, and cfg.Size is ignored.
+//
+// We also accept an extended form of TPS, which sets non-standard
+// reserves by appending `flats=N` and/or `caps=N`; these override
+// cfg.Pieces and cfg.Capstones.
+func ParseTPSWithConfig(tpn string, cfg tak.Config) (*tak.Position, error) {
 	var pieces [][]tak.Square
 	words := strings.Split(tpn, " ")
-	if len(words) != 3 {
+	if len(words) < 3 {
 		return nil, errors.New("bad TPS: wrong number of words")
 	}
+	for _, w := range words[3:] {
+		bits := strings.SplitN(w, "=", 2)
+		if len(bits) != 2 {
+			return nil, fmt.Errorf("bad TPS: unexpected word: %s", w)
+		}
+		var err error
+		switch bits[0] {
+		case "flats":
+			cfg.Pieces, err = ParseFlats(bits[1])
+		case "caps":
+			cfg.Capstones, err = ParseCaps(bits[1])
+		default:
+			err = fmt.Errorf("unknown field: %s", bits[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bad TPS: %w", err)
+		}
+	}
+	words = words[:3]
 	turn, err := strconv.Atoi(words[1])
 	if err != nil {
 		return nil, fmt.Errorf("bad turn: %s", words[1])
@@ -36,7 +66,7 @@ func ParseTPS(tpn string) (*tak.Position, error) {
 		}
 		pieces = append([][]tak.Square{row}, pieces...)
 	}
-	if len(pieces) < 3 || len(pieces) > 8 {
+	if len(pieces) < 3 || len(pieces) > tak.MaxSize {
 		return nil, fmt.Errorf("bad size board: %d", len(pieces))
 	}
 	for i, r := range pieces {
@@ -44,7 +74,43 @@ func ParseTPS(tpn string) (*tak.Position, error) {
 			return nil, fmt.Errorf("row %d bad length: %d", i, len(r))
 		}
 	}
-	return tak.FromSquares(tak.Config{Size: len(pieces)}, pieces, move)
+	cfg.Size = len(pieces)
+	p, err := tak.FromSquares(cfg, pieces, move)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkReserves(p.Config(), pieces); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// checkReserves returns an error if `pieces` uses more stones or
+// capstones than either player's reserve holds under `cfg`.
+func checkReserves(cfg tak.Config, pieces [][]tak.Square) error {
+	maxPieces, maxCaps := cfg.Reserves()
+	stones := make(map[tak.Color]int)
+	caps := make(map[tak.Color]int)
+	for _, row := range pieces {
+		for _, sq := range row {
+			for _, piece := range sq {
+				if piece.Kind() == tak.Capstone {
+					caps[piece.Color()]++
+				} else {
+					stones[piece.Color()]++
+				}
+			}
+		}
+	}
+	for _, c := range []tak.Color{tak.White, tak.Black} {
+		if stones[c] > maxPieces {
+			return fmt.Errorf("%s has %d stones, more than the reserve of %d", c, stones[c], maxPieces)
+		}
+		if caps[c] > maxCaps {
+			return fmt.Errorf("%s has %d capstones, more than the reserve of %d", c, caps[c], maxCaps)
+		}
+	}
+	return nil
 }
 
 func FormatTPS(p *tak.Position) string {
@@ -61,6 +127,44 @@ func FormatTPS(p *tak.Position) string {
 	return fmt.Sprintf("%s %s %d", strings.Join(rows, "/"), toMove, p.MoveNumber()/2+1)
 }
 
+// FormatExtendedTPS is like FormatTPS, but records the size of the
+// reserves if they are not the standard ones for the board size.
+func FormatExtendedTPS(p *tak.Position) string {
+	tps := FormatTPS(p)
+	pieces, caps := p.Config().Reserves()
+	defPieces, defCaps := tak.DefaultReserves(p.Size())
+	if pieces != defPieces {
+		tps += fmt.Sprintf(" flats=%d", pieces)
+	}
+	if caps != defCaps {
+		tps += fmt.Sprintf(" caps=%d", caps)
+	}
+	return tps
+}
+
+// ParseFlats parses the number of stones in a reserve, as in the PTN
+// `Flats` tag.
+func ParseFlats(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > tak.MaxPieces {
+		return 0, fmt.Errorf("bad flats: %s", s)
+	}
+	return n, nil
+}
+
+// ParseCaps parses the number of capstones in a reserve, as in the
+// PTN `Caps` tag, into a tak.Config.Capstones.
+func ParseCaps(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > tak.MaxPieces {
+		return 0, fmt.Errorf("bad caps: %s", s)
+	}
+	if n == 0 {
+		return tak.NoCapstones, nil
+	}
+	return n, nil
+}
+
 func tpsRow(p *tak.Position, y int) string {
 	var bits []string
 	for x := 0; x < p.Size(); {
@@ -105,7 +209,11 @@ func parseRow(row string) ([]tak.Square, error) {
 		if bit[0] == 'x' {
 			count := 1
 			if len(bit) > 1 {
-				count = int(bit[1] - '0')
+				var err error
+				count, err = strconv.Atoi(bit[1:])
+				if err != nil || count < 1 || count > tak.MaxSize {
+					return nil, fmt.Errorf("malformed empty run: %s", bit)
+				}
 			}
 			for i := 0; i < count; i++ {
 				out = append(out, nil)
@@ -113,16 +113,86 @@ func TestParseTPS(t *testing.T) {
 }
 
 func TestRenderTPS(t *testing.T) {
-	tps := `x3,12,2S/x,22S,22C,11,21/121,212,12,1121C,1212S/21S,1,21,211S,12S/x,21S,2,x2 1 26`
-	p, e := ParseTPS(tps)
+	cases := []string{
+		`x3,12,2S/x,22S,22C,11,21/121,212,12,1121C,1212S/21S,1,21,211S,12S/x,21S,2,x2 1 26`,
+		`x10/x10/x9,2/x10/x2,1212121212,x7/x10/x4,2C,x5/x10/x,1S,x8/1,x9 2 12`,
+	}
+	for _, tps := range cases {
+		p, e := ParseTPS(tps)
+		if e != nil {
+			t.Fatal("parse error", e)
+		}
+		if p == nil {
+			t.Fatal("parse returned nil?")
+		}
+		out := FormatTPS(p)
+		if out != tps {
+			t.Fatalf("FormatTPS:\n in= `%s`\n out=`%s`", tps, out)
+		}
+	}
+}
+
+func TestParseTPSReserves(t *testing.T) {
+	cases := []struct {
+		tps string
+		ok  bool
+	}{
+		{"1111111111,x2/x3/x3 1 10", true},
+		{"11111111111,x2/x3/x3 1 10", false},
+		{"1C,x3/x4/x4/x4 1 2", false},
+		{"1C,x4/x5/x5/x5/2C,x4 1 2", true},
+		{"1C,1C,x3/x5/x5/x5/x5 1 2", false},
+	}
+	for _, tc := range cases {
+		_, e := ParseTPS(tc.tps)
+		if tc.ok && e != nil {
+			t.Errorf("ParseTPS(%q): %v", tc.tps, e)
+		}
+		if !tc.ok && e == nil {
+			t.Errorf("ParseTPS(%q): expected an error", tc.tps)
+		}
+	}
+}
+
+func TestExtendedTPS(t *testing.T) {
+	cases := []struct {
+		tps string
+		out string
+		err bool
+	}{
+		{"x5/x5/x5/x5/x5 1 1", "x5/x5/x5/x5/x5 1 1", false},
+		{"x5/x5/x5/x5/x5 1 1 flats=30 caps=0", "x5/x5/x5/x5/x5 1 1 flats=30 caps=0", false},
+		{"x5/x5/x5/x5/x5 1 1 caps=1 flats=21", "x5/x5/x5/x5/x5 1 1", false},
+		{"x5/x5/x5/x5/x5 1 1 caps=2", "x5/x5/x5/x5/x5 1 1 caps=2", false},
+		{"1C,x4/x5/x5/x5/x5 2 1 caps=0", "", true},
+		{"x5/x5/x5/x5/x5 1 1 flats=0", "", true},
+		{"x5/x5/x5/x5/x5 1 1 komi=2", "", true},
+	}
+	for _, tc := range cases {
+		p, e := ParseTPS(tc.tps)
+		if tc.err {
+			if e == nil {
+				t.Errorf("ParseTPS(%q): expected an error", tc.tps)
+			}
+			continue
+		}
+		if e != nil {
+			t.Errorf("ParseTPS(%q): %v", tc.tps, e)
+			continue
+		}
+		if out := FormatExtendedTPS(p); out != tc.out {
+			t.Errorf("FormatExtendedTPS(%q) = %q != %q", tc.tps, out, tc.out)
+		}
+	}
+
+	p, e := ParseTPS("x4/x4/x4/x4 1 2 flats=3 caps=1")
 	if e != nil {
-		t.Fatal("parse error", e)
+		t.Fatal(e)
 	}
-	if p == nil {
-		t.Fatal("parse returned nil?")
+	if p.WhiteStones() != 3 || p.BlackStones() != 3 {
+		t.Errorf("stones=%d/%d", p.WhiteStones(), p.BlackStones())
 	}
-	out := FormatTPS(p)
-	if out != tps {
-		t.Fatalf("FormatTPS:\n in= `%s`\n out=`%s`", tps, out)
+	if _, e := p.Move(tak.Move{X: 0, Y: 0, Type: tak.PlaceCapstone}); e != nil {
+		t.Errorf("place capstone: %v", e)
 	}
 }
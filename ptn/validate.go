@@ -0,0 +1,220 @@
+package ptn
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nelhage/taktician/tak"
+)
+
+// A Diagnostic is a problem found by Validate. Line and Col locate
+// it in the parsed file, and are zero for games that were not read
+// by ParsePTN.
+type Diagnostic struct {
+	Line, Col int
+	Message   string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Line, d.Col, d.Message)
+}
+
+type validator struct {
+	p     *PTN
+	diags []Diagnostic
+}
+
+func (v *validator) report(at position, format string, args ...interface{}) {
+	v.diags = append(v.diags, Diagnostic{at.line, at.col, fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) tagPos(i int) position {
+	if v.p.layout == nil || i >= len(v.p.layout.tagPos) {
+		return position{}
+	}
+	return v.p.layout.tagPos[i]
+}
+
+func (v *validator) opPos(op Op) position {
+	if v.p.layout == nil {
+		return position{}
+	}
+	return v.p.layout.pos[op]
+}
+
+// Validate checks a game for problems that ParsePTN tolerates: it
+// checks that the tags are present and well-formed, that the Result
+// tag agrees with how the game ended, that move numbers are in
+// sequence, and that every move, in the main line and in each
+// variation, is legal.
+func Validate(p *PTN) []Diagnostic {
+	v := &validator{p: p}
+	start := v.checkTags()
+	if start != nil {
+		final := v.checkLine(p.Ops, start)
+		v.checkResult(final)
+	}
+	sort.SliceStable(v.diags, func(i, j int) bool {
+		a, b := v.diags[i], v.diags[j]
+		return a.Line < b.Line || (a.Line == b.Line && a.Col < b.Col)
+	})
+	return v.diags
+}
+
+var requiredTags = []string{"Size", "Player1", "Player2"}
+
+// checkTags checks the tags, and returns the initial position, if it
+// is valid.
+func (v *validator) checkTags() *tak.Position {
+	first := position{1, 1}
+	if v.p.layout == nil {
+		first = position{}
+	}
+	seen := make(map[string]bool)
+	badConfig := false
+	for i, t := range v.p.Tags {
+		at := v.tagPos(i)
+		if seen[t.Name] {
+			v.report(at, "duplicate %s tag", t.Name)
+		}
+		seen[t.Name] = true
+		switch t.Name {
+		case "Size":
+			if n, e := strconv.Atoi(t.Value); e != nil || n < 3 || n > tak.MaxSize {
+				v.report(at, "bad Size: %q", t.Value)
+				badConfig = true
+			}
+		case "Komi":
+			if _, e := ParseKomi(t.Value); e != nil {
+				v.report(at, "bad Komi: %q", t.Value)
+				badConfig = true
+			}
+		case "Flats":
+			if _, e := ParseFlats(t.Value); e != nil {
+				v.report(at, "bad Flats: %q", t.Value)
+				badConfig = true
+			}
+		case "Caps":
+			if _, e := ParseCaps(t.Value); e != nil {
+				v.report(at, "bad Caps: %q", t.Value)
+				badConfig = true
+			}
+		case "Player1", "Player2":
+			if strings.TrimSpace(t.Value) == "" {
+				v.report(at, "empty %s", t.Name)
+			}
+		case "Result":
+			if t.Value != "" && !resultRE.MatchString(t.Value) {
+				v.report(at, "bad Result: %q", t.Value)
+			}
+		}
+	}
+	for _, name := range requiredTags {
+		if !seen[name] {
+			v.report(first, "missing %s tag", name)
+		}
+	}
+	if !seen["Size"] || badConfig {
+		return nil
+	}
+	p, e := v.p.InitialPosition()
+	if e != nil {
+		at := first
+		for i, t := range v.p.Tags {
+			if t.Name == "TPS" {
+				at = v.tagPos(i)
+			}
+		}
+		v.report(at, "initial position: %v", e)
+		return nil
+	}
+	return p
+}
+
+// checkLine checks the moves in `ops`, played from `p`, and returns
+// the final position, or nil if the line contains an illegal move.
+func (v *validator) checkLine(ops []Op, p *tak.Position) *tak.Position {
+	var before *tak.Position
+	numbered, first, over := false, true, false
+	for _, op := range ops {
+		at := v.opPos(op)
+		switch o := op.(type) {
+		case *MoveNumber:
+			if expect := p.MoveNumber()/2 + 1; o.Number != expect {
+				v.report(at, "move number %d, expected %d", o.Number, expect)
+			} else if p.ToMove() == tak.Black && !first &&
+				!strings.HasSuffix(o.Source(), "...") {
+				v.report(at, "move number %d before black's move", o.Number)
+			}
+			numbered = true
+		case *Move:
+			if over {
+				v.report(at, "move %s after the end of the game", FormatMove(o.Move))
+				continue
+			}
+			if p.ToMove() == tak.White && !numbered {
+				v.report(at, "missing move number before %s", FormatMove(o.Move))
+			}
+			next, e := p.Move(o.Move)
+			if e != nil {
+				v.report(at, "illegal move %s: %v", FormatMove(o.Move), e)
+				return nil
+			}
+			before, p = p, next
+			numbered, first = false, false
+			over, _ = p.GameOver()
+		case *Variation:
+			if before == nil {
+				v.report(at, "variation does not follow a move")
+				continue
+			}
+			v.checkLine(o.Ops, before)
+		case *Result:
+			if over {
+				if want := ResultFromGame(p).Result; o.Result != want {
+					v.report(at, "result %s, but the game ended %s", o.Result, want)
+				}
+			} else if !resignation(o.Result) {
+				v.report(at, "result %s, but the game is not over", o.Result)
+			}
+		}
+	}
+	return p
+}
+
+// resignation reports whether `result` is one that can end a game
+// that is not over on the board: by resignation, time or agreement.
+func resignation(result string) bool {
+	switch result {
+	case "1-0", "0-1", "1/2-1/2":
+		return true
+	}
+	return false
+}
+
+// checkResult checks the Result tag against the final position of
+// the main line, if it is known.
+func (v *validator) checkResult(final *tak.Position) {
+	for i, t := range v.p.Tags {
+		if t.Name != "Result" || t.Value == "" || !resultRE.MatchString(t.Value) || final == nil {
+			continue
+		}
+		at := v.tagPos(i)
+		if over, _ := final.GameOver(); over {
+			if want := ResultFromGame(final).Result; t.Value != want {
+				v.report(at, "Result tag %s, but the game ended %s", t.Value, want)
+			}
+		} else if !resignation(t.Value) {
+			v.report(at, "Result tag %s, but the game is not over", t.Value)
+		}
+	}
+	for _, op := range v.p.Ops {
+		if r, ok := op.(*Result); ok {
+			if tag := v.p.FindTag("Result"); tag != "" && tag != r.Result {
+				v.report(v.opPos(op), "result %s does not match Result tag %s", r.Result, tag)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package ptn
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		ptn   string
+		diags []string
+	}{
+		{`[Size "5"]
+[Player1 "a"]
+[Player2 "b"]
+[Result "0-R"]
+
+1. a1 e1
+2. e2 a2
+3. e3 a3
+4. e4 a4
+5. d3 a5
+0-R
+`, nil},
+		{`[Size "5"]
+[Player1 "a"]
+[Player2 "b"]
+
+1. a1 e5 (e4 2. e3)
+2. e4 a2 (2... e3 3. a3)
+`, nil},
+		{`[Size "5"]
+[Player1 "a"]
+[Player2 "b"]
+[Result "R-0"]
+
+1. a1 e5
+3. e4 a2 b1
+`, []string{
+			"4:1: Result tag R-0, but the game is not over",
+			"7:1: move number 3, expected 2",
+			"7:10: missing move number before b1",
+		}},
+		{`[Size "5"]
+[Player2 "b"]
+[Player2 "c"]
+[Komi "1.25"]
+
+1. a1
+`, []string{
+			"1:1: missing Player1 tag",
+			"3:1: duplicate Player2 tag",
+			"4:1: bad Komi: \"1.25\"",
+		}},
+		{`[Size "3"]
+[Player1 "a"]
+[Player2 "b"]
+[TPS "111,111,111/111,x2/x3 2 1"]
+`, []string{
+			"4:1: initial position: bad TPS: white has 12 stones, more than the reserve of 10",
+		}},
+		{`[Size "5"]
+[Player1 "a"]
+[Player2 "b"]
+[Result "F-0"]
+
+1. a1 e5 (e4 2. a1)
+2. e5 b2
+F-0
+`, []string{
+			"6:17: illegal move a1: position is occupied",
+			"7:4: illegal move e5: position is occupied",
+		}},
+	}
+	for i, tc := range cases {
+		g, err := ParsePTN(strings.NewReader(tc.ptn))
+		if err != nil {
+			t.Errorf("[%d] parse: %v", i, err)
+			continue
+		}
+		var got []string
+		for _, d := range Validate(g) {
+			got = append(got, d.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tc.diags, "\n") {
+			t.Errorf("[%d] diagnostics:\n%s\nexpected:\n%s", i,
+				strings.Join(got, "\n"), strings.Join(tc.diags, "\n"))
+		}
+	}
+}
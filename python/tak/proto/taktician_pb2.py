@@ -14,12 +14,13 @@ _sym_db = _symbol_database.Default()
 
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x19tak/proto/taktician.proto\x12\ttak.proto\"B\n\x0e\x41nalyzeRequest\x12\x10\n\x08position\x18\x01 \x01(\t\x12\r\n\x05\x64\x65pth\x18\x02 \x01(\x05\x12\x0f\n\x07precise\x18\x03 \x01(\x08\";\n\x0f\x41nalyzeResponse\x12\n\n\x02pv\x18\x01 \x03(\t\x12\r\n\x05value\x18\x02 \x01(\x03\x12\r\n\x05\x64\x65pth\x18\x03 \x01(\x05\"2\n\x13\x43\x61nonicalizeRequest\x12\x0c\n\x04size\x18\x01 \x01(\x05\x12\r\n\x05moves\x18\x02 \x03(\t\"%\n\x14\x43\x61nonicalizeResponse\x12\r\n\x05moves\x18\x01 \x03(\t\"*\n\x16IsPositionInTakRequest\x12\x10\n\x08position\x18\x01 \x01(\t\"9\n\x17IsPositionInTakResponse\x12\r\n\x05inTak\x18\x01 \x01(\x08\x12\x0f\n\x07takMove\x18\x02 \x01(\t2\xfe\x01\n\tTaktician\x12\x42\n\x07\x41nalyze\x12\x19.tak.proto.AnalyzeRequest\x1a\x1a.tak.proto.AnalyzeResponse\"\x00\x12Q\n\x0c\x43\x61nonicalize\x12\x1e.tak.proto.CanonicalizeRequest\x1a\x1f.tak.proto.CanonicalizeResponse\"\x00\x12Z\n\x0fIsPositionInTak\x12!.tak.proto.IsPositionInTakRequest\x1a\".tak.proto.IsPositionInTakResponse\"\x00\x42!Z\x1fgithub.com/nelhage/taktician/pbb\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x19tak/proto/taktician.proto\x12\ttak.proto\"T\n\x0e\x41nalyzeRequest\x12\x10\n\x08position\x18\x01 \x01(\t\x12\r\n\x05\x64\x65pth\x18\x02 \x01(\x05\x12\x0f\n\x07precise\x18\x03 \x01(\x08\x12\x10\n\x08multi_pv\x18\x04 \x01(\x05\"[\n\x0f\x41nalyzeResponse\x12\n\n\x02pv\x18\x01 \x03(\t\x12\r\n\x05value\x18\x02 \x01(\x03\x12\r\n\x05\x64\x65pth\x18\x03 \x01(\x05\x12\x1e\n\x05lines\x18\x04 \x03(\x0b\x32\x0f.tak.proto.Line\"!\n\x04Line\x12\n\n\x02pv\x18\x01 \x03(\t\x12\r\n\x05value\x18\x02 \x01(\x03\"2\n\x13\x43\x61nonicalizeRequest\x12\x0c\n\x04size\x18\x01 \x01(\x05\x12\r\n\x05moves\x18\x02 \x03(\t\"%\n\x14\x43\x61nonicalizeResponse\x12\r\n\x05moves\x18\x01 \x03(\t\"*\n\x16IsPositionInTakRequest\x12\x10\n\x08position\x18\x01 \x01(\t\"9\n\x17IsPositionInTakResponse\x12\r\n\x05inTak\x18\x01 \x01(\x08\x12\x0f\n\x07takMove\x18\x02 \x01(\t2\xca\x02\n\tTaktician\x12\x42\n\x07\x41nalyze\x12\x19.tak.proto.AnalyzeRequest\x1a\x1a.tak.proto.AnalyzeResponse\"\x00\x12J\n\rAnalyzeStream\x12\x19.tak.proto.AnalyzeRequest\x1a\x1a.tak.proto.AnalyzeResponse\"\x00\x30\x01\x12Q\n\x0c\x43\x61nonicalize\x12\x1e.tak.proto.CanonicalizeRequest\x1a\x1f.tak.proto.CanonicalizeResponse\"\x00\x12Z\n\x0fIsPositionInTak\x12!.tak.proto.IsPositionInTakRequest\x1a\".tak.proto.IsPositionInTakResponse\"\x00\x42!Z\x1fgithub.com/nelhage/taktician/pbb\x06proto3')
 
 
 
 _ANALYZEREQUEST = DESCRIPTOR.message_types_by_name['AnalyzeRequest']
 _ANALYZERESPONSE = DESCRIPTOR.message_types_by_name['AnalyzeResponse']
+_LINE = DESCRIPTOR.message_types_by_name['Line']
 _CANONICALIZEREQUEST = DESCRIPTOR.message_types_by_name['CanonicalizeRequest']
 _CANONICALIZERESPONSE = DESCRIPTOR.message_types_by_name['CanonicalizeResponse']
 _ISPOSITIONINTAKREQUEST = DESCRIPTOR.message_types_by_name['IsPositionInTakRequest']
@@ -38,6 +39,13 @@ AnalyzeResponse = _reflection.GeneratedProtocolMessageType('AnalyzeResponse', (_
   })
 _sym_db.RegisterMessage(AnalyzeResponse)
 
+Line = _reflection.GeneratedProtocolMessageType('Line', (_message.Message,), {
+  'DESCRIPTOR' : _LINE,
+  '__module__' : 'tak.proto.taktician_pb2'
+  # @@protoc_insertion_point(class_scope:tak.proto.Line)
+  })
+_sym_db.RegisterMessage(Line)
+
 CanonicalizeRequest = _reflection.GeneratedProtocolMessageType('CanonicalizeRequest', (_message.Message,), {
   'DESCRIPTOR' : _CANONICALIZEREQUEST,
   '__module__' : 'tak.proto.taktician_pb2'
@@ -72,17 +80,19 @@ if _descriptor._USE_C_DESCRIPTORS == False:
   DESCRIPTOR._options = None
   DESCRIPTOR._serialized_options = b'Z\037github.com/nelhage/taktician/pb'
   _ANALYZEREQUEST._serialized_start=40
-  _ANALYZEREQUEST._serialized_end=106
-  _ANALYZERESPONSE._serialized_start=108
-  _ANALYZERESPONSE._serialized_end=167
-  _CANONICALIZEREQUEST._serialized_start=169
-  _CANONICALIZEREQUEST._serialized_end=219
-  _CANONICALIZERESPONSE._serialized_start=221
-  _CANONICALIZERESPONSE._serialized_end=258
-  _ISPOSITIONINTAKREQUEST._serialized_start=260
-  _ISPOSITIONINTAKREQUEST._serialized_end=302
-  _ISPOSITIONINTAKRESPONSE._serialized_start=304
-  _ISPOSITIONINTAKRESPONSE._serialized_end=361
-  _TAKTICIAN._serialized_start=364
-  _TAKTICIAN._serialized_end=618
+  _ANALYZEREQUEST._serialized_end=124
+  _ANALYZERESPONSE._serialized_start=126
+  _ANALYZERESPONSE._serialized_end=217
+  _LINE._serialized_start=219
+  _LINE._serialized_end=252
+  _CANONICALIZEREQUEST._serialized_start=254
+  _CANONICALIZEREQUEST._serialized_end=304
+  _CANONICALIZERESPONSE._serialized_start=306
+  _CANONICALIZERESPONSE._serialized_end=343
+  _ISPOSITIONINTAKREQUEST._serialized_start=345
+  _ISPOSITIONINTAKREQUEST._serialized_end=387
+  _ISPOSITIONINTAKRESPONSE._serialized_start=389
+  _ISPOSITIONINTAKRESPONSE._serialized_end=446
+  _TAKTICIAN._serialized_start=449
+  _TAKTICIAN._serialized_end=779
 # @@protoc_insertion_point(module_scope)
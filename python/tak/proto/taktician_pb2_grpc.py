@@ -19,6 +19,11 @@ class TakticianStub(object):
                 request_serializer=tak_dot_proto_dot_taktician__pb2.AnalyzeRequest.SerializeToString,
                 response_deserializer=tak_dot_proto_dot_taktician__pb2.AnalyzeResponse.FromString,
                 )
+        self.AnalyzeStream = channel.unary_stream(
+                '/tak.proto.Taktician/AnalyzeStream',
+                request_serializer=tak_dot_proto_dot_taktician__pb2.AnalyzeRequest.SerializeToString,
+                response_deserializer=tak_dot_proto_dot_taktician__pb2.AnalyzeResponse.FromString,
+                )
         self.Canonicalize = channel.unary_unary(
                 '/tak.proto.Taktician/Canonicalize',
                 request_serializer=tak_dot_proto_dot_taktician__pb2.CanonicalizeRequest.SerializeToString,
@@ -40,6 +45,12 @@ class TakticianServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def AnalyzeStream(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
     def Canonicalize(self, request, context):
         """Missing associated documentation comment in .proto file."""
         context.set_code(grpc.StatusCode.UNIMPLEMENTED)
@@ -60,6 +71,11 @@ def add_TakticianServicer_to_server(servicer, server):
                     request_deserializer=tak_dot_proto_dot_taktician__pb2.AnalyzeRequest.FromString,
                     response_serializer=tak_dot_proto_dot_taktician__pb2.AnalyzeResponse.SerializeToString,
             ),
+            'AnalyzeStream': grpc.unary_stream_rpc_method_handler(
+                    servicer.AnalyzeStream,
+                    request_deserializer=tak_dot_proto_dot_taktician__pb2.AnalyzeRequest.FromString,
+                    response_serializer=tak_dot_proto_dot_taktician__pb2.AnalyzeResponse.SerializeToString,
+            ),
             'Canonicalize': grpc.unary_unary_rpc_method_handler(
                     servicer.Canonicalize,
                     request_deserializer=tak_dot_proto_dot_taktician__pb2.CanonicalizeRequest.FromString,
@@ -97,6 +113,23 @@ class Taktician(object):
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
 
+    @staticmethod
+    def AnalyzeStream(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_stream(request, target, '/tak.proto.Taktician/AnalyzeStream',
+            tak_dot_proto_dot_taktician__pb2.AnalyzeRequest.SerializeToString,
+            tak_dot_proto_dot_taktician__pb2.AnalyzeResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
     @staticmethod
     def Canonicalize(request,
             target,
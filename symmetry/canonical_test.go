@@ -139,3 +139,31 @@ func TestRotations(t *testing.T) {
 		}
 	}
 }
+
+func TestCanonicalHash(t *testing.T) {
+	cases := []struct {
+		size  int
+		moves string
+	}{
+		{5, "a1"},
+		{5, "a1 e5 c3 Sc4"},
+		{6, "a1 f6 d4 d3 Cc4 c3 c4- b3 d4< b4"},
+		{7, "a1 g7 d4 d3 Cc4 c3 c4- b3 d4< Sb4 2c3<"},
+	}
+	for _, tc := range cases {
+		p := taktest.Position(tc.size, tc.moves)
+		ss, e := Symmetries(p)
+		if e != nil {
+			t.Fatal(e)
+		}
+		for i, sym := range ss {
+			if got, want := sym.P.CanonicalHash(), p.CanonicalHash(); got != want {
+				t.Errorf("%q: symmetry %d: CanonicalHash=%x, want %x",
+					tc.moves, i, got, want)
+			}
+			if i > 0 && sym.P.Hash() == p.Hash() {
+				t.Errorf("%q: symmetry %d has the same Hash", tc.moves, i)
+			}
+		}
+	}
+}
@@ -1,13 +1,17 @@
 package tak
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/nelhage/taktician/bitboard"
+)
 
 type position3 struct {
 	Position
 	alloc struct {
 		Height [3 * 3]uint8
 		Stacks [3 * 3]uint64
-		Groups [6]uint64
+		Groups [6]bitboard.Bits
 	}
 }
 
@@ -16,7 +20,7 @@ type position4 struct {
 	alloc struct {
 		Height [4 * 4]uint8
 		Stacks [4 * 4]uint64
-		Groups [8]uint64
+		Groups [8]bitboard.Bits
 	}
 }
 
@@ -25,7 +29,7 @@ type position5 struct {
 	alloc struct {
 		Height [5 * 5]uint8
 		Stacks [5 * 5]uint64
-		Groups [10]uint64
+		Groups [10]bitboard.Bits
 	}
 }
 
@@ -34,7 +38,7 @@ type position6 struct {
 	alloc struct {
 		Height [6 * 6]uint8
 		Stacks [6 * 6]uint64
-		Groups [12]uint64
+		Groups [12]bitboard.Bits
 	}
 }
 
@@ -43,7 +47,7 @@ type position7 struct {
 	alloc struct {
 		Height [7 * 7]uint8
 		Stacks [7 * 7]uint64
-		Groups [14]uint64
+		Groups [14]bitboard.Bits
 	}
 }
 
@@ -52,11 +56,39 @@ type position8 struct {
 	alloc struct {
 		Height [8 * 8]uint8
 		Stacks [8 * 8]uint64
-		Groups [16]uint64
+		Groups [16]bitboard.Bits
+	}
+}
+
+type position9 struct {
+	Position
+	alloc struct {
+		Height [9 * 9]uint8
+		Stacks [9 * 9]uint64
+		Groups [18]bitboard.Bits
+	}
+}
+
+type position10 struct {
+	Position
+	alloc struct {
+		Height [10 * 10]uint8
+		Stacks [10 * 10]uint64
+		Groups [20]bitboard.Bits
 	}
 }
 
 func alloc(tpl *Position) *Position {
+	p := allocBoard(tpl)
+	if len(tpl.deep) > 0 {
+		p.deep = append([]uint64(nil), tpl.deep...)
+	}
+	return p
+}
+
+// allocBoard returns a copy of `tpl`, whose Height and Stacks are
+// allocated along with it, but which shares its deep.
+func allocBoard(tpl *Position) *Position {
 	switch tpl.Size() {
 	case 3:
 		a := &position3{Position: *tpl}
@@ -111,6 +143,24 @@ func alloc(tpl *Position) *Position {
 		copy(a.Height, tpl.Height)
 		copy(a.Stacks, tpl.Stacks)
 
+		return &a.Position
+	case 9:
+		a := &position9{Position: *tpl}
+		a.Height = a.alloc.Height[:]
+		a.Stacks = a.alloc.Stacks[:]
+		a.analysis.WhiteGroups = a.alloc.Groups[:0]
+		copy(a.Height, tpl.Height)
+		copy(a.Stacks, tpl.Stacks)
+
+		return &a.Position
+	case 10:
+		a := &position10{Position: *tpl}
+		a.Height = a.alloc.Height[:]
+		a.Stacks = a.alloc.Stacks[:]
+		a.analysis.WhiteGroups = a.alloc.Groups[:0]
+		copy(a.Height, tpl.Height)
+		copy(a.Stacks, tpl.Stacks)
+
 		return &a.Position
 	default:
 		panic(fmt.Sprintf("illegal size: %d", tpl.Size()))
@@ -120,6 +170,7 @@ func alloc(tpl *Position) *Position {
 func copyPosition(p *Position, out *Position) {
 	h := out.Height
 	s := out.Stacks
+	d := out.deep
 	g := out.analysis.WhiteGroups
 
 	*out = *p
@@ -129,6 +180,13 @@ func copyPosition(p *Position, out *Position) {
 
 	copy(out.Height, p.Height)
 	copy(out.Stacks, p.Stacks)
+	if len(p.deep) > 0 {
+		if cap(d) < len(p.deep) {
+			d = make([]uint64, len(p.deep))
+		}
+		out.deep = d[:len(p.deep)]
+		copy(out.deep, p.deep)
+	}
 }
 
 func Alloc(size int) *Position {
@@ -7,17 +7,59 @@ import (
 )
 
 type Config struct {
-	Size      int
+	Size int
+
+	// Pieces and Capstones are the number of stones and
+	// capstones in each player's reserve. Zero means the standard
+	// reserve for the board size; set Capstones to NoCapstones
+	// for a game without capstones.
 	Pieces    int
 	Capstones int
 
+	// Komi is the number of half-flats added to Black's flat
+	// count when the game ends on flats. A Komi of 4 awards Black
+	// two flats; an odd Komi makes flat draws impossible.
+	Komi int
+
 	BlackWinsTies bool
 
 	c bitboard.Constants
 }
 
-var defaultPieces = []int{0, 0, 0, 10, 15, 21, 30, 40, 50}
-var defaultCaps = []int{0, 0, 0, 0, 0, 1, 1, 2, 2}
+// MaxSize is the largest supported board size.
+const MaxSize = 10
+
+// NoCapstones is the Config.Capstones for a game without
+// capstones.
+const NoCapstones = -1
+
+// MaxPieces is the largest reserve of stones we can represent.
+const MaxPieces = 255
+
+var defaultPieces = []int{0, 0, 0, 10, 15, 21, 30, 40, 50, 60, 75}
+var defaultCaps = []int{0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3}
+
+// DefaultReserves returns the standard number of stones and
+// capstones for a board of `size`.
+func DefaultReserves(size int) (pieces, capstones int) {
+	return defaultPieces[size], defaultCaps[size]
+}
+
+// Reserves returns the number of stones and capstones in each
+// player's reserve at the start of a game under `c`.
+func (c Config) Reserves() (pieces, capstones int) {
+	pieces, capstones = c.Pieces, c.Capstones
+	if pieces == 0 {
+		pieces = defaultPieces[c.Size]
+	}
+	switch {
+	case capstones == 0:
+		capstones = defaultCaps[c.Size]
+	case capstones < 0:
+		capstones = 0
+	}
+	return pieces, capstones
+}
 
 func New(g Config) *Position {
 	if g.Pieces == 0 {
@@ -26,22 +68,28 @@ func New(g Config) *Position {
 	if g.Capstones == 0 {
 		g.Capstones = defaultCaps[g.Size]
 	}
+	pieces, caps := g.Reserves()
 	g.c = bitboard.Precompute(uint(g.Size))
 	p := alloc(&Position{
 		cfg:         &g,
-		whiteStones: byte(g.Pieces),
-		whiteCaps:   byte(g.Capstones),
-		blackStones: byte(g.Pieces),
-		blackCaps:   byte(g.Capstones),
+		whiteStones: byte(pieces),
+		whiteCaps:   byte(caps),
+		blackStones: byte(pieces),
+		blackCaps:   byte(caps),
 		move:        0,
 
-		hash: fnvBasis,
+		hash: emptyKey,
 	})
+	if w := deepWords(pieces, caps); w > 0 {
+		p.deep = make([]uint64, w*g.Size*g.Size)
+	}
 	return p
 }
 
 func (p *Position) Clone() *Position {
-	return alloc(p)
+	c := alloc(p)
+	c.analyze()
+	return c
 }
 
 type Square []Piece
@@ -55,21 +103,26 @@ type Position struct {
 
 	move int
 
-	White    uint64
-	Black    uint64
-	Standing uint64
-	Caps     uint64
+	White    bitboard.Bits
+	Black    bitboard.Bits
+	Standing bitboard.Bits
+	Caps     bitboard.Bits
 	Height   []uint8
 	Stacks   []uint64
 
+	// deep holds the captives of tall stacks that don't fit in
+	// Stacks; see stack.go.
+	deep []uint64
+
 	analysis Analysis
 
+	// hash is the Zobrist hash of the board; see hash.go.
 	hash uint64
 }
 
 type Analysis struct {
-	WhiteGroups []uint64
-	BlackGroups []uint64
+	WhiteGroups []bitboard.Bits
+	BlackGroups []bitboard.Bits
 }
 
 // FromSquares initializes a Position with the specified squares and
@@ -85,19 +138,23 @@ func FromSquares(cfg Config, board [][]Square, move int) (*Position, error) {
 				continue
 			}
 			i := uint(x + y*p.Size())
+			if len(sq) > p.tallest() {
+				return nil, ErrStackTooTall
+			}
+			bit := bitboard.Bit(i)
 			switch sq[0].Color() {
 			case White:
-				p.White |= (1 << i)
+				p.White = p.White.Or(bit)
 			case Black:
-				p.Black |= (1 << i)
+				p.Black = p.Black.Or(bit)
 			}
 			switch sq[0].Kind() {
 			case Capstone:
-				p.Caps |= (1 << i)
+				p.Caps = p.Caps.Or(bit)
 			case Standing:
-				p.Standing |= (1 << i)
+				p.Standing = p.Standing.Or(bit)
 			}
-			for j, piece := range sq {
+			for _, piece := range sq {
 				switch piece {
 				case MakePiece(White, Capstone):
 					p.whiteCaps--
@@ -110,15 +167,10 @@ func FromSquares(cfg Config, board [][]Square, move int) (*Position, error) {
 				default:
 					return nil, errors.New("bad stone")
 				}
-				if j == 0 {
-					continue
-				}
-				if piece.Color() == Black {
-					p.Stacks[i] |= 1 << uint(j-1)
-				}
 			}
+			p.setCaptives(i, sq)
 			p.Height[i] = uint8(len(sq))
-			p.hash ^= p.hashAt(i)
+			p.toggleSquare(i)
 		}
 	}
 	p.analyze()
@@ -135,13 +187,13 @@ func (p *Position) Config() Config {
 
 func (p *Position) At(x, y int) Square {
 	i := uint(x + y*p.Size())
-	if (p.White|p.Black)&(1<<i) == 0 {
+	if !p.White.Or(p.Black).Has(i) {
 		return nil
 	}
 	sq := make(Square, p.Height[i])
 	sq[0] = p.Top(x, y)
 	for j := uint8(1); j < p.Height[i]; j++ {
-		if p.Stacks[i]&(1<<(j-1)) != 0 {
+		if p.captive(i, j-1) != 0 {
 			sq[j] = MakePiece(Black, Flat)
 		} else {
 			sq[j] = MakePiece(White, Flat)
@@ -155,17 +207,17 @@ func (p *Position) Top(x, y int) Piece {
 	var c Color
 	var k Kind
 	switch {
-	case p.White&(1<<i) != 0:
+	case p.White.Has(i):
 		c = White
-	case p.Black&(1<<i) != 0:
+	case p.Black.Has(i):
 		c = Black
 	default:
 		return 0
 	}
 	switch {
-	case p.Standing&(1<<i) != 0:
+	case p.Standing.Has(i):
 		k = Standing
-	case p.Caps&(1<<i) != 0:
+	case p.Caps.Has(i):
 		k = Capstone
 	default:
 		k = Flat
@@ -175,35 +227,31 @@ func (p *Position) Top(x, y int) Piece {
 
 func set(p *Position, x, y int8, s Square) {
 	i := uint(y*int8(p.cfg.Size) + x)
-	p.White &= ^(1 << i)
-	p.Black &= ^(1 << i)
-	p.Standing &= ^(1 << i)
-	p.Caps &= ^(1 << i)
+	bit := bitboard.Bit(i)
+	p.toggleSquare(i)
+	p.White = p.White.AndNot(bit)
+	p.Black = p.Black.AndNot(bit)
+	p.Standing = p.Standing.AndNot(bit)
+	p.Caps = p.Caps.AndNot(bit)
 	if len(s) == 0 {
 		p.Height[i] = 0
 		return
 	}
 	switch s[0].Color() {
 	case White:
-		p.White |= (1 << i)
+		p.White = p.White.Or(bit)
 	case Black:
-		p.Black |= (1 << i)
+		p.Black = p.Black.Or(bit)
 	}
 	switch s[0].Kind() {
 	case Standing:
-		p.Standing |= (1 << i)
+		p.Standing = p.Standing.Or(bit)
 	case Capstone:
-		p.Caps |= (1 << i)
+		p.Caps = p.Caps.Or(bit)
 	}
-	p.hash ^= p.hashAt(i)
 	p.Height[i] = uint8(len(s))
-	p.Stacks[i] = 0
-	for j, piece := range s[1:] {
-		if piece.Color() == Black {
-			p.Stacks[i] |= (1 << uint(j))
-		}
-	}
-	p.hash ^= p.hashAt(i)
+	p.setCaptives(i, s)
+	p.toggleSquare(i)
 }
 
 func (p *Position) ToMove() Color {
@@ -232,7 +280,7 @@ func (p *Position) GameOver() (over bool, winner Color) {
 
 	if (p.whiteStones+p.whiteCaps) != 0 &&
 		(p.blackStones+p.blackCaps) != 0 &&
-		(p.White|p.Black) != p.cfg.c.Mask {
+		p.White.Or(p.Black) != p.cfg.c.Mask {
 		return false, NoColor
 	}
 
@@ -251,15 +299,13 @@ func (p *Position) hasRoad() (Color, bool) {
 	white, black := false, false
 
 	for _, g := range p.analysis.WhiteGroups {
-		if ((g&p.cfg.c.T) != 0 && (g&p.cfg.c.B) != 0) ||
-			((g&p.cfg.c.L) != 0 && (g&p.cfg.c.R) != 0) {
+		if p.cfg.c.IsRoad(g) {
 			white = true
 			break
 		}
 	}
 	for _, g := range p.analysis.BlackGroups {
-		if ((g&p.cfg.c.T) != 0 && (g&p.cfg.c.B) != 0) ||
-			((g&p.cfg.c.L) != 0 && (g&p.cfg.c.R) != 0) {
+		if p.cfg.c.IsRoad(g) {
 			black = true
 			break
 		}
@@ -286,8 +332,8 @@ func (p *Position) Analysis() *Analysis {
 }
 
 func (p *Position) analyze() {
-	wr := p.White &^ p.Standing
-	br := p.Black &^ p.Standing
+	wr := p.White.AndNot(p.Standing)
+	br := p.Black.AndNot(p.Standing)
 	alloc := p.analysis.WhiteGroups[:0]
 	p.analysis.WhiteGroups = bitboard.FloodGroups(&p.cfg.c, wr, alloc)
 	alloc = p.analysis.WhiteGroups
@@ -295,18 +341,70 @@ func (p *Position) analyze() {
 	p.analysis.BlackGroups = bitboard.FloodGroups(&p.cfg.c, br, alloc)
 }
 
+// updateAnalysis brings p.analysis up to date after a move, given
+// the squares that counted toward each player's roads before it.
+// Groups are only recomputed for a player whose road squares
+// changed, and then only around any squares they gained. The groups
+// may end up in a different order than analyze would produce.
+func (p *Position) updateAnalysis(wr, br bitboard.Bits) {
+	nwr := p.White.AndNot(p.Standing)
+	nbr := p.Black.AndNot(p.Standing)
+	switch {
+	case nwr == wr && nbr == br:
+	case nwr == wr:
+		alloc := p.analysis.WhiteGroups
+		alloc = alloc[len(alloc):len(alloc):cap(alloc)]
+		p.analysis.BlackGroups = p.regroup(p.analysis.BlackGroups, br, nbr, alloc)
+	case nbr == br && len(p.analysis.BlackGroups) <= maxUndoGroups:
+		// The black groups share a buffer with the white ones,
+		// so set them aside while we recompute white's.
+		var black [maxUndoGroups]bitboard.Bits
+		n := copy(black[:], p.analysis.BlackGroups)
+		white := p.analysis.WhiteGroups
+		p.analysis.WhiteGroups = p.regroup(white, wr, nwr, white[:0])
+		alloc := p.analysis.WhiteGroups
+		alloc = alloc[len(alloc):len(alloc):cap(alloc)]
+		p.analysis.BlackGroups = append(alloc, black[:n]...)
+	default:
+		p.analyze()
+	}
+}
+
+// regroup appends to `out` the groups of `bits`, given `groups`, the
+// groups of `old`. `out` may share storage with `groups`, as long as
+// it starts at the same place.
+func (p *Position) regroup(groups []bitboard.Bits, old, bits bitboard.Bits, out []bitboard.Bits) []bitboard.Bits {
+	if !old.AndNot(bits).IsZero() {
+		return bitboard.FloodGroups(&p.cfg.c, bits, out)
+	}
+	added := bits.AndNot(old)
+	// Recompute the groups around the new squares, including
+	// any neighbours that were singletons, and so not in `groups`.
+	near := bitboard.Grow(&p.cfg.c, bits, added)
+	region := near
+	for _, g := range groups {
+		if g.Intersects(near) {
+			region = region.Or(g)
+		} else {
+			out = append(out, g)
+		}
+	}
+	return bitboard.FloodGroups(&p.cfg.c, region, out)
+}
+
 func (p *Position) countFlats() (w int, b int) {
-	w = bitboard.Popcount(p.White &^ (p.Standing | p.Caps))
-	b = bitboard.Popcount(p.Black &^ (p.Standing | p.Caps))
+	w = p.White.AndNot(p.Standing.Or(p.Caps)).Popcount()
+	b = p.Black.AndNot(p.Standing.Or(p.Caps)).Popcount()
 	return w, b
 }
 
 func (p *Position) flatsWinner() Color {
 	cw, cb := p.countFlats()
-	if cw > cb {
+	w, b := 2*cw, 2*cb+p.cfg.Komi
+	if w > b {
 		return White
 	}
-	if cb > cw {
+	if b > w {
 		return Black
 	}
 	if p.cfg.BlackWinsTies {
@@ -72,6 +72,37 @@ func TestHasRoadRegression(t *testing.T) {
 	}
 }
 
+func TestHasRoadLarge(t *testing.T) {
+	for _, size := range []int{9, 10} {
+		p := New(Config{Size: size})
+		for x := 0; x < size; x++ {
+			set(p, int8(x), 6, Square{MakePiece(White, Flat)})
+		}
+		p.analyze()
+		c, ok := p.hasRoad()
+		if !ok || c != White {
+			t.Errorf("size=%d row: c=%v hasRoad=%v", size, c, ok)
+		}
+
+		set(p, int8(size-1), 6, Square{MakePiece(White, Standing)})
+		p.analyze()
+		if _, ok := p.hasRoad(); ok {
+			t.Errorf("size=%d blocked row: hasRoad=%v", size, ok)
+		}
+
+		p = New(Config{Size: size})
+		for y := 0; y < size; y++ {
+			set(p, int8(y/2), int8(y), Square{MakePiece(Black, Flat)})
+			set(p, int8(y/2+1), int8(y), Square{MakePiece(Black, Flat)})
+		}
+		p.analyze()
+		c, ok = p.hasRoad()
+		if !ok || c != Black {
+			t.Errorf("size=%d staircase: c=%v hasRoad=%v", size, c, ok)
+		}
+	}
+}
+
 func TestFlatsWinner(t *testing.T) {
 	p := New(Config{Size: 5})
 	set(p, 0, 0, Square{MakePiece(White, Flat)})
@@ -304,3 +335,26 @@ func TestBlackWinsTies(t *testing.T) {
 		t.Fatal("not a draw")
 	}
 }
+
+func TestKomi(t *testing.T) {
+	cases := []struct {
+		komi   int
+		winner Color
+	}{
+		{0, White},
+		{1, White},
+		{2, NoColor},
+		{3, Black},
+		{4, Black},
+	}
+	for _, tc := range cases {
+		p := New(Config{Size: 5, Komi: tc.komi})
+		set(p, 0, 0, Square{MakePiece(White, Flat)})
+		set(p, 1, 0, Square{MakePiece(White, Flat)})
+		set(p, 2, 0, Square{MakePiece(Black, Flat)})
+		set(p, 3, 0, Square{MakePiece(Black, Standing)})
+		if w := p.flatsWinner(); w != tc.winner {
+			t.Errorf("komi=%d: winner=%s != %s", tc.komi, w, tc.winner)
+		}
+	}
+}
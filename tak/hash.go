@@ -2,47 +2,174 @@ package tak
 
 import "math/rand"
 
-const (
-	fnvBasis = 14695981039346656037
-	fnvPrime = 1099511628211
-)
+// A position's hash is a Zobrist hash: the XOR of a random key for
+// each stone, chosen by its square, its height in the stack and its
+// color, and of a key for each standing stone or capstone on top of
+// a stack. Moves update it a stone at a time.
+
+// numSymmetries is the number of symmetries of a square board.
+const numSymmetries = 8
 
-var basis [64]uint64
+var (
+	emptyKey  uint64
+	blackKey  uint64
+	stoneKeys [MaxSize * MaxSize][maxHeight][2]uint64
+	topKeys   [MaxSize * MaxSize][2]uint64
+
+	// symSquares[size][s][i] is the square to which symmetry `s`
+	// moves square `i` of a board of size `size`. The symmetries
+	// are in the same order as in the symmetry package.
+	symSquares [MaxSize + 1][numSymmetries][MaxSize * MaxSize]uint8
+)
 
 func init() {
 	r := rand.New(rand.NewSource(0x7a3))
-	for i := 0; i < 64; i++ {
-		basis[i] = uint64(r.Int63())
+	emptyKey = r.Uint64()
+	blackKey = r.Uint64()
+	for i := range stoneKeys {
+		for j := 0; j < shortHeight; j++ {
+			stoneKeys[i][j][0] = r.Uint64()
+			stoneKeys[i][j][1] = r.Uint64()
+		}
+		topKeys[i][0] = r.Uint64()
+		topKeys[i][1] = r.Uint64()
+	}
+	// Keys for the levels only tall stacks reach come last, so
+	// that they don't change the hashes of other positions.
+	for i := range stoneKeys {
+		for j := shortHeight; j < maxHeight; j++ {
+			stoneKeys[i][j][0] = r.Uint64()
+			stoneKeys[i][j][1] = r.Uint64()
+		}
+	}
+
+	for size := 3; size <= MaxSize; size++ {
+		f := func(i int) int { return size - 1 - i }
+		for x := 0; x < size; x++ {
+			for y := 0; y < size; y++ {
+				images := [numSymmetries][2]int{
+					{x, y},
+					{f(x), y},
+					{x, f(y)},
+					{y, x},
+					{f(y), f(x)},
+					{f(x), f(y)},
+					{y, f(x)},
+					{f(y), x},
+				}
+				for s, im := range images {
+					symSquares[size][s][x+y*size] = uint8(im[0] + im[1]*size)
+				}
+			}
+		}
 	}
 }
 
-func hash8(basis uint64, b byte) uint64 {
-	return (basis ^ uint64(b)) * fnvPrime
+func colorIndex(c Color) int {
+	if c == Black {
+		return 1
+	}
+	return 0
 }
 
-func hash64(basis uint64, w uint64) uint64 {
-	h := basis
-	h = (h ^ (w & 0xff)) * fnvPrime
-	h = (h ^ ((w >> 8) & 0xff)) * fnvPrime
-	h = (h ^ ((w >> 16) & 0xff)) * fnvPrime
-	h = (h ^ (w >> 24)) * fnvPrime
-	return h
+// toggleStone adds or removes the key for a stone of color `c` at
+// `level` (counting from 0 at the bottom) of square `i`.
+func (p *Position) toggleStone(i uint, level uint8, c Color) {
+	p.hash ^= stoneKeys[i][level][colorIndex(c)]
+}
+
+// toggleTop adds or removes the key for a standing stone or
+// capstone on top of square `i`.
+func (p *Position) toggleTop(i uint, k Kind) {
+	p.hash ^= topKeys[i][topIndex(k)]
+}
+
+func topIndex(k Kind) int {
+	if k == Capstone {
+		return 1
+	}
+	return 0
 }
 
-func (p *Position) hashAt(i uint) uint64 {
-	if p.Height[i] <= 1 {
+// toggleSquare adds or removes the keys for everything on square
+// `i`.
+func (p *Position) toggleSquare(i uint) {
+	p.hash ^= p.squareHash(i, i)
+}
+
+// squareHash returns the XOR of the keys for everything on square
+// `i`, as if it were on square `at`.
+func (p *Position) squareHash(i, at uint) uint64 {
+	h := p.Height[i]
+	if h == 0 {
 		return 0
 	}
-	return hash64(hash8(basis[i], p.Height[i]), p.Stacks[i])
+	top := p.Top(int(i)%p.cfg.Size, int(i)/p.cfg.Size)
+	out := stoneKeys[at][h-1][colorIndex(top.Color())]
+	if top.Kind() != Flat {
+		out ^= topKeys[at][topIndex(top.Kind())]
+	}
+	for k := uint8(0); k < h-1; k++ {
+		out ^= stoneKeys[at][h-2-k][p.captive(i, k)]
+	}
+	return out
 }
 
+// Hash returns a hash of the board and the player to move.
 func (p *Position) Hash() uint64 {
 	h := p.hash
-	h = hash64(h, p.White)
-	h = hash64(h, p.Black)
-	h = hash64(h, p.Standing)
-	h = hash64(h, p.Caps)
-	h = hash8(h, byte(p.ToMove()))
+	if p.ToMove() == Black {
+		h ^= blackKey
+	}
+	return h
+}
+
+// CanonicalHash returns a hash that, unlike Hash, is the same for
+// positions which are rotations or reflections of each other. It
+// computes the hash of each symmetry of the board, and returns the
+// least; this is much cheaper than constructing them with the
+// symmetry package, but, unlike Hash, is not maintained
+// incrementally.
+func (p *Position) CanonicalHash() uint64 {
+	var hs [numSymmetries]uint64
+	sq := &symSquares[p.cfg.Size]
+	for i := range p.Height {
+		h := p.Height[i]
+		if h == 0 {
+			continue
+		}
+		var at [numSymmetries]uint
+		for s := range at {
+			at[s] = uint(sq[s][i])
+		}
+		top := p.Top(i%p.cfg.Size, i/p.cfg.Size)
+		c := colorIndex(top.Color())
+		for s := range hs {
+			hs[s] ^= stoneKeys[at[s]][h-1][c]
+		}
+		if top.Kind() != Flat {
+			k := topIndex(top.Kind())
+			for s := range hs {
+				hs[s] ^= topKeys[at[s]][k]
+			}
+		}
+		for k := uint8(0); k < h-1; k++ {
+			c := p.captive(uint(i), k)
+			for s := range hs {
+				hs[s] ^= stoneKeys[at[s]][h-2-k][c]
+			}
+		}
+	}
+	h := hs[0]
+	for _, s := range hs[1:] {
+		if s < h {
+			h = s
+		}
+	}
+	h ^= emptyKey
+	if p.ToMove() == Black {
+		h ^= blackKey
+	}
 	return h
 }
 
@@ -61,5 +188,13 @@ func (p *Position) Equal(rhs *Position) bool {
 			return false
 		}
 	}
+	if len(p.deep) != len(rhs.deep) {
+		return false
+	}
+	for i := range p.deep {
+		if p.deep[i] != rhs.deep[i] {
+			return false
+		}
+	}
 	return true
 }
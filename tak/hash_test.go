@@ -1,6 +1,9 @@
 package tak
 
-import "testing"
+import (
+	"math/rand"
+	"testing"
+)
 
 func TestPositionEqual(t *testing.T) {
 	p := New(Config{Size: 5})
@@ -37,3 +40,44 @@ func TestPositionEqual(t *testing.T) {
 	}
 
 }
+
+func rebuild(t *testing.T, p *Position) *Position {
+	t.Helper()
+	board := make([][]Square, p.Size())
+	for y := range board {
+		board[y] = make([]Square, p.Size())
+		for x := range board[y] {
+			board[y][x] = p.At(x, y)
+		}
+	}
+	q, e := FromSquares(p.Config(), board, p.MoveNumber())
+	if e != nil {
+		t.Fatalf("FromSquares: %v", e)
+	}
+	return q
+}
+
+func TestIncrementalHash(t *testing.T) {
+	r := rand.New(rand.NewSource(18))
+	for size := 3; size <= MaxSize; size++ {
+		p := New(Config{Size: size})
+		for ply := 0; ply < 150; ply++ {
+			if over, _ := p.GameOver(); over {
+				break
+			}
+			moves := p.AllMoves(nil)
+			for {
+				next, e := p.Move(moves[r.Intn(len(moves))])
+				if e == nil {
+					p = next
+					break
+				}
+			}
+			q := rebuild(t, p)
+			if p.hash != q.hash {
+				t.Fatalf("size=%d ply=%d: incremental hash %x, from scratch %x",
+					size, ply, p.hash, q.hash)
+			}
+		}
+	}
+}
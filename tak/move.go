@@ -1,6 +1,10 @@
 package tak
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/nelhage/taktician/bitboard"
+)
 
 //go:generate stringer -type=MoveType
 type MoveType byte
@@ -65,8 +69,13 @@ var (
 	ErrIllegalSlide   = errors.New("illegal slide")
 	ErrNoCapstone     = errors.New("capstone has already been played")
 	ErrIllegalOpening = errors.New("illegal opening move")
+	ErrStackTooTall   = errors.New("stack is too tall")
 )
 
+// maxHeight is the tallest stack we can represent, as limited by
+// Position.Height; see stack.go for how the captives are stored.
+const maxHeight = 255
+
 func (p *Position) Move(m Move) (*Position, error) {
 	return p.MovePreallocated(m, nil)
 }
@@ -77,19 +86,31 @@ func (p *Position) MovePreallocated(m Move, next *Position) (*Position, error) {
 	} else {
 		copyPosition(p, next)
 	}
+	if err := p.apply(m, next); err != nil {
+		return nil, err
+	}
+	next.analyze()
+	return next, nil
+}
+
+// apply plays `m` from `p`, writing the new board into `next`, which
+// must start out as a copy of `p`, and may be `p` itself. It does not
+// update next.analysis. If the move is illegal, `next` is left in an
+// unspecified state.
+func (p *Position) apply(m Move, next *Position) error {
+	toMove, ply := p.ToMove(), p.move
 	next.move++
 	var place Piece
 	var dx, dy int8
 	switch m.Type {
 	case Pass:
-		next.analyze()
-		return next, nil
+		return nil
 	case PlaceFlat:
-		place = MakePiece(p.ToMove(), Flat)
+		place = MakePiece(toMove, Flat)
 	case PlaceStanding:
-		place = MakePiece(p.ToMove(), Standing)
+		place = MakePiece(toMove, Standing)
 	case PlaceCapstone:
-		place = MakePiece(p.ToMove(), Capstone)
+		place = MakePiece(toMove, Capstone)
 	case SlideLeft:
 		dx = -1
 	case SlideRight:
@@ -99,31 +120,32 @@ func (p *Position) MovePreallocated(m Move, next *Position) (*Position, error) {
 	case SlideDown:
 		dy = -1
 	default:
-		return nil, errors.New("invalid move type")
+		return errors.New("invalid move type")
 	}
-	if p.move < 2 {
+	if ply < 2 {
 		if place.Kind() != Flat {
-			return nil, ErrIllegalOpening
+			return ErrIllegalOpening
 		}
 		place = MakePiece(place.Color().Flip(), place.Kind())
 	}
 	i := uint(m.X + m.Y*int8(p.Size()))
+	bit := bitboard.Bit(i)
 	if place != 0 {
-		if (p.White|p.Black)&(1<<i) != 0 {
-			return nil, ErrOccupied
+		if p.White.Or(p.Black).Intersects(bit) {
+			return ErrOccupied
 		}
 
 		var stones *byte
 		switch place.Kind() {
 		case Capstone:
-			if p.ToMove() == Black {
+			if toMove == Black {
 				stones = &next.blackCaps
 			} else {
 				stones = &next.whiteCaps
 			}
-			next.Caps |= (1 << i)
+			next.Caps = next.Caps.Or(bit)
 		case Standing:
-			next.Standing |= (1 << i)
+			next.Standing = next.Standing.Or(bit)
 			fallthrough
 		case Flat:
 			if place.Color() == Black {
@@ -133,35 +155,38 @@ func (p *Position) MovePreallocated(m Move, next *Position) (*Position, error) {
 			}
 		}
 		if *stones <= 0 {
-			return nil, ErrNoCapstone
+			return ErrNoCapstone
 		}
 		*stones--
 		if place.Color() == White {
-			next.White |= (1 << i)
+			next.White = next.White.Or(bit)
 		} else {
-			next.Black |= (1 << i)
+			next.Black = next.Black.Or(bit)
 		}
 		next.Height[i]++
-		next.analyze()
-		return next, nil
+		next.toggleStone(i, 0, place.Color())
+		if place.Kind() != Flat {
+			next.toggleTop(i, place.Kind())
+		}
+		return nil
 	}
 
 	ct := uint(0)
 	for it := m.Slides.Iterator(); it.Ok(); it = it.Next() {
 		c := it.Elem()
 		if c == 0 {
-			return nil, ErrIllegalSlide
+			return ErrIllegalSlide
 		}
 		ct += uint(c)
 	}
 	if ct > uint(p.cfg.Size) || ct < 1 || ct > uint(p.Height[i]) {
-		return nil, ErrIllegalSlide
+		return ErrIllegalSlide
 	}
-	if p.ToMove() == White && p.White&(1<<i) == 0 {
-		return nil, ErrIllegalSlide
+	if toMove == White && !p.White.Intersects(bit) {
+		return ErrIllegalSlide
 	}
-	if p.ToMove() == Black && p.Black&(1<<i) == 0 {
-		return nil, ErrIllegalSlide
+	if toMove == Black && !p.Black.Intersects(bit) {
+		return ErrIllegalSlide
 	}
 
 	top := p.Top(int(m.X), int(m.Y))
@@ -170,24 +195,29 @@ func (p *Position) MovePreallocated(m Move, next *Position) (*Position, error) {
 		stack |= 1
 	}
 
-	next.Caps &= ^(1 << i)
-	next.Standing &= ^(1 << i)
+	next.Caps = next.Caps.AndNot(bit)
+	next.Standing = next.Standing.AndNot(bit)
 	if uint(next.Height[i]) == ct {
-		next.White &= ^(1 << i)
-		next.Black &= ^(1 << i)
+		next.White = next.White.AndNot(bit)
+		next.Black = next.Black.AndNot(bit)
 	} else {
 		if stack&(1<<ct) == 0 {
-			next.White |= (1 << i)
-			next.Black &= ^(1 << i)
+			next.White = next.White.Or(bit)
+			next.Black = next.Black.AndNot(bit)
 		} else {
-			next.Black |= (1 << i)
-			next.White &= ^(1 << i)
+			next.Black = next.Black.Or(bit)
+			next.White = next.White.AndNot(bit)
 		}
 	}
-	next.hash ^= next.hashAt(i)
-	next.Stacks[i] >>= ct
+	h := next.Height[i]
+	for k := uint(0); k < ct; k++ {
+		next.toggleStone(i, h-1-uint8(k), stackColor(stack, k))
+	}
+	if top.Kind() != Flat {
+		next.toggleTop(i, top.Kind())
+	}
+	next.popCaptives(i, ct)
 	next.Height[i] -= uint8(ct)
-	next.hash ^= next.hashAt(i)
 
 	x, y := m.X, m.Y
 	for it := m.Slides.Iterator(); it.Ok(); it = it.Next() {
@@ -196,59 +226,80 @@ func (p *Position) MovePreallocated(m Move, next *Position) (*Position, error) {
 		y += dy
 		if x < 0 || x >= int8(next.cfg.Size) ||
 			y < 0 || y >= int8(next.cfg.Size) {
-			return nil, ErrIllegalSlide
+			return ErrIllegalSlide
 		}
 		if int(c) < 1 || uint(c) > ct {
-			return nil, ErrIllegalSlide
+			return ErrIllegalSlide
 		}
 		i = uint(x + y*int8(p.Size()))
+		bit = bitboard.Bit(i)
 		switch {
-		case next.Caps&(1<<i) != 0:
-			return nil, ErrIllegalSlide
-		case next.Standing&(1<<i) != 0:
+		case next.Caps.Intersects(bit):
+			return ErrIllegalSlide
+		case next.Standing.Intersects(bit):
 			if ct != 1 || top.Kind() != Capstone {
-				return nil, ErrIllegalSlide
+				return ErrIllegalSlide
 			}
-			next.Standing &= ^(1 << i)
+			next.Standing = next.Standing.AndNot(bit)
+			next.toggleTop(i, Standing)
+		}
+		if int(next.Height[i])+int(c) > maxHeight {
+			return ErrStackTooTall
+		}
+		h := next.Height[i]
+		for j := uint(0); j < c; j++ {
+			next.toggleStone(i, h+uint8(j), stackColor(stack, ct-1-j))
 		}
-		next.hash ^= next.hashAt(i)
-		if next.White&(1<<i) != 0 {
-			next.Stacks[i] <<= 1
-		} else if next.Black&(1<<i) != 0 {
-			next.Stacks[i] <<= 1
-			next.Stacks[i] |= 1
+		// The dropped stones bury the old top, if any.
+		n := c - 1
+		drop := (stack >> (ct - n)) & ((1 << n) - 1)
+		if next.Black.Intersects(bit) {
+			drop |= 1 << n
 		}
-		drop := (stack >> (ct - uint(c-1))) & ((1 << (c - 1)) - 1)
-		next.Stacks[i] = next.Stacks[i]<<(c-1) | drop
+		if next.White.Or(next.Black).Intersects(bit) {
+			n++
+		}
+		next.pushCaptives(i, n, drop)
 		next.Height[i] += uint8(c)
-		next.hash ^= next.hashAt(i)
 		if stack&(1<<(ct-uint(c))) != 0 {
-			next.Black |= (1 << i)
-			next.White &= ^(1 << i)
+			next.Black = next.Black.Or(bit)
+			next.White = next.White.AndNot(bit)
 		} else {
-			next.Black &= ^(1 << i)
-			next.White |= (1 << i)
+			next.Black = next.Black.AndNot(bit)
+			next.White = next.White.Or(bit)
 		}
 		ct -= uint(c)
 		if ct == 0 {
 			switch top.Kind() {
 			case Capstone:
-				next.Caps |= (1 << i)
+				next.Caps = next.Caps.Or(bit)
 			case Standing:
-				next.Standing |= (1 << i)
+				next.Standing = next.Standing.Or(bit)
+			}
+			if top.Kind() != Flat {
+				next.toggleTop(i, top.Kind())
 			}
 		}
 	}
 
-	next.analyze()
-	return next, nil
+	return nil
+}
+
+// stackColor returns the color of the `k`th stone from the top of
+// `stack`, a stack of stones being moved in the format used by
+// apply.
+func stackColor(stack uint64, k uint) Color {
+	if stack&(1<<k) != 0 {
+		return Black
+	}
+	return White
 }
 
 var slides [][]Slides
 
 func init() {
-	slides = make([][]Slides, 10)
-	for s := 1; s <= 8; s++ {
+	slides = make([][]Slides, MaxSize+1)
+	for s := 1; s <= MaxSize; s++ {
 		slides[s] = calculateSlides(s)
 	}
 }
@@ -289,9 +340,9 @@ func (p *Position) AllMoves(moves []Move) []Move {
 			if p.move < 2 {
 				continue
 			}
-			if next == White && p.White&(1<<i) == 0 {
+			if next == White && !p.White.Has(i) {
 				continue
-			} else if next == Black && p.Black&(1<<i) == 0 {
+			} else if next == Black && !p.Black.Has(i) {
 				continue
 			}
 
@@ -1,9 +1,12 @@
 package tak
 
 import (
+	"math/rand"
 	"reflect"
 	"sort"
 	"testing"
+
+	"github.com/nelhage/taktician/bitboard"
 )
 
 func TestMove(t *testing.T) {
@@ -176,6 +179,147 @@ func TestMoveMultiDrop(t *testing.T) {
 	}
 }
 
+func TestMoveLargeBoard(t *testing.T) {
+	p := New(Config{Size: 10})
+	p.move = 4
+	var stack Square
+	for i := 0; i < 10; i++ {
+		if i%2 == 0 {
+			stack = append(stack, MakePiece(White, Flat))
+		} else {
+			stack = append(stack, MakePiece(Black, Flat))
+		}
+	}
+	set(p, 0, 6, stack)
+
+	next, e := p.Move(Move{
+		X: 0, Y: 6,
+		Type:   SlideUp,
+		Slides: MkSlides(3, 3, 4)})
+	if e != nil {
+		t.Fatalf("slide: %v", e)
+	}
+	expect := []struct {
+		x, y int
+		sq   Square
+	}{
+		{0, 6, nil},
+		{0, 7, stack[7:]},
+		{0, 8, stack[4:7]},
+		{0, 9, stack[:4]},
+	}
+	for _, e := range expect {
+		if sq := next.At(e.x, e.y); !reflect.DeepEqual(sq, e.sq) {
+			t.Errorf("%d,%d=%v!=%v", e.x, e.y, sq, e.sq)
+		}
+	}
+	if !next.Black.Has(70) || !next.White.Has(80) || !next.White.Has(90) {
+		t.Errorf("white=%v black=%v", next.White, next.Black)
+	}
+
+	next, e = p.Move(Move{
+		X: 0, Y: 6,
+		Type:   SlideRight,
+		Slides: MkSlides(2, 1, 1, 1, 1, 1, 1, 1, 1)})
+	if e != nil {
+		t.Fatalf("slide: %v", e)
+	}
+	if sq := next.At(1, 6); !reflect.DeepEqual(sq, stack[8:]) {
+		t.Errorf("1,6=%v", sq)
+	}
+	for x := 2; x < 10; x++ {
+		if sq := next.At(x, 6); !reflect.DeepEqual(sq, stack[9-x:10-x]) {
+			t.Errorf("%d,6=%v", x, sq)
+		}
+	}
+}
+
+func TestStackTooTall(t *testing.T) {
+	var tall Square
+	for i := 0; i <= maxHeight; i++ {
+		tall = append(tall, MakePiece(White, Flat))
+	}
+	_, e := FromSquares(Config{Size: 10}, [][]Square{{tall}}, 2)
+	if e != ErrStackTooTall {
+		t.Errorf("FromSquares: err=%v", e)
+	}
+}
+
+func TestTallStack(t *testing.T) {
+	// A 10x10 game has 156 stones, enough for stacks whose
+	// captives don't fit in a word.
+	w, b := MakePiece(White, Flat), MakePiece(Black, Flat)
+	short := Square{w, b, w, b, w}
+	var tall Square
+	for i := 0; i < 70; i++ {
+		if i%3 == 1 {
+			tall = append(tall, b)
+		} else {
+			tall = append(tall, w)
+		}
+	}
+	board := func(a, b Square) [][]Square {
+		rows := make([][]Square, 10)
+		for y := range rows {
+			rows[y] = make([]Square, 10)
+		}
+		rows[0][0], rows[0][1] = a, b
+		return rows
+	}
+	p, e := FromSquares(Config{Size: 10}, board(tall, short), 2)
+	if e != nil {
+		t.Fatal("FromSquares: ", e)
+	}
+	if sq := p.At(0, 0); !reflect.DeepEqual(sq, tall) {
+		t.Fatalf("0,0=%v", sq)
+	}
+
+	check := func(p *Position, a, b Square) {
+		t.Helper()
+		if sq := p.At(0, 0); !reflect.DeepEqual(sq, a) {
+			t.Errorf("0,0=%v, want %v", sq, a)
+		}
+		if sq := p.At(1, 0); !reflect.DeepEqual(sq, b) {
+			t.Errorf("1,0=%v, want %v", sq, b)
+		}
+		want, e := FromSquares(Config{Size: 10}, board(a, b), p.MoveNumber())
+		if e != nil {
+			t.Fatal("FromSquares: ", e)
+		}
+		if !p.Equal(want) {
+			t.Error("position differs from FromSquares")
+		}
+	}
+
+	onto := Move{X: 1, Y: 0, Type: SlideLeft, Slides: MkSlides(5)}
+	next, e := p.Move(onto)
+	if e != nil {
+		t.Fatal("slide onto: ", e)
+	}
+	check(next, append(append(Square(nil), short...), tall...), nil)
+
+	next, e = next.Move(Move{Type: Pass})
+	if e != nil {
+		t.Fatal("pass: ", e)
+	}
+	off := Move{X: 0, Y: 0, Type: SlideRight, Slides: MkSlides(4)}
+	next, e = next.Move(off)
+	if e != nil {
+		t.Fatal("slide off: ", e)
+	}
+	check(next, append(short[4:], tall...), short[:4])
+
+	var u Undo
+	q := p.Clone()
+	if e := q.MakeMove(onto, &u); e != nil {
+		t.Fatal("MakeMove: ", e)
+	}
+	q.UnmakeMove(&u)
+	if !q.Equal(p) {
+		t.Error("UnmakeMove did not restore the tall stack")
+	}
+}
+
 func TestAllMovesEmptyBoard(t *testing.T) {
 	type coord struct{ x, y int8 }
 	p := New(Config{Size: 6})
@@ -306,3 +450,68 @@ func BenchmarkCopy8(b *testing.B) {
 		copyPosition(b1, b2)
 	}
 }
+
+func sameGroups(a, b []bitboard.Bits) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[bitboard.Bits]bool)
+	for _, g := range a {
+		seen[g] = true
+	}
+	for _, g := range b {
+		if !seen[g] {
+			return false
+		}
+	}
+	return true
+}
+
+func samePosition(t *testing.T, what string, got, want *Position) {
+	t.Helper()
+	if !got.Equal(want) || got.Hash() != want.Hash() ||
+		got.move != want.move ||
+		got.whiteStones != want.whiteStones || got.whiteCaps != want.whiteCaps ||
+		got.blackStones != want.blackStones || got.blackCaps != want.blackCaps {
+		t.Fatalf("%s: positions differ", what)
+	}
+	if !sameGroups(got.analysis.WhiteGroups, want.analysis.WhiteGroups) ||
+		!sameGroups(got.analysis.BlackGroups, want.analysis.BlackGroups) {
+		t.Fatalf("%s: analysis differs: %v != %v", what, got.analysis, want.analysis)
+	}
+}
+
+func TestMakeUnmake(t *testing.T) {
+	r := rand.New(rand.NewSource(17))
+	for size := 3; size <= MaxSize; size++ {
+		p := New(Config{Size: size})
+		var u Undo
+		for ply := 0; ply < 120; ply++ {
+			if over, _ := p.GameOver(); over {
+				break
+			}
+			before := p.Clone()
+			moves := p.AllMoves(nil)
+			// Also try some moves that are illegal here.
+			moves = append(moves,
+				Move{X: 0, Y: 0, Type: SlideLeft, Slides: MkSlides(1)},
+				Move{X: int8(size - 1), Y: 0, Type: SlideUp, Slides: MkSlides(1, 1, 1)})
+			for _, m := range moves {
+				want, wantErr := before.Move(m)
+				err := p.MakeMove(m, &u)
+				if err != wantErr {
+					t.Fatalf("size=%d ply=%d %#v: err=%v want %v", size, ply, m, err, wantErr)
+				}
+				if err != nil {
+					samePosition(t, "failed make", p, before)
+					continue
+				}
+				samePosition(t, "make", p, want)
+				p.UnmakeMove(&u)
+				samePosition(t, "unmake", p, before)
+			}
+			for p.MakeMove(moves[r.Intn(len(moves)-2)], &u) != nil {
+			}
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package tak
+
+import "github.com/nelhage/taktician/bitboard"
+
+const (
+	stageHash = iota
+	stageWins
+	stagePlace
+	stageSlides
+	stageDone
+)
+
+var placeTypes = [...]MoveType{PlaceFlat, PlaceStanding, PlaceCapstone}
+
+var slideDirs = [...]struct {
+	t      MoveType
+	dx, dy int8
+}{
+	{SlideLeft, -1, 0},
+	{SlideRight, 1, 0},
+	{SlideDown, 0, -1},
+	{SlideUp, 0, 1},
+}
+
+// A MoveIterator generates the legal moves in a position one at a
+// time, in stages, so that a search which cuts off early need not
+// generate the rest. It yields the hash move it was given, if that
+// is legal; then any placement that completes a road; then the
+// other placements; and then slides, starting with the shortest
+// stacks, which have the fewest. Unlike AllMoves, it only yields
+// moves that MovePreallocated will accept.
+//
+// A MoveIterator is large, so a search should keep one per ply and
+// Reset it, rather than create one per node.
+type MoveIterator struct {
+	p     *Position
+	hash  Move
+	stage int
+
+	// Placements: the next square and kind to try, and the
+	// squares where a placement of winType was yielded as a win.
+	i, kind int
+	wins    bitboard.Bits
+	winType MoveType
+
+	// Slides: the squares to slide from, in order of height, and
+	// our position in the moves from order[i].
+	order [MaxSize * MaxSize]uint8
+	n     int
+	dir   int
+	j     int
+	limit int
+	crush bool
+	tall  bool
+}
+
+// Reset starts the iterator over on `p`, trying `hash` first. A zero
+// Move means there is no hash move.
+func (it *MoveIterator) Reset(p *Position, hash Move) {
+	it.p = p
+	it.hash = hash
+	it.stage = stageHash
+	it.i, it.kind = 0, 0
+	it.wins, it.winType = bitboard.Bits{}, 0
+	it.n, it.dir, it.j = 0, 0, 0
+}
+
+// Next returns the next move, or false when there are no more.
+func (it *MoveIterator) Next() (Move, bool) {
+	if m, ok := it.NextPlace(); ok {
+		return m, true
+	}
+	if it.stage == stageSlides {
+		if m, ok := it.nextSlide(); ok {
+			return m, true
+		}
+		it.stage = stageDone
+	}
+	return Move{}, false
+}
+
+// NextPlace is like Next, but returns false once only slides remain
+// (other than the hash move, which always comes first); Next will
+// then yield them. A caller can use it to order the cheap placements
+// before generating any slides.
+func (it *MoveIterator) NextPlace() (Move, bool) {
+	for {
+		switch it.stage {
+		case stageHash:
+			it.stage = stageWins
+			if it.hash.Type != 0 && it.p.legal(it.hash) {
+				return it.hash, true
+			}
+		case stageWins:
+			if m, ok := it.nextWin(); ok {
+				return m, true
+			}
+			it.stage = stagePlace
+			it.i = 0
+		case stagePlace:
+			if m, ok := it.nextPlace(); ok {
+				return m, true
+			}
+			it.stage = stageSlides
+			it.sortStacks()
+		default:
+			return Move{}, false
+		}
+	}
+}
+
+func (it *MoveIterator) skip(m Move) bool {
+	return it.hash.Type != 0 && it.hash.Equal(m)
+}
+
+// winKind returns the kind of piece the player to move would place to
+// complete a road, if they have one.
+func (p *Position) winKind() (MoveType, bool) {
+	stones, caps := p.whiteStones, p.whiteCaps
+	if p.ToMove() == Black {
+		stones, caps = p.blackStones, p.blackCaps
+	}
+	switch {
+	case stones > 0:
+		return PlaceFlat, true
+	case caps > 0:
+		return PlaceCapstone, true
+	}
+	return 0, false
+}
+
+func (it *MoveIterator) nextWin() (Move, bool) {
+	p := it.p
+	if p.move < 2 {
+		return Move{}, false
+	}
+	t, ok := p.winKind()
+	if !ok {
+		return Move{}, false
+	}
+	c := &p.cfg.c
+	road := p.White.AndNot(p.Standing)
+	if p.ToMove() == Black {
+		road = p.Black.AndNot(p.Standing)
+	}
+	empty := c.Mask.AndNot(p.White.Or(p.Black))
+	// Only a square next to one of our road squares can join them
+	// into a road.
+	candidates := bitboard.Grow(c, empty, road).AndNot(road)
+	for it.i < len(p.Height) {
+		i := it.i
+		it.i++
+		bit := bitboard.Bit(uint(i))
+		if !candidates.Intersects(bit) {
+			continue
+		}
+		if !c.IsRoad(bitboard.Flood(c, road.Or(bit), bit)) {
+			continue
+		}
+		it.wins = it.wins.Or(bit)
+		it.winType = t
+		m := Move{X: int8(i % p.Size()), Y: int8(i / p.Size()), Type: t}
+		if it.skip(m) {
+			continue
+		}
+		return m, true
+	}
+	return Move{}, false
+}
+
+func (it *MoveIterator) nextPlace() (Move, bool) {
+	p := it.p
+	for ; it.i < len(p.Height); it.i, it.kind = it.i+1, 0 {
+		if p.Height[it.i] != 0 {
+			continue
+		}
+		for ; it.kind < len(placeTypes); it.kind++ {
+			m := Move{X: int8(it.i % p.Size()), Y: int8(it.i / p.Size()), Type: placeTypes[it.kind]}
+			if !p.canPlace(m.Type) || it.skip(m) {
+				continue
+			}
+			if m.Type == it.winType && it.wins.Has(uint(it.i)) {
+				continue
+			}
+			it.kind++
+			return m, true
+		}
+	}
+	return Move{}, false
+}
+
+// canPlace reports whether the player to move may place a piece of
+// type `t` on an empty square.
+func (p *Position) canPlace(t MoveType) bool {
+	if p.move < 2 {
+		// The first move of each player places one of their
+		// opponent's flats.
+		if t != PlaceFlat {
+			return false
+		}
+		if p.ToMove() == White {
+			return p.blackStones > 0
+		}
+		return p.whiteStones > 0
+	}
+	stones, caps := p.whiteStones, p.whiteCaps
+	if p.ToMove() == Black {
+		stones, caps = p.blackStones, p.blackCaps
+	}
+	if t == PlaceCapstone {
+		return caps > 0
+	}
+	return stones > 0
+}
+
+// sortStacks fills it.order with the stacks the player to move
+// controls, shortest first.
+func (it *MoveIterator) sortStacks() {
+	p := it.p
+	it.n, it.dir, it.j = 0, 0, 0
+	if p.move < 2 {
+		return
+	}
+	mine := p.White
+	if p.ToMove() == Black {
+		mine = p.Black
+	}
+	tallest := uint8(0)
+	for i, h := range p.Height {
+		if h > tallest {
+			tallest = h
+		}
+		if !mine.Has(uint(i)) {
+			continue
+		}
+		k := it.n
+		for k > 0 && p.Height[it.order[k-1]] > h {
+			it.order[k] = it.order[k-1]
+			k--
+		}
+		it.order[k] = uint8(i)
+		it.n++
+	}
+	// Only when a stack is nearly as tall as we can represent
+	// need we check each slide for ErrStackTooTall.
+	it.tall = int(tallest)+p.Size() > maxHeight
+	it.i = 0
+	if it.n > 0 {
+		it.startDir()
+	}
+}
+
+// startDir computes how far the stack at order[i] can slide in
+// direction `dir`: `limit` squares, or one more if its capstone can
+// flatten a standing stone there.
+func (it *MoveIterator) startDir() {
+	p := it.p
+	i := int(it.order[it.i])
+	x, y := int8(i%p.Size()), int8(i/p.Size())
+	d := slideDirs[it.dir]
+	it.j, it.limit, it.crush = 0, 0, false
+	for {
+		x, y = x+d.dx, y+d.dy
+		if x < 0 || y < 0 || x >= int8(p.Size()) || y >= int8(p.Size()) {
+			return
+		}
+		bit := bitboard.Bit(uint(x) + uint(y)*uint(p.Size()))
+		if p.Caps.Intersects(bit) {
+			return
+		}
+		if p.Standing.Intersects(bit) {
+			it.crush = p.Caps.Has(uint(i))
+			return
+		}
+		it.limit++
+	}
+}
+
+func (it *MoveIterator) nextSlide() (Move, bool) {
+	p := it.p
+	for it.i < it.n {
+		i := int(it.order[it.i])
+		h := int(p.Height[i])
+		if h > p.Size() {
+			h = p.Size()
+		}
+		ss := slides[h]
+		d := slideDirs[it.dir]
+		for it.j < len(ss) {
+			s := ss[it.j]
+			it.j++
+			l := s.Len()
+			switch {
+			case l <= it.limit:
+			case l == it.limit+1 && it.crush && s>>(4*uint(l-1)) == 1:
+			default:
+				continue
+			}
+			m := Move{X: int8(i % p.Size()), Y: int8(i / p.Size()), Type: d.t, Slides: s}
+			if it.skip(m) || (it.tall && !p.legal(m)) {
+				continue
+			}
+			return m, true
+		}
+		it.dir++
+		if it.dir == len(slideDirs) {
+			it.dir = 0
+			it.i++
+		}
+		if it.i < it.n {
+			it.startDir()
+		}
+	}
+	return Move{}, false
+}
+
+// legal reports whether MovePreallocated would accept `m`.
+func (p *Position) legal(m Move) bool {
+	size := int8(p.Size())
+	if m.X < 0 || m.Y < 0 || m.X >= size || m.Y >= size {
+		return false
+	}
+	i := uint(m.X + m.Y*size)
+	switch m.Type {
+	case Pass:
+		return true
+	case PlaceFlat, PlaceStanding, PlaceCapstone:
+		return p.Height[i] == 0 && p.canPlace(m.Type)
+	case SlideLeft, SlideRight, SlideUp, SlideDown:
+	default:
+		return false
+	}
+	if p.move < 2 {
+		return false
+	}
+	mine := p.White
+	if p.ToMove() == Black {
+		mine = p.Black
+	}
+	if !mine.Has(i) {
+		return false
+	}
+	ct := 0
+	for it := m.Slides.Iterator(); it.Ok(); it = it.Next() {
+		if it.Elem() == 0 {
+			return false
+		}
+		ct += it.Elem()
+	}
+	if ct < 1 || ct > int(size) || ct > int(p.Height[i]) {
+		return false
+	}
+	var dx, dy int8
+	for _, d := range slideDirs {
+		if d.t == m.Type {
+			dx, dy = d.dx, d.dy
+		}
+	}
+	x, y := m.X, m.Y
+	for it := m.Slides.Iterator(); it.Ok(); it = it.Next() {
+		c := it.Elem()
+		x, y = x+dx, y+dy
+		if x < 0 || y < 0 || x >= size || y >= size {
+			return false
+		}
+		j := uint(x + y*size)
+		switch {
+		case p.Caps.Has(j):
+			return false
+		case p.Standing.Has(j):
+			if c != 1 || ct != 1 || !p.Caps.Has(i) {
+				return false
+			}
+		}
+		if int(p.Height[j])+c > maxHeight {
+			return false
+		}
+		ct -= c
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package tak
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// checkIterator checks that a MoveIterator on `p` yields exactly the
+// moves from AllMoves that MovePreallocated accepts, in stage order.
+func checkIterator(t *testing.T, p *Position, hash Move) {
+	t.Helper()
+	want := make(map[Move]bool)
+	for _, m := range p.AllMoves(nil) {
+		if _, e := p.Move(m); e == nil {
+			want[m] = true
+		}
+	}
+
+	var it MoveIterator
+	it.Reset(p, hash)
+	seen := make(map[Move]bool)
+	stage, height := 0, 0
+	first := true
+	for m, ok := it.Next(); ok; m, ok = it.Next() {
+		if seen[m] {
+			t.Fatalf("move %#v yielded twice", m)
+		}
+		seen[m] = true
+		if !want[m] {
+			t.Fatalf("move %#v is not legal", m)
+		}
+		if first && want[hash] && !m.Equal(hash) {
+			t.Fatalf("first move %#v, want hash move %#v", m, hash)
+		}
+		isHash := first && m.Equal(hash)
+		first = false
+		if isHash {
+			continue
+		}
+		s := 2
+		if m.IsSlide() {
+			s = 3
+			h := int(p.Height[int(m.X)+int(m.Y)*p.Size()])
+			if h < height {
+				t.Fatalf("slide %#v from height %d after height %d", m, h, height)
+			}
+			height = h
+		} else if next, _ := p.Move(m); next.WinDetails().Reason == RoadWin {
+			s = 1
+		}
+		if s < stage {
+			t.Fatalf("move %#v in stage %d after stage %d", m, s, stage)
+		}
+		stage = s
+	}
+	for m := range want {
+		if !seen[m] {
+			t.Fatalf("missed move %#v", m)
+		}
+	}
+
+	// NextPlace yields the same moves up to the first slide, and
+	// Next the rest.
+	it.Reset(p, hash)
+	n := 0
+	for m, ok := it.NextPlace(); ok; m, ok = it.NextPlace() {
+		if m.IsSlide() && !(n == 0 && m.Equal(hash)) {
+			t.Fatalf("NextPlace yielded slide %#v", m)
+		}
+		n++
+	}
+	for m, ok := it.Next(); ok; m, ok = it.Next() {
+		if !m.IsSlide() {
+			t.Fatalf("Next yielded %#v after NextPlace", m)
+		}
+		n++
+	}
+	if n != len(seen) {
+		t.Fatalf("NextPlace and Next yielded %d moves, want %d", n, len(seen))
+	}
+}
+
+func TestMoveIterator(t *testing.T) {
+	r := rand.New(rand.NewSource(20))
+	for size := 3; size <= MaxSize; size++ {
+		p := New(Config{Size: size})
+		for ply := 0; ply < 100; ply++ {
+			if over, _ := p.GameOver(); over {
+				break
+			}
+			moves := p.AllMoves(nil)
+			checkIterator(t, p, Move{})
+			checkIterator(t, p, moves[r.Intn(len(moves))])
+			for {
+				next, e := p.Move(moves[r.Intn(len(moves))])
+				if e == nil {
+					p = next
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestMoveIteratorEdgeCases(t *testing.T) {
+	t.Log("No stones left, but a capstone")
+	p := New(Config{Size: 5})
+	p.move = 2
+	p.whiteStones = 0
+	checkIterator(t, p, Move{X: 0, Y: 0, Type: PlaceFlat})
+
+	t.Log("A capstone that can flatten a wall")
+	sq := [][]Square{
+		{{MakePiece(White, Capstone), MakePiece(White, Flat)}, {MakePiece(Black, Standing)}, nil},
+		{{MakePiece(Black, Flat)}, {MakePiece(White, Flat)}, {MakePiece(Black, Capstone)}},
+		{nil, {MakePiece(White, Standing)}, nil},
+	}
+	p, e := FromSquares(Config{Size: 3, Capstones: 1}, sq, 4)
+	if e != nil {
+		t.Fatal(e)
+	}
+	checkIterator(t, p, Move{})
+
+	t.Log("A placement that wins")
+	sq = [][]Square{
+		{{MakePiece(White, Flat)}, nil, {MakePiece(White, Flat)}},
+		{{MakePiece(Black, Flat)}, {MakePiece(Black, Flat)}, nil},
+		{nil, nil, nil},
+	}
+	p, e = FromSquares(Config{Size: 3}, sq, 4)
+	if e != nil {
+		t.Fatal(e)
+	}
+	var it MoveIterator
+	it.Reset(p, Move{})
+	if m, _ := it.Next(); !m.Equal(Move{X: 1, Y: 0, Type: PlaceFlat}) {
+		t.Fatalf("first move %#v, want the win", m)
+	}
+	checkIterator(t, p, Move{})
+}
@@ -0,0 +1,36 @@
+package tak
+
+// Perft counts the move sequences of length `depth` from `p`, for
+// checking AllMoves and MakeMove against other engines. A finished
+// game has no moves. Perft updates `p` in place as it
+// searches, and restores it before returning, so `p` must not be
+// used concurrently.
+func (p *Position) Perft(depth int) uint64 {
+	if depth <= 0 {
+		return 1
+	}
+	undo := make([]Undo, depth)
+	moves := make([][]Move, depth)
+	return p.perft(depth, undo, moves)
+}
+
+func (p *Position) perft(depth int, undo []Undo, moves [][]Move) uint64 {
+	if over, _ := p.GameOver(); over {
+		return 0
+	}
+	u := &undo[depth-1]
+	moves[depth-1] = p.AllMoves(moves[depth-1][:0])
+	var n uint64
+	for _, m := range moves[depth-1] {
+		if p.MakeMove(m, u) != nil {
+			continue
+		}
+		if depth == 1 {
+			n++
+		} else {
+			n += p.perft(depth-1, undo, moves)
+		}
+		p.UnmakeMove(u)
+	}
+	return n
+}
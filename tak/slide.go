@@ -2,15 +2,15 @@ package tak
 
 import "fmt"
 
-// Slides is essentially a packed [8]uint4, used to represent the
+// Slides is essentially a packed [16]uint4, used to represent the
 // slide counts in a Tak move in a space-efficient way. We store the
 // first drop count in (s&0xf), the next in (s&0xf0), and so on.
-type Slides uint32
+type Slides uint64
 
 func MkSlides(drops ...int) Slides {
 	var out Slides
 	for i := len(drops) - 1; i >= 0; i-- {
-		if drops[i] > 8 {
+		if drops[i] > MaxSize {
 			panic(fmt.Sprintf("bad drop: %#v", drops))
 		}
 		out = out.Prepend(drops[i])
@@ -43,7 +43,7 @@ func (s Slides) Prepend(next int) Slides {
 	return (s << 4) | Slides(next)
 }
 
-type SlideIterator uint32
+type SlideIterator uint64
 
 func (s Slides) Iterator() SlideIterator {
 	return SlideIterator(s)
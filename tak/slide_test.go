@@ -7,7 +7,7 @@ import (
 
 func TestMkSlides(t *testing.T) {
 	cases := []struct {
-		out uint32
+		out uint64
 		in  []int
 	}{
 		{
@@ -22,11 +22,15 @@ func TestMkSlides(t *testing.T) {
 			0x321,
 			[]int{1, 2, 3},
 		},
+		{
+			0x211111111,
+			[]int{1, 1, 1, 1, 1, 1, 1, 1, 2},
+		},
 	}
 
 	for _, tc := range cases {
 		s := MkSlides(tc.in...)
-		if uint32(s) != tc.out {
+		if uint64(s) != tc.out {
 			t.Errorf("%v: got %x != %x", tc.in, s, tc.out)
 		}
 
@@ -0,0 +1,108 @@
+package tak
+
+// The captives in each stack are stored a bit per stone, set for
+// black stones, starting from the stone just below the top. The 64
+// nearest the top are in Position.Stacks; a game whose reserves can
+// build taller stacks also stores, for each square, deepWords more
+// words in Position.deep, which continue the bits of Stacks.
+
+// shortHeight is the tallest stack whose captives all fit in
+// Position.Stacks.
+const shortHeight = 65
+
+// maxDeepWords is the most words we ever need in Position.deep for
+// one square.
+const maxDeepWords = (maxHeight - shortHeight + 63) / 64
+
+// deepWords returns the number of words in Position.deep for each
+// square of a game with reserves of `pieces` and `caps`.
+func deepWords(pieces, caps int) int {
+	total := 2 * (pieces + caps)
+	if total > maxHeight {
+		total = maxHeight
+	}
+	if total <= shortHeight {
+		return 0
+	}
+	return (total - shortHeight + 63) / 64
+}
+
+// deepWords returns the number of words in p.deep for each square.
+func (p *Position) deepWords() int {
+	return len(p.deep) / len(p.Height)
+}
+
+// tallest returns the tallest stack we can represent in `p`.
+func (p *Position) tallest() int {
+	return shortHeight + 64*p.deepWords()
+}
+
+// captive returns 1 if the stone `k` places below the top of square
+// `i` (counting from 0) is black, and 0 otherwise.
+func (p *Position) captive(i uint, k uint8) uint64 {
+	if k < 64 {
+		return (p.Stacks[i] >> k) & 1
+	}
+	k -= 64
+	w := p.deepWords()
+	return (p.deep[int(i)*w+int(k/64)] >> (k % 64)) & 1
+}
+
+// setCaptives stores the captives of the stack `sq`, whose first
+// element is its top, for square `i`.
+func (p *Position) setCaptives(i uint, sq Square) {
+	p.Stacks[i] = 0
+	w := p.deepWords()
+	deep := p.deep[int(i)*w : int(i+1)*w]
+	for j := range deep {
+		deep[j] = 0
+	}
+	for j, piece := range sq[1:] {
+		if piece.Color() != Black {
+			continue
+		}
+		if j < 64 {
+			p.Stacks[i] |= 1 << uint(j)
+		} else {
+			deep[(j-64)/64] |= 1 << uint((j-64)%64)
+		}
+	}
+}
+
+// pushCaptives shifts the captives of square `i` down by `n`
+// stones, and stores the low `n` bits of `bits` above them. It must
+// be called before updating p.Height[i].
+func (p *Position) pushCaptives(i uint, n uint, bits uint64) {
+	if n == 0 {
+		return
+	}
+	carry := p.Stacks[i] >> (64 - n)
+	p.Stacks[i] = p.Stacks[i]<<n | bits
+	if len(p.deep) == 0 || int(p.Height[i])+int(n) <= shortHeight {
+		return
+	}
+	w := p.deepWords()
+	for j, d := range p.deep[int(i)*w : int(i+1)*w] {
+		p.deep[int(i)*w+j] = d<<n | carry
+		carry = d >> (64 - n)
+	}
+}
+
+// popCaptives removes the top `n` captives of square `i`, shifting
+// the rest up. It must be called before updating p.Height[i].
+func (p *Position) popCaptives(i uint, n uint) {
+	if p.Height[i] <= shortHeight || len(p.deep) == 0 {
+		p.Stacks[i] >>= n
+		return
+	}
+	w := p.deepWords()
+	deep := p.deep[int(i)*w : int(i+1)*w]
+	p.Stacks[i] = p.Stacks[i]>>n | deep[0]<<(64-n)
+	for j := range deep {
+		var next uint64
+		if j+1 < len(deep) {
+			next = deep[j+1] << (64 - n)
+		}
+		deep[j] = deep[j]>>n | next
+	}
+}
@@ -0,0 +1,143 @@
+package tak
+
+import "github.com/nelhage/taktician/bitboard"
+
+// maxUndoGroups is the number of road groups an Undo can save. A
+// position with more groups than this has its analysis recomputed
+// by UnmakeMove, instead of restored.
+const maxUndoGroups = 2 * MaxSize
+
+// Undo records the state that MakeMove changes, so that UnmakeMove
+// can restore it. An Undo is large enough that searches should keep
+// a stack of them, one per ply, rather than allocate one per move.
+type Undo struct {
+	whiteStones, whiteCaps byte
+	blackStones, blackCaps byte
+
+	move int
+
+	white, black, standing, caps bitboard.Bits
+
+	hash uint64
+
+	// The squares a move may have touched, and their contents.
+	n      int
+	square [MaxSize + 1]uint16
+	height [MaxSize + 1]uint8
+	stacks [MaxSize + 1]uint64
+	deep   [MaxSize + 1][maxDeepWords]uint64
+
+	// changed is set if the move changed p.analysis, and so
+	// groups holds the groups from before it.
+	changed        bool
+	nwhite, nblack int
+	reanalyze      bool
+	groups         [maxUndoGroups]bitboard.Bits
+}
+
+// MakeMove plays `m` on `p` in place, recording in `u` what
+// UnmakeMove needs to take it back. It accepts the same moves as
+// Move; if `m` is illegal, it returns the same error and leaves `p`
+// unchanged.
+func (p *Position) MakeMove(m Move, u *Undo) error {
+	u.save(p, m)
+	if err := p.apply(m, p); err != nil {
+		p.UnmakeMove(u)
+		return err
+	}
+	wr, br := u.white.AndNot(u.standing), u.black.AndNot(u.standing)
+	if p.White.AndNot(p.Standing) != wr || p.Black.AndNot(p.Standing) != br {
+		u.saveAnalysis(p)
+		p.updateAnalysis(wr, br)
+	}
+	return nil
+}
+
+// UnmakeMove restores `p` to the position before the MakeMove call
+// that filled in `u`.
+func (p *Position) UnmakeMove(u *Undo) {
+	p.whiteStones, p.whiteCaps = u.whiteStones, u.whiteCaps
+	p.blackStones, p.blackCaps = u.blackStones, u.blackCaps
+	p.move = u.move
+	p.White, p.Black, p.Standing, p.Caps = u.white, u.black, u.standing, u.caps
+	p.hash = u.hash
+	w := p.deepWords()
+	for j := 0; j < u.n; j++ {
+		i := u.square[j]
+		p.Height[i] = u.height[j]
+		p.Stacks[i] = u.stacks[j]
+		if w > 0 {
+			copy(p.deep[int(i)*w:], u.deep[j][:w])
+		}
+	}
+
+	switch {
+	case !u.changed:
+		return
+	case u.reanalyze:
+		p.analyze()
+		return
+	}
+	groups := append(p.analysis.WhiteGroups[:0], u.groups[:u.nwhite+u.nblack]...)
+	p.analysis.WhiteGroups = groups[:u.nwhite]
+	p.analysis.BlackGroups = groups[u.nwhite:]
+}
+
+func (u *Undo) save(p *Position, m Move) {
+	u.whiteStones, u.whiteCaps = p.whiteStones, p.whiteCaps
+	u.blackStones, u.blackCaps = p.blackStones, p.blackCaps
+	u.move = p.move
+	u.white, u.black, u.standing, u.caps = p.White, p.Black, p.Standing, p.Caps
+	u.hash = p.hash
+
+	u.n = 0
+	w := p.deepWords()
+	if m.Type != Pass {
+		size := int8(p.Size())
+		x, y := m.X, m.Y
+		var dx, dy int8
+		switch m.Type {
+		case SlideLeft:
+			dx = -1
+		case SlideRight:
+			dx = 1
+		case SlideUp:
+			dy = 1
+		case SlideDown:
+			dy = -1
+		}
+		steps := 0
+		if m.IsSlide() {
+			steps = m.Slides.Len()
+		}
+		for j := 0; j <= steps && j < len(u.square); j++ {
+			if x < 0 || x >= size || y < 0 || y >= size {
+				break
+			}
+			i := uint16(x) + uint16(y)*uint16(size)
+			u.square[u.n] = i
+			u.height[u.n] = p.Height[i]
+			u.stacks[u.n] = p.Stacks[i]
+			if w > 0 {
+				copy(u.deep[u.n][:], p.deep[int(i)*w:int(i+1)*w])
+			}
+			u.n++
+			x += dx
+			y += dy
+		}
+	}
+	u.changed = false
+}
+
+// saveAnalysis records p.analysis, which MakeMove is about to
+// recompute.
+func (u *Undo) saveAnalysis(p *Position) {
+	u.changed = true
+	u.nwhite = len(p.analysis.WhiteGroups)
+	u.nblack = len(p.analysis.BlackGroups)
+	u.reanalyze = u.nwhite+u.nblack > len(u.groups)
+	if !u.reanalyze {
+		copy(u.groups[:], p.analysis.WhiteGroups)
+		copy(u.groups[u.nwhite:], p.analysis.BlackGroups)
+	}
+}
@@ -76,9 +76,21 @@ func NewClient(cmdline []string) (*Client, error) {
 	return cl, nil
 }
 
-func (c *Client) NewGame(size int) (*Player, error) {
+func (c *Client) NewGame(cfg tak.Config) (*Player, error) {
 	c.gameid += 1
-	if _, err := c.sendCommand(fmt.Sprintf("teinewgame %d", size), ""); err != nil {
+	cmd := fmt.Sprintf("teinewgame %d", cfg.Size)
+	if cfg.Komi != 0 {
+		cmd = fmt.Sprintf("%s halfkomi %d", cmd, cfg.Komi)
+	}
+	pieces, caps := cfg.Reserves()
+	defPieces, defCaps := tak.DefaultReserves(cfg.Size)
+	if pieces != defPieces {
+		cmd = fmt.Sprintf("%s flats %d", cmd, pieces)
+	}
+	if caps != defCaps {
+		cmd = fmt.Sprintf("%s caps %d", cmd, caps)
+	}
+	if _, err := c.sendCommand(cmd, ""); err != nil {
 		return nil, err
 	}
 	return &Player{
@@ -168,7 +180,7 @@ func (p *Player) TEIGetMove(ctx context.Context, pos *tak.Position, tc *TimeCont
 	if err != nil {
 		return tak.Move{}, fmt.Errorf("tei: server error: %w", err)
 	}
-	if len(bestmove) != 2 {
+	if len(bestmove) != 2 && !(len(bestmove) == 4 && bestmove[2] == "ponder") {
 		return tak.Move{}, fmt.Errorf("bad bestmove: %v", bestmove)
 	}
 	mv, err := ptn.ParseMove(bestmove[1])
@@ -0,0 +1,204 @@
+package tei
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nelhage/taktician/ai"
+)
+
+// options holds the engine options set via `setoption`. Unset
+// options are nil, and leave the value from the ConfigFactory
+// alone.
+type options struct {
+	depth    *int
+	hashMB   *int64
+	threads  *int
+	multiPV  *int
+	weights  *ai.Weights
+	halfKomi int
+}
+
+func (o *options) apply(cfg *ai.MinimaxConfig) {
+	if o.depth != nil {
+		cfg.Depth = *o.depth
+	}
+	if o.hashMB != nil {
+		cfg.TableMem = *o.hashMB << 20
+	}
+	if o.threads != nil {
+		cfg.Threads = *o.threads
+	}
+	if o.multiPV != nil {
+		cfg.MultiPV = *o.multiPV
+	}
+	if o.weights != nil {
+		w := *o.weights
+		cfg.Weights = &w
+	}
+}
+
+// engineOption describes an option we advertise during the `tei`
+// handshake and accept via `setoption`.
+type engineOption struct {
+	name     string
+	kind     string
+	def      string
+	min, max int
+	set      func(o *options, value string) error
+}
+
+// emptyString is the conventional UCI spelling of an empty string
+// option value.
+const emptyString = "<empty>"
+
+var engineOptions = []engineOption{
+	{
+		name: "Depth", kind: "spin", def: "0", min: 0, max: ai.MaxDepth,
+		set: func(o *options, value string) error {
+			d, err := parseSpin(value, 0, ai.MaxDepth)
+			o.depth = &d
+			return err
+		},
+	},
+	{
+		name: "Hash", kind: "spin",
+		def: strconv.Itoa(ai.DefaultTableMem >> 20), min: 1, max: 1 << 16,
+		set: func(o *options, value string) error {
+			mb, err := parseSpin(value, 1, 1<<16)
+			hash := int64(mb)
+			o.hashMB = &hash
+			return err
+		},
+	},
+	{
+		name: "Threads", kind: "spin", def: "1", min: 1, max: 256,
+		set: func(o *options, value string) error {
+			t, err := parseSpin(value, 1, 256)
+			o.threads = &t
+			return err
+		},
+	},
+	{
+		name: "MultiPV", kind: "spin", def: "1", min: 1, max: 256,
+		set: func(o *options, value string) error {
+			n, err := parseSpin(value, 1, 256)
+			o.multiPV = &n
+			return err
+		},
+	},
+	{
+		name: "HalfKomi", kind: "spin", def: "0", min: -20, max: 20,
+		set: func(o *options, value string) error {
+			k, err := parseSpin(value, -20, 20)
+			o.halfKomi = k
+			return err
+		},
+	},
+	{
+		// Controllers conventionally only send `go ponder` to
+		// engines advertising this option. We can always
+		// ponder, so it has no effect.
+		name: "Ponder", kind: "check", def: "false",
+		set: func(o *options, value string) error {
+			if _, err := strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("bad value: %q", value)
+			}
+			return nil
+		},
+	},
+	{
+		name: "Weights", kind: "string", def: emptyString,
+		set: func(o *options, value string) error {
+			if value == "" || value == emptyString {
+				o.weights = nil
+				return nil
+			}
+			var w ai.Weights
+			if err := json.Unmarshal([]byte(value), &w); err != nil {
+				return fmt.Errorf("bad weights: %w", err)
+			}
+			o.weights = &w
+			return nil
+		},
+	},
+}
+
+func (o *engineOption) describe() string {
+	desc := fmt.Sprintf("option name %s type %s default %s", o.name, o.kind, o.def)
+	if o.kind == "spin" {
+		desc = fmt.Sprintf("%s min %d max %d", desc, o.min, o.max)
+	}
+	return desc
+}
+
+func findOption(name string) *engineOption {
+	for i := range engineOptions {
+		if strings.EqualFold(engineOptions[i].name, name) {
+			return &engineOptions[i]
+		}
+	}
+	return nil
+}
+
+func parseSpin(value string, min, max int) (int, error) {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("bad value: %q", value)
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("value out of range [%d, %d]: %d", min, max, v)
+	}
+	return v, nil
+}
+
+// parseSetOption parses a `setoption name NAME [value VALUE]`
+// command. The value may contain spaces.
+func parseSetOption(words []string) (name, value string, err error) {
+	words = words[1:]
+	if len(words) < 2 || words[0] != "name" {
+		return "", "", errors.New("expected `name'")
+	}
+	words = words[1:]
+	i := 0
+	for i < len(words) && words[i] != "value" {
+		i++
+	}
+	name = strings.Join(words[:i], " ")
+	if name == "" {
+		return "", "", errors.New("missing option name")
+	}
+	if i < len(words) {
+		value = strings.Join(words[i+1:], " ")
+	}
+	return name, value, nil
+}
+
+func (e *Engine) setOption(words []string) error {
+	name, value, err := parseSetOption(words)
+	if err != nil {
+		return err
+	}
+	opt := findOption(name)
+	if opt == nil {
+		return fmt.Errorf("Unknown option: %s", name)
+	}
+	opts := e.opts
+	if err := opt.set(&opts, value); err != nil {
+		return fmt.Errorf("%s: %w", opt.name, err)
+	}
+	e.opts = opts
+	if opt.name == "HalfKomi" {
+		e.cfg.Komi = opts.halfKomi
+		if e.posWords != nil {
+			if e.pos, err = parsePosition(e.cfg, e.posWords); err != nil {
+				return fmt.Errorf("position: %w", err)
+			}
+		}
+	}
+	e.mm = nil
+	return nil
+}
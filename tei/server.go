@@ -9,22 +9,54 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/nelhage/taktician/ai"
 	"github.com/nelhage/taktician/ptn"
 	"github.com/nelhage/taktician/tak"
+	"github.com/nelhage/taktician/timemanager"
 )
 
 type Engine struct {
 	ConfigFactory func(size int) ai.MinimaxConfig
 
-	in  *bufio.Reader
-	out io.Writer
+	in *bufio.Reader
+
+	outMu sync.Mutex
+	out   io.Writer
 
 	mm   *ai.MinimaxAI
 	pos  *tak.Position
-	size int
+	cfg  tak.Config
+	opts options
+
+	// posWords is the `position` command that set pos, which we
+	// parse again if the komi changes.
+	posWords []string
+
+	search *search
+}
+
+// search is a `go` command running in the background.
+type search struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// unbounded is set if the search has neither a time limit nor
+	// a depth limit short of MaxDepth, and so will only finish when
+	// stopped.
+	unbounded bool
+
+	// For `go ponder`, hit is closed on `ponderhit`, which calls
+	// start to start the clock of the search's budget; hard is
+	// its hard limit. pondering and timer are only accessed by
+	// the command loop.
+	hit       chan struct{}
+	start     func()
+	hard      time.Duration
+	pondering bool
+	timer     *time.Timer
 }
 
 func NewEngine(in io.Reader, out io.Writer) *Engine {
@@ -34,10 +66,27 @@ func NewEngine(in io.Reader, out io.Writer) *Engine {
 	}
 }
 
+func (e *Engine) println(args ...interface{}) {
+	e.outMu.Lock()
+	defer e.outMu.Unlock()
+	fmt.Fprintln(e.out, args...)
+}
+
+func (e *Engine) printf(format string, args ...interface{}) {
+	e.outMu.Lock()
+	defer e.outMu.Unlock()
+	fmt.Fprintf(e.out, format, args...)
+}
+
 func (e *Engine) Run(ctx context.Context) error {
+	defer e.stop()
 	for {
 		line, err := e.in.ReadString('\n')
 		if err == io.EOF {
+			if e.search != nil && (e.search.pondering || e.search.unbounded) {
+				e.stop()
+			}
+			e.wait()
 			return nil
 		}
 		if err != nil {
@@ -50,46 +99,143 @@ func (e *Engine) Run(ctx context.Context) error {
 		words := strings.Fields(line)
 		switch words[0] {
 		case "tei":
-			fmt.Fprintln(e.out, "id name Taktician")
-			fmt.Fprintln(e.out, "id author Nelson Elhage")
-			fmt.Fprintln(e.out, "teiok")
+			e.println("id name Taktician")
+			e.println("id author Nelson Elhage")
+			for _, o := range engineOptions {
+				e.println(o.describe())
+			}
+			e.println("teiok")
 		case "quit":
 			return nil
 		case "teinewgame":
+			e.stop()
 			e.mm = nil
 			e.pos = nil
-			if len(words) > 1 {
-				e.size, err = strconv.Atoi(words[1])
-				if err != nil || e.size < 3 || e.size > 8 {
-					return fmt.Errorf("Bad size: %s", words[1])
-				}
-			} else {
-				e.size = 5
+			e.posWords = nil
+			e.cfg, err = parseNewGame(words, e.opts.halfKomi)
+			if err != nil {
+				return err
 			}
 			break
+		case "setoption":
+			e.stop()
+			// Controllers may send options we don't
+			// support; we ignore them.
+			if err := e.setOption(words); err != nil {
+				log.Printf("setoption: %v", err)
+			}
 		case "position":
-			e.pos, err = parsePosition(e.size, words)
+			e.stop()
+			e.pos, err = parsePosition(e.cfg, words)
+			e.posWords = words
 			if err != nil {
 				return fmt.Errorf("error parsing position: %w\n", err)
 			}
 			break
 		case "go":
+			e.stop()
 			if err := e.analyze(ctx, words); err != nil {
 				log.Printf("error in go: %v\n", err)
 				break
 			}
 			break
 		case "stop":
-			break
+			e.stop()
+		case "ponderhit":
+			e.ponderhit()
 		case "isready":
-			fmt.Fprintln(e.out, "readyok")
+			e.println("readyok")
 		default:
 			return fmt.Errorf("Unknown command: %q", line)
 		}
 	}
 }
 
-func parsePosition(size int, words []string) (*tak.Position, error) {
+// stop cancels the running search, if any, and waits for it to
+// report its best move.
+func (e *Engine) stop() {
+	if e.search != nil {
+		e.search.cancel()
+	}
+	e.wait()
+}
+
+// wait waits for the running search, if any, to finish on its own.
+func (e *Engine) wait() {
+	if e.search != nil {
+		<-e.search.done
+		e.search.cancel()
+		if e.search.timer != nil {
+			e.search.timer.Stop()
+		}
+		e.search = nil
+	}
+}
+
+// ponderhit tells a `go ponder` search that the opponent played the
+// expected move, so it should now search under its time control.
+func (e *Engine) ponderhit() {
+	s := e.search
+	if s == nil || !s.pondering {
+		log.Printf("ponderhit: not pondering")
+		return
+	}
+	s.pondering = false
+	s.start()
+	close(s.hit)
+	// The search's Tracker should stop it by the soft limit; the
+	// timer is a backstop.
+	if s.hard > 0 {
+		s.timer = time.AfterFunc(s.hard, s.cancel)
+	}
+}
+
+// parseNewGame parses a `teinewgame [SIZE [OPTION VALUE]...]`
+// command. The options are `halfkomi`, which sets the komi in
+// half-flats (if it is absent, the komi is `halfKomi`), and `flats`
+// and `caps`, which set the size of each player's reserve.
+func parseNewGame(words []string, halfKomi int) (tak.Config, error) {
+	cfg := tak.Config{Size: 5, Komi: halfKomi}
+	words = words[1:]
+	if len(words) == 0 {
+		return cfg, nil
+	}
+	var err error
+	cfg.Size, err = strconv.Atoi(words[0])
+	if err != nil || cfg.Size < 3 || cfg.Size > tak.MaxSize {
+		return cfg, fmt.Errorf("Bad size: %s", words[0])
+	}
+	words = words[1:]
+	for len(words) > 0 {
+		if len(words) == 1 {
+			return cfg, fmt.Errorf("%s: expected arg", words[0])
+		}
+		opt, arg := words[0], words[1]
+		words = words[2:]
+		switch strings.ToLower(opt) {
+		case "halfkomi":
+			cfg.Komi, err = strconv.Atoi(arg)
+			if err != nil {
+				return cfg, fmt.Errorf("Bad komi: %s", arg)
+			}
+		case "flats":
+			cfg.Pieces, err = ptn.ParseFlats(arg)
+			if err != nil {
+				return cfg, fmt.Errorf("Bad flats: %s", arg)
+			}
+		case "caps":
+			cfg.Capstones, err = ptn.ParseCaps(arg)
+			if err != nil {
+				return cfg, fmt.Errorf("Bad caps: %s", arg)
+			}
+		default:
+			return cfg, fmt.Errorf("Unknown teinewgame option: %s", opt)
+		}
+	}
+	return cfg, nil
+}
+
+func parsePosition(cfg tak.Config, words []string) (*tak.Position, error) {
 	var pos *tak.Position
 	words = words[1:]
 	if len(words) == 0 {
@@ -98,20 +244,20 @@ func parsePosition(size int, words []string) (*tak.Position, error) {
 	switch words[0] {
 	case "startpos":
 		words = words[1:]
-		pos = tak.New(tak.Config{Size: size})
+		pos = tak.New(cfg)
 	case "tps":
 		// tps A B C
 		if len(words) < 4 {
 			return nil, errors.New("position tps: not enough arguments")
 		}
 		var err error
-		pos, err = ptn.ParseTPS(strings.Join(words[1:4], " "))
+		pos, err = ptn.ParseTPSWithConfig(strings.Join(words[1:4], " "), cfg)
 		if err != nil {
 			return nil, fmt.Errorf("Parse TPS: %w", err)
 		}
 		words = words[4:]
-		if pos.Size() != size {
-			return nil, fmt.Errorf("tps has wrong size: got %d, configured for %d", pos.Size(), size)
+		if pos.Size() != cfg.Size {
+			return nil, fmt.Errorf("tps has wrong size: got %d, configured for %d", pos.Size(), cfg.Size)
 		}
 	default:
 		return nil, fmt.Errorf("Unknown initial position: %q", words[0])
@@ -136,18 +282,52 @@ func parsePosition(size int, words []string) (*tak.Position, error) {
 	return pos, nil
 }
 
-func calcBudget(movetime time.Duration, gametime time.Duration, inc time.Duration) time.Duration {
-	var budget time.Duration
-	if gametime != 0 {
-		budget = gametime/5 + inc
-		if budget > gametime-time.Millisecond {
-			budget = gametime - time.Millisecond
+func (e *Engine) newAI() *ai.MinimaxAI {
+	var cfg ai.MinimaxConfig
+	if e.ConfigFactory != nil {
+		cfg = e.ConfigFactory(e.cfg.Size)
+	} else {
+		cfg = ai.MinimaxConfig{
+			Size: e.cfg.Size,
 		}
 	}
-	if movetime > 0 && (budget == 0 || movetime < budget) {
-		budget = movetime
+	e.opts.apply(&cfg)
+	return ai.NewMinimax(cfg)
+}
+
+// printInfo reports the result of a search iteration. In MultiPV
+// mode, it prints one line for each of mm's lines, tagged with its
+// rank.
+func (e *Engine) printInfo(mm *ai.MinimaxAI, pv []tak.Move, val int64, stats ai.Stats) {
+	if mm.Cfg.MultiPV < 2 {
+		e.printf("info depth %d time %d nodes %d score cp %d pv%s\n",
+			stats.Depth,
+			stats.Elapsed/time.Millisecond,
+			stats.Visited,
+			val,
+			formatPV(pv),
+		)
+		return
+	}
+	for i, l := range mm.Lines() {
+		e.printf("info depth %d multipv %d time %d nodes %d score cp %d pv%s\n",
+			stats.Depth,
+			i+1,
+			stats.Elapsed/time.Millisecond,
+			stats.Visited,
+			l.Value,
+			formatPV(l.PV),
+		)
 	}
-	return budget
+}
+
+func formatPV(pv []tak.Move) string {
+	var pvs strings.Builder
+	for _, m := range pv {
+		pvs.WriteString(" ")
+		pvs.WriteString(ptn.FormatMove(m))
+	}
+	return pvs.String()
 }
 
 func (e *Engine) analyze(ctx context.Context, words []string) error {
@@ -155,15 +335,7 @@ func (e *Engine) analyze(ctx context.Context, words []string) error {
 		return errors.New("No position provided")
 	}
 	if e.mm == nil {
-		var cfg ai.MinimaxConfig
-		if e.ConfigFactory != nil {
-			cfg = e.ConfigFactory(e.size)
-		} else {
-			cfg = ai.MinimaxConfig{
-				Size: e.size,
-			}
-		}
-		e.mm = ai.NewMinimax(cfg)
+		e.mm = e.newAI()
 	}
 	words = words[1:]
 	var movetime time.Duration
@@ -175,8 +347,19 @@ func (e *Engine) analyze(ctx context.Context, words []string) error {
 		"winc":     &tc.WInc,
 		"binc":     &tc.BInc,
 	}
+	var ponder, infinite bool
 	for len(words) > 0 {
 		opt := words[0]
+		if opt == "ponder" {
+			ponder = true
+			words = words[1:]
+			continue
+		}
+		if opt == "infinite" {
+			infinite = true
+			words = words[1:]
+			continue
+		}
 		if len(words) == 1 {
 			return fmt.Errorf("%s: expected arg", opt)
 		}
@@ -199,26 +382,74 @@ func (e *Engine) analyze(ctx context.Context, words []string) error {
 		tm, inc = tc.Black, tc.BInc
 	}
 
-	if movetime > 0 || tm > 0 {
-		budget := calcBudget(movetime, tm, inc)
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, budget)
-		defer cancel()
+	budget := timemanager.Allocate(timemanager.Clock{
+		Remaining: tm,
+		Increment: inc,
+		MoveTime:  movetime,
+	}, e.pos)
+	if infinite {
+		budget = timemanager.Budget{}
+	}
+	s := &search{done: make(chan struct{})}
+	s.unbounded = budget.Hard == 0 && e.mm.Cfg.Depth >= ai.MaxDepth
+	if ponder {
+		// The clock doesn't start until ponderhit
+		s.hit = make(chan struct{})
+		s.hard = budget.Hard
+		s.pondering = true
+		ctx, s.start = timemanager.WithPonder(ctx, budget)
+		ctx, s.cancel = context.WithCancel(ctx)
+	} else {
+		ctx, s.cancel = timemanager.WithBudget(ctx, budget)
 	}
 
-	pv, val, stats := e.mm.Analyze(ctx, e.pos)
-	var pvs strings.Builder
-	for _, m := range pv {
-		pvs.WriteString(" ")
-		pvs.WriteString(ptn.FormatMove(m))
+	// reported records whether the search has reported an
+	// iteration; it is only accessed on the search's goroutine.
+	var reported bool
+	mm := e.mm
+	mm.Cfg.OnIteration = func(pv []tak.Move, val int64, stats ai.Stats) {
+		reported = true
+		e.printInfo(mm, pv, val, stats)
 	}
-	fmt.Fprintf(e.out, "info depth %d time %d nodes %d score cp %d pv%s\n",
-		stats.Depth,
-		stats.Elapsed/time.Millisecond,
-		stats.Visited,
-		val,
-		pvs.String(),
-	)
-	fmt.Fprintf(e.out, "bestmove %s\n", ptn.FormatMove(pv[0]))
+
+	e.search = s
+	go func(mm *ai.MinimaxAI, pos *tak.Position) {
+		defer close(s.done)
+		pv, val, stats := mm.Analyze(ctx, pos)
+		if s.hit != nil {
+			// We may not report a move while pondering, even
+			// if the search finished.
+			select {
+			case <-s.hit:
+			case <-ctx.Done():
+			}
+		}
+		var best tak.Move
+		if len(pv) > 0 {
+			if !reported {
+				// We were stopped before completing an
+				// iteration, with a move from the
+				// transposition table.
+				e.printInfo(mm, pv, val, stats)
+			}
+			best = pv[0]
+		} else {
+			// We were stopped before completing a single
+			// iteration; we still owe the controller a
+			// legal move.
+			for _, m := range pos.AllMoves(nil) {
+				if _, err := pos.Move(m); err == nil {
+					best = m
+					break
+				}
+			}
+		}
+		if len(pv) > 1 {
+			e.printf("bestmove %s ponder %s\n",
+				ptn.FormatMove(best), ptn.FormatMove(pv[1]))
+		} else {
+			e.printf("bestmove %s\n", ptn.FormatMove(best))
+		}
+	}(e.mm, e.pos)
 	return nil
 }
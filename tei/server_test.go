@@ -1,33 +1,278 @@
 package tei
 
 import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestCalcBudget(t *testing.T) {
+func TestParseNewGame(t *testing.T) {
 	cases := []struct {
-		Move   time.Duration
-		Game   time.Duration
-		Inc    time.Duration
-		Expect time.Duration
+		cmd  string
+		size int
+		komi int
+		err  bool
 	}{
-		{0, 3 * time.Second, 3 * time.Second, 0},
-		{time.Second, 3 * time.Second, 3 * time.Second, time.Second},
-		{5 * time.Second, 3 * time.Second, 3 * time.Second, 0},
+		{"teinewgame", 5, 0, false},
+		{"teinewgame 6", 6, 0, false},
+		{"teinewgame 6 halfkomi 4", 6, 4, false},
+		{"teinewgame 6 HalfKomi -1", 6, -1, false},
+		{"teinewgame 10", 10, 0, false},
+		{"teinewgame 11", 0, 0, true},
+		{"teinewgame 5 halfkomi", 0, 0, true},
+		{"teinewgame 5 komi 2", 0, 0, true},
 	}
 	for _, tc := range cases {
-		got := calcBudget(tc.Move, tc.Game, tc.Inc)
-		if tc.Expect != 0 {
-			assert.Equal(t, tc.Expect, got)
+		cfg, err := parseNewGame(strings.Fields(tc.cmd), 0)
+		if tc.err {
+			assert.Error(t, err, tc.cmd)
+			continue
 		}
-		if tc.Game != 0 {
-			assert.Less(t, int64(got), int64(tc.Game))
+		if assert.NoError(t, err, tc.cmd) {
+			assert.Equal(t, tc.size, cfg.Size, tc.cmd)
+			assert.Equal(t, tc.komi, cfg.Komi, tc.cmd)
 		}
-		if tc.Move != 0 {
-			assert.LessOrEqual(t, int64(got), int64(tc.Move))
+	}
+}
+
+func TestParseSetOption(t *testing.T) {
+	cases := []struct {
+		cmd   string
+		name  string
+		value string
+		err   bool
+	}{
+		{"setoption name Threads value 4", "Threads", "4", false},
+		{"setoption name Weights value [1, 2, 3]", "Weights", "[1, 2, 3]", false},
+		{"setoption name Clear Hash", "Clear Hash", "", false},
+		{"setoption Threads 4", "", "", true},
+		{"setoption name", "", "", true},
+		{"setoption name value 4", "", "", true},
+	}
+	for _, tc := range cases {
+		name, value, err := parseSetOption(strings.Fields(tc.cmd))
+		if tc.err {
+			assert.Error(t, err, tc.cmd)
+			continue
+		}
+		if assert.NoError(t, err, tc.cmd) {
+			assert.Equal(t, tc.name, name, tc.cmd)
+			assert.Equal(t, tc.value, value, tc.cmd)
+		}
+	}
+}
+
+// testEngine runs an Engine on pipes, returning a writer for its
+// input and a channel of its output lines.
+func testEngine(t *testing.T) (io.WriteCloser, <-chan string, <-chan error) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	engine := NewEngine(inR, outW)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- engine.Run(context.Background())
+		outW.Close()
+	}()
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		r := bufio.NewScanner(outR)
+		for r.Scan() {
+			lines <- r.Text()
+		}
+	}()
+	return inW, lines, errs
+}
+
+// expectLine reads lines from `lines` until it sees one starting
+// with `prefix`, and returns it along with the lines it skipped.
+func expectLine(t *testing.T, lines <-chan string, prefix string) (string, []string) {
+	var skipped []string
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("EOF waiting for %q", prefix)
+			}
+			if strings.HasPrefix(line, prefix) {
+				return line, skipped
+			}
+			skipped = append(skipped, line)
+		case <-timeout:
+			t.Fatalf("timed out waiting for %q", prefix)
+		}
+	}
+}
+
+func TestEngine(t *testing.T) {
+	in, lines, errs := testEngine(t)
+	send := func(cmd string) {
+		if _, err := fmt.Fprintln(in, cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send("tei")
+	_, opts := expectLine(t, lines, "teiok")
+	assert.Contains(t, opts, "option name Threads type spin default 1 min 1 max 256")
+	assert.Contains(t, opts, "option name Weights type string default <empty>")
+
+	send("setoption name Depth value 3")
+	send("teinewgame 5")
+	send("position startpos moves a1 e5")
+	send("go")
+	best, infos := expectLine(t, lines, "bestmove")
+	assert.Regexp(t, `^bestmove \S+ ponder \S+$`, best)
+	var depths []string
+	for _, info := range infos {
+		words := strings.Fields(info)
+		if assert.Equal(t, "info", words[0]) && assert.Equal(t, "depth", words[1]) {
+			depths = append(depths, words[2])
+		}
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, depths)
+
+	send("setoption name Depth value 0")
+	send("go")
+	send("isready")
+	expectLine(t, lines, "readyok")
+	send("stop")
+	expectLine(t, lines, "bestmove")
+
+	send("setoption name Bogus value 1")
+	send("setoption name Depth value deep")
+	send("isready")
+	expectLine(t, lines, "readyok")
+	send("quit")
+	assert.NoError(t, <-errs)
+}
+
+func TestEnginePonder(t *testing.T) {
+	in, lines, errs := testEngine(t)
+	send := func(cmd string) {
+		if _, err := fmt.Fprintln(in, cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send("setoption name Depth value 2")
+	send("teinewgame 5")
+	send("position startpos moves a1 e5")
+	send("go ponder movetime 10000")
+	// The search will finish long before this, but we must not
+	// report a move until ponderhit.
+	time.Sleep(50 * time.Millisecond)
+	send("isready")
+	_, skipped := expectLine(t, lines, "readyok")
+	for _, line := range skipped {
+		assert.True(t, strings.HasPrefix(line, "info "), line)
+	}
+	send("ponderhit")
+	expectLine(t, lines, "bestmove")
+
+	send("setoption name Depth value 0")
+	send("go ponder movetime 1")
+	time.Sleep(50 * time.Millisecond)
+	send("stop")
+	expectLine(t, lines, "bestmove")
+
+	send("go ponder movetime 10")
+	send("ponderhit")
+	expectLine(t, lines, "bestmove")
+
+	send("quit")
+	assert.NoError(t, <-errs)
+}
+
+func TestEngineMultiPV(t *testing.T) {
+	in, lines, errs := testEngine(t)
+	send := func(cmd string) {
+		if _, err := fmt.Fprintln(in, cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send("setoption name Depth value 2")
+	send("setoption name MultiPV value 3")
+	send("teinewgame 5")
+	send("position startpos moves a1 e5")
+	send("go")
+	_, infos := expectLine(t, lines, "bestmove")
+	var tags []string
+	for _, info := range infos {
+		words := strings.Fields(info)
+		if assert.Equal(t, "multipv", words[3], info) {
+			tags = append(tags, words[2]+"/"+words[4])
 		}
 	}
+	assert.Equal(t, []string{
+		"1/1", "1/2", "1/3",
+		"2/1", "2/2", "2/3",
+	}, tags)
+
+	send("setoption name MultiPV value 0")
+	send("isready")
+	expectLine(t, lines, "readyok")
+	send("quit")
+	assert.NoError(t, <-errs)
+}
+
+func TestParseNewGameReserves(t *testing.T) {
+	cfg, err := parseNewGame(strings.Fields("teinewgame 6 flats 20 caps 0"), 0)
+	if assert.NoError(t, err) {
+		pieces, caps := cfg.Reserves()
+		assert.Equal(t, 20, pieces)
+		assert.Equal(t, 0, caps)
+	}
+	cfg, err = parseNewGame(strings.Fields("teinewgame 4 caps 1"), 0)
+	if assert.NoError(t, err) {
+		pieces, caps := cfg.Reserves()
+		assert.Equal(t, 15, pieces)
+		assert.Equal(t, 1, caps)
+	}
+	_, err = parseNewGame(strings.Fields("teinewgame 5 flats 0"), 0)
+	assert.Error(t, err)
+}
+
+func TestEngineEOF(t *testing.T) {
+	for _, cmd := range []string{"go infinite", "go"} {
+		in, lines, errs := testEngine(t)
+		send := func(cmd string) {
+			if _, err := fmt.Fprintln(in, cmd); err != nil {
+				t.Fatal(err)
+			}
+		}
+		send("teinewgame 5")
+		send("position startpos moves a1 e5")
+		send(cmd)
+		in.Close()
+		// The controller is gone, so we stop a search that
+		// would otherwise never finish.
+		expectLine(t, lines, "bestmove")
+		select {
+		case err := <-errs:
+			assert.NoError(t, err, cmd)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s: did not exit at EOF", cmd)
+		}
+	}
+}
+
+func TestEngineSetKomi(t *testing.T) {
+	e := NewEngine(strings.NewReader(`teinewgame 5
+position startpos moves a1 e5
+setoption name HalfKomi value 4
+`), io.Discard)
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 4, e.pos.Config().Komi)
+	assert.Equal(t, 2, e.pos.MoveNumber())
 }
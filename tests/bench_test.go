@@ -7,6 +7,7 @@ import (
 
 	"github.com/nelhage/taktician/ai"
 	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/symmetry"
 	"github.com/nelhage/taktician/tak"
 )
 
@@ -49,6 +50,160 @@ func BenchmarkMoveComplex(b *testing.B) {
 	}
 }
 
+func BenchmarkMakeUnmakeComplex(b *testing.B) {
+	p, e := ptn.ParseTPS(walkTPS)
+	if e != nil {
+		panic("bad tps")
+	}
+	ms := p.AllMoves(nil)
+	var u tak.Undo
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		j := i
+		for {
+			e := p.MakeMove(ms[j%len(ms)], &u)
+			if e == nil {
+				p.UnmakeMove(&u)
+				break
+			}
+			j++
+		}
+	}
+}
+
+const walkTPS = "112S,12,1112S,x2/x2,121C,12S,x/1,21,2,2,2/x,2,1,1,1/2,x3,21 2 24"
+
+// walkCopy and walkUnmake count the leaves of the move tree to
+// `depth`, copying positions and updating one in place, respectively.
+func walkCopy(p *tak.Position, depth int, stack []*tak.Position, moves [][]tak.Move) int {
+	if depth == 0 {
+		return 1
+	}
+	n := 0
+	moves[depth] = p.AllMoves(moves[depth][:0])
+	for _, m := range moves[depth] {
+		child, e := p.MovePreallocated(m, stack[depth])
+		if e != nil {
+			continue
+		}
+		if over, _ := child.GameOver(); over {
+			n++
+			continue
+		}
+		n += walkCopy(child, depth-1, stack, moves)
+	}
+	return n
+}
+
+func walkUnmake(p *tak.Position, depth int, undo []tak.Undo, moves [][]tak.Move) int {
+	if depth == 0 {
+		return 1
+	}
+	n := 0
+	moves[depth] = p.AllMoves(moves[depth][:0])
+	for _, m := range moves[depth] {
+		if p.MakeMove(m, &undo[depth]) != nil {
+			continue
+		}
+		if over, _ := p.GameOver(); over {
+			n++
+		} else {
+			n += walkUnmake(p, depth-1, undo, moves)
+		}
+		p.UnmakeMove(&undo[depth])
+	}
+	return n
+}
+
+const walkTPS8 = "2,1S,1S,1S,2,1S,1,1S/x2,2,x,1,1,2,2S/x,1S,x,2C,x3,22S/1,1S,1S,2S,x4/1,1,1S,1S,1,1S,x,2C/x,2S,2,2S,x,2S,2,1/1,2,2,1,2,x,1S,1C/x2,1,x,2S,1S,2S,21C 1 31"
+
+const walkDepth = 2
+
+func benchWalkCopy(b *testing.B, tps string) {
+	p, e := ptn.ParseTPS(tps)
+	if e != nil {
+		panic("bad tps")
+	}
+	stack := make([]*tak.Position, walkDepth+1)
+	for i := range stack {
+		stack[i] = tak.Alloc(p.Size())
+	}
+	moves := make([][]tak.Move, walkDepth+1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		walkCopy(p, walkDepth, stack, moves)
+	}
+}
+
+func benchWalkUnmake(b *testing.B, tps string) {
+	p, e := ptn.ParseTPS(tps)
+	if e != nil {
+		panic("bad tps")
+	}
+	undo := make([]tak.Undo, walkDepth+1)
+	moves := make([][]tak.Move, walkDepth+1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		walkUnmake(p, walkDepth, undo, moves)
+	}
+}
+
+func BenchmarkWalkCopy5(b *testing.B)   { benchWalkCopy(b, walkTPS) }
+func BenchmarkWalkUnmake5(b *testing.B) { benchWalkUnmake(b, walkTPS) }
+func BenchmarkWalkCopy8(b *testing.B)   { benchWalkCopy(b, walkTPS8) }
+func BenchmarkWalkUnmake8(b *testing.B) { benchWalkUnmake(b, walkTPS8) }
+
+func TestWalkUnmake(t *testing.T) {
+	for _, tps := range []string{walkTPS, walkTPS8} {
+		p, e := ptn.ParseTPS(tps)
+		if e != nil {
+			t.Fatal(e)
+		}
+		stack := make([]*tak.Position, walkDepth+1)
+		for i := range stack {
+			stack[i] = tak.Alloc(p.Size())
+		}
+		want := walkCopy(p, walkDepth, stack, make([][]tak.Move, walkDepth+1))
+		got := walkUnmake(p, walkDepth, make([]tak.Undo, walkDepth+1), make([][]tak.Move, walkDepth+1))
+		if got != want {
+			t.Errorf("%s: unmake walk found %d leaves, copying %d", tps, got, want)
+		}
+	}
+}
+
+func BenchmarkCanonicalHash(b *testing.B) {
+	p, e := ptn.ParseTPS(walkTPS8)
+	if e != nil {
+		panic("bad tps")
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.CanonicalHash()
+	}
+}
+
+func BenchmarkSymmetriesHash(b *testing.B) {
+	p, e := ptn.ParseTPS(walkTPS8)
+	if e != nil {
+		panic("bad tps")
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		syms, _ := symmetry.Symmetries(p)
+		h := syms[0].P.Hash()
+		for _, s := range syms[1:] {
+			if s.P.Hash() < h {
+				h = s.P.Hash()
+			}
+		}
+	}
+}
+
 func BenchmarkPuzzle1(b *testing.B) {
 	p, e := ptn.ParseTPS("2,x2,121C,1/x2,2,12,1/x2,2,12S,2/x3,1,1/x4,1 1 2")
 	if e != nil {
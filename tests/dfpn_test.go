@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/nelhage/taktician/prove"
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+type puzzle struct {
+	name string
+	p    *tak.Position
+}
+
+func readPuzzles(t testing.TB) []puzzle {
+	games, err := readPTNs("../testdata/puzzles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []puzzle
+	for name, g := range games {
+		p, err := g.PositionAtMove(0, tak.NoColor)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		out = append(out, puzzle{name, p})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func TestDFPNPuzzles(t *testing.T) {
+	for _, pz := range readPuzzles(t) {
+		if testing.Short() && pz.p.Size() > 5 {
+			continue
+		}
+		solver := prove.NewDFPN(&prove.DFPNConfig{
+			TableMem: 16 << 20,
+			Threads:  2,
+		})
+		out, _ := solver.Prove(pz.p)
+		if out.Result != prove.EvalTrue {
+			t.Errorf("%s: %s", pz.name, out.Result)
+			continue
+		}
+		tree, _, err := solver.ProofTree(pz.p)
+		if err == nil {
+			err = prove.VerifyProof(pz.p, pz.p.ToMove(), tree)
+		}
+		if err != nil {
+			t.Errorf("%s: proof %s: %v", pz.name, ptn.FormatMove(out.Move), err)
+		}
+	}
+}
+
+func BenchmarkDFPNPuzzles(b *testing.B) {
+	puzzles := readPuzzles(b)
+	for _, threads := range []int{1, 2, 4, 8} {
+		for _, pz := range puzzles {
+			b.Run(fmt.Sprintf("threads=%d/%s", threads, pz.name), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					solver := prove.NewDFPN(&prove.DFPNConfig{
+						TableMem: 16 << 20,
+						Threads:  threads,
+					})
+					if out, _ := solver.Prove(pz.p); out.Result != prove.EvalTrue {
+						b.Fatalf("%s: %s", pz.name, out.Result)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+func TestPlayPTNKomi(t *testing.T) {
+	cases := []struct {
+		komi   string
+		winner tak.Color
+	}{
+		{"0", tak.White},
+		{"1", tak.NoColor},
+		{"1.5", tak.Black},
+		{"2", tak.Black},
+	}
+	for _, tc := range cases {
+		// c1 fills the board, leaving white a flat ahead.
+		text := fmt.Sprintf(`[Size "3"]
+[Komi "%s"]
+[TPS "1,2,1/2,1,2/1,2,x 1 5"]
+
+5. c1
+`, tc.komi)
+		p, err := ptn.ParsePTN(strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := p.InitialPosition()
+		if err != nil {
+			t.Fatalf("komi=%s: initial position: %v", tc.komi, err)
+		}
+		for _, op := range p.Ops {
+			if m, ok := op.(*ptn.Move); ok {
+				if g, err = g.Move(m.Move); err != nil {
+					t.Fatalf("komi=%s: %s: %v", tc.komi, ptn.FormatMove(m.Move), err)
+				}
+			}
+		}
+		over, winner := g.GameOver()
+		if !over || winner != tc.winner || g.WinDetails().Reason != tak.FlatsWin {
+			t.Errorf("komi=%s: over=%v winner=%s details=%+v", tc.komi, over, winner, g.WinDetails())
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/nelhage/taktician/ptn"
+	"github.com/nelhage/taktician/tak"
+)
+
+// perftCases are perft counts for the start of the game on each size
+// of board, and for a few later positions. The opening counts for
+// 3x3, 5x5 and 6x6 agree with those published by other engines.
+// Finished games have no moves.
+var perftCases = []struct {
+	size   int
+	tps    string
+	counts []uint64
+}{
+	{size: 3, counts: []uint64{9, 72, 1200, 17792, 271812}},
+	{size: 4, counts: []uint64{16, 240, 7440, 216464}},
+	{size: 5, counts: []uint64{25, 600, 43320, 2999784}},
+	{size: 6, counts: []uint64{36, 1260, 132720}},
+	{size: 7, counts: []uint64{49, 2352, 339696}},
+	{size: 8, counts: []uint64{64, 4032, 764064}},
+
+	{tps: "x3/x,2,x/1,x2 1 2", counts: []uint64{16, 256, 3776}},
+	{tps: "2,1,x/x,1,2/1,x,2 2 3", counts: []uint64{13, 166, 2022}},
+	{
+		tps:    "112S,12,1112S,x2/x2,121C,12S,x/1,21,2,2,2/x,2,1,1,1/2,x3,21 2 24",
+		counts: []uint64{76, 4815, 351220},
+	},
+	{
+		tps:    "x,2,2,22S,2,111S/21S,22C,112,x,1112S,11S/x,2,112212,2,221,2/2,x2,1,x2/x,2,1,1C,221S,x/1,2,x,1,x2 1 30",
+		counts: []uint64{74, 15942},
+	},
+	{
+		tps:    walkTPS8,
+		counts: []uint64{91, 7662},
+	},
+}
+
+// copyPerft is a perft that copies positions instead of updating one
+// in place, to check MakeMove and UnmakeMove against Move.
+func copyPerft(p *tak.Position, depth int) uint64 {
+	if depth == 0 {
+		return 1
+	}
+	if over, _ := p.GameOver(); over {
+		return 0
+	}
+	var n uint64
+	for _, m := range p.AllMoves(nil) {
+		child, e := p.Move(m)
+		if e != nil {
+			continue
+		}
+		n += copyPerft(child, depth-1)
+	}
+	return n
+}
+
+func TestPerft(t *testing.T) {
+	for _, tc := range perftCases {
+		var p *tak.Position
+		name := tc.tps
+		if tc.tps == "" {
+			p = tak.New(tak.Config{Size: tc.size})
+			name = "start"
+		} else {
+			var e error
+			if p, e = ptn.ParseTPS(tc.tps); e != nil {
+				t.Fatalf("%s: %v", tc.tps, e)
+			}
+		}
+		for i, want := range tc.counts {
+			depth := i + 1
+			if testing.Short() && want > 100000 {
+				break
+			}
+			if got := p.Perft(depth); got != want {
+				t.Errorf("size=%d %s: perft(%d) = %d, want %d", p.Size(), name, depth, got, want)
+			}
+			if depth <= 2 {
+				if got := copyPerft(p, depth); got != want {
+					t.Errorf("size=%d %s: copying perft(%d) = %d, want %d", p.Size(), name, depth, got, want)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkPerft5(b *testing.B) {
+	p := tak.New(tak.Config{Size: 5})
+	for i := 0; i < b.N; i++ {
+		p.Perft(3)
+	}
+}
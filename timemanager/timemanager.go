@@ -0,0 +1,260 @@
+// Package timemanager decides how long to think about a move.
+//
+// Allocate divides the time on a player's clock into a Budget for a
+// single move. A Budget has a soft limit, which a search should aim
+// to finish within, and a hard limit, which it must not exceed. A
+// Tracker follows a single iterative-deepening search, stretching
+// the soft limit while the search keeps changing its mind.
+package timemanager
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/nelhage/taktician/tak"
+)
+
+const (
+	// minMovesLeft and maxMovesLeft bound our estimate of how
+	// many more moves we'll have to make this game.
+	minMovesLeft = 8
+	maxMovesLeft = 40
+
+	// hardFactor is how far past the soft limit a search may
+	// run.
+	hardFactor = 4
+
+	// safetyMargin is time we always leave on the clock, to
+	// cover communication latency.
+	safetyMargin = 20 * time.Millisecond
+
+	// maxInstability bounds how much an unstable search may
+	// stretch the soft limit: by up to (1+maxInstability)x.
+	maxInstability = 2
+)
+
+// Clock describes the time available for a move.
+type Clock struct {
+	// Remaining is the time left on our clock, if it is
+	// running, and Increment is added to it after each of our
+	// moves.
+	Remaining time.Duration
+	Increment time.Duration
+
+	// MoveTime, if nonzero, is a limit on the time for this move.
+	MoveTime time.Duration
+}
+
+// Spend deducts `used` from the clock and adds the increment. It
+// returns false if the clock ran out.
+func (c *Clock) Spend(used time.Duration) bool {
+	c.Remaining -= used
+	if c.Remaining <= time.Millisecond {
+		return false
+	}
+	c.Remaining += c.Increment
+	return true
+}
+
+// A Budget is the time allocated to a move, measured from the start
+// of the search.
+type Budget struct {
+	Soft time.Duration
+	Hard time.Duration
+}
+
+// movesLeft estimates how many more moves the player to move in `p`
+// will make. A game can't last longer than it takes to play out
+// their reserve, and usually ends well before.
+func movesLeft(p *tak.Position) int {
+	stones := p.WhiteStones()
+	if p.ToMove() == tak.Black {
+		stones = p.BlackStones()
+	}
+	n := stones * 3 / 4
+	if n < minMovesLeft {
+		n = minMovesLeft
+	}
+	if n > maxMovesLeft {
+		n = maxMovesLeft
+	}
+	return n
+}
+
+// Allocate budgets time from `c` for the player to move in `p`. If
+// the clock is not running and there is no MoveTime, it returns a
+// zero Budget, meaning no limit.
+func Allocate(c Clock, p *tak.Position) Budget {
+	var b Budget
+	if c.Remaining > 0 {
+		b.Soft = c.Remaining/time.Duration(movesLeft(p)) + c.Increment*3/4
+		if p.MoveNumber() < 2 {
+			// The opening placements don't deserve much
+			// thought.
+			b.Soft /= 4
+		}
+		b.Hard = c.Remaining/4 + c.Increment
+		if b.Hard > hardFactor*b.Soft {
+			b.Hard = hardFactor * b.Soft
+		}
+		limit := c.Remaining - safetyMargin
+		if limit < c.Remaining/2 {
+			limit = c.Remaining / 2
+		}
+		if b.Hard > limit {
+			b.Hard = limit
+		}
+	}
+	if c.MoveTime > 0 {
+		if b.Hard == 0 || c.MoveTime < b.Hard {
+			b.Hard = c.MoveTime
+		}
+		if b.Soft == 0 {
+			b.Soft = c.MoveTime
+		}
+	}
+	if b.Soft > b.Hard {
+		b.Soft = b.Hard
+	}
+	return b
+}
+
+type budgetKey struct{}
+type ponderKey struct{}
+
+// WithBudget returns a context that is canceled at the hard limit of
+// `b`, and that carries `b` for BudgetFrom. If `b` is zero, it only
+// adds a cancel function.
+func WithBudget(ctx context.Context, b Budget) (context.Context, context.CancelFunc) {
+	if b.Hard == 0 {
+		return context.WithCancel(ctx)
+	}
+	ctx = context.WithValue(ctx, budgetKey{}, b)
+	return context.WithTimeout(ctx, b.Hard)
+}
+
+// WithPonder returns a context that carries `b`, for a search that
+// is pondering, and a function that starts its clock. Until the clock
+// starts, a Tracker never stops the search. Unlike WithBudget, it
+// does not cancel the context at the hard limit.
+func WithPonder(ctx context.Context, b Budget) (context.Context, func()) {
+	if b.Hard == 0 {
+		return ctx, func() {}
+	}
+	// start holds the time the clock started, in UnixNano, or
+	// zero.
+	start := new(int64)
+	ctx = context.WithValue(ctx, budgetKey{}, b)
+	ctx = context.WithValue(ctx, ponderKey{}, start)
+	return ctx, func() {
+		atomic.StoreInt64(start, time.Now().UnixNano())
+	}
+}
+
+// BudgetFrom returns the Budget attached to `ctx` by WithBudget or
+// WithPonder, if any.
+func BudgetFrom(ctx context.Context) (Budget, bool) {
+	b, ok := ctx.Value(budgetKey{}).(Budget)
+	return b, ok
+}
+
+// A Tracker applies a Budget to an iterative-deepening search.
+type Tracker struct {
+	b     Budget
+	start time.Time
+	// ponder, if set, is the start of a pondering search's clock,
+	// from WithPonder, and overrides start.
+	ponder *int64
+
+	iterations  int
+	best        tak.Move
+	instability float64
+}
+
+func NewTracker(b Budget, start time.Time) *Tracker {
+	return &Tracker{b: b, start: start}
+}
+
+// TrackerFrom returns a Tracker for the Budget attached to `ctx`,
+// for a search that starts at `start`, or nil if there is none.
+func TrackerFrom(ctx context.Context, start time.Time) *Tracker {
+	b, ok := BudgetFrom(ctx)
+	if !ok {
+		return nil
+	}
+	t := NewTracker(b, start)
+	t.ponder, _ = ctx.Value(ponderKey{}).(*int64)
+	return t
+}
+
+// elapsed returns the time the search has used at `now`, or false if
+// it is pondering and its clock has not started.
+func (t *Tracker) elapsed(now time.Time) (time.Duration, bool) {
+	if t.ponder == nil {
+		return now.Sub(t.start), true
+	}
+	start := atomic.LoadInt64(t.ponder)
+	if start == 0 {
+		return 0, false
+	}
+	return now.Sub(time.Unix(0, start)), true
+}
+
+// Iteration records the best move found by a completed iteration.
+// Each change of mind stretches the soft limit; the stretch decays
+// while the best move stays the same.
+func (t *Tracker) Iteration(best tak.Move) {
+	t.instability /= 2
+	if t.iterations > 0 && !best.Equal(t.best) {
+		t.instability += 1
+		if t.instability > maxInstability {
+			t.instability = maxInstability
+		}
+	}
+	t.best = best
+	t.iterations++
+}
+
+// Soft returns the current soft limit, adjusted for instability.
+func (t *Tracker) Soft() time.Duration {
+	soft := time.Duration(float64(t.b.Soft) * (1 + t.instability))
+	if soft > t.b.Hard {
+		soft = t.b.Hard
+	}
+	return soft
+}
+
+// Hard returns the hard limit.
+func (t *Tracker) Hard() time.Duration {
+	return t.b.Hard
+}
+
+// Continue reports whether the search should start another
+// iteration at time `now`, if it expects the iteration to take
+// `estimate`.
+func (t *Tracker) Continue(now time.Time, estimate time.Duration) bool {
+	elapsed, ok := t.elapsed(now)
+	if !ok {
+		return true
+	}
+	if elapsed >= t.Soft() {
+		return false
+	}
+	return elapsed+estimate <= t.b.Hard
+}
+
+// StopRoot reports whether a search that is past its soft limit at
+// time `now` should abandon its current iteration, given the best
+// move it has found so far. We only stop early if the iteration
+// agrees with the last one: a search which has changed its mind may
+// keep going until the hard limit.
+func (t *Tracker) StopRoot(now time.Time, best tak.Move) bool {
+	if t.iterations == 0 {
+		return false
+	}
+	if elapsed, ok := t.elapsed(now); !ok || elapsed < t.Soft() {
+		return false
+	}
+	return best.Equal(t.best)
+}
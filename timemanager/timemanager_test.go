@@ -0,0 +1,123 @@
+package timemanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nelhage/taktician/tak"
+	"github.com/nelhage/taktician/taktest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocate(t *testing.T) {
+	opening := tak.New(tak.Config{Size: 5})
+	mid := taktest.Position(5, "a1 e5 c3 c4 d3 b3 d4")
+
+	assert.Equal(t, Budget{}, Allocate(Clock{}, mid))
+	assert.Equal(t, Budget{time.Second, time.Second},
+		Allocate(Clock{MoveTime: time.Second}, mid))
+
+	clocks := []Clock{
+		{Remaining: 3 * time.Second, Increment: 3 * time.Second},
+		{Remaining: 3 * time.Second, Increment: 3 * time.Second, MoveTime: time.Second},
+		{Remaining: 3 * time.Second, MoveTime: 5 * time.Second},
+		{Remaining: 10 * time.Millisecond},
+		{Remaining: 10 * time.Minute},
+	}
+	for _, c := range clocks {
+		for _, p := range []*tak.Position{opening, mid} {
+			got := Allocate(c, p)
+			assert.Greater(t, int64(got.Soft), int64(0), "%+v", c)
+			assert.LessOrEqual(t, int64(got.Soft), int64(got.Hard), "%+v", c)
+			assert.Less(t, int64(got.Hard), int64(c.Remaining), "%+v", c)
+			if c.MoveTime != 0 {
+				assert.LessOrEqual(t, int64(got.Hard), int64(c.MoveTime), "%+v", c)
+			}
+		}
+	}
+
+	early := Allocate(Clock{Remaining: 10 * time.Minute}, opening)
+	later := Allocate(Clock{Remaining: 10 * time.Minute}, mid)
+	assert.Less(t, int64(early.Soft), int64(later.Soft))
+}
+
+func TestTracker(t *testing.T) {
+	start := time.Now()
+	tr := NewTracker(Budget{Soft: time.Second, Hard: 10 * time.Second}, start)
+	a1, b1 := taktest.Move("a1"), taktest.Move("b1")
+
+	tr.Iteration(a1)
+	assert.Equal(t, time.Second, tr.Soft())
+	assert.True(t, tr.Continue(start.Add(500*time.Millisecond), time.Second))
+	assert.False(t, tr.Continue(start.Add(500*time.Millisecond), 10*time.Second))
+	assert.False(t, tr.Continue(start.Add(2*time.Second), 0))
+
+	// Changing our mind buys more time, until we settle down.
+	tr.Iteration(b1)
+	assert.Equal(t, 2*time.Second, tr.Soft())
+	assert.True(t, tr.Continue(start.Add(1500*time.Millisecond), 0))
+	tr.Iteration(b1)
+	assert.Equal(t, 1500*time.Millisecond, tr.Soft())
+
+	assert.False(t, tr.StopRoot(start.Add(time.Second), b1))
+	assert.True(t, tr.StopRoot(start.Add(2*time.Second), b1))
+	assert.False(t, tr.StopRoot(start.Add(2*time.Second), a1))
+}
+
+func TestWithBudget(t *testing.T) {
+	ctx, cancel := WithBudget(context.Background(), Budget{})
+	defer cancel()
+	if _, ok := BudgetFrom(ctx); ok {
+		t.Fatal("zero budget attached")
+	}
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatal("zero budget has a deadline")
+	}
+
+	b := Budget{Soft: time.Second, Hard: time.Minute}
+	ctx, cancel = WithBudget(context.Background(), b)
+	defer cancel()
+	got, ok := BudgetFrom(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, b, got)
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+}
+
+func TestClockSpend(t *testing.T) {
+	c := Clock{Remaining: time.Second, Increment: 100 * time.Millisecond}
+	assert.True(t, c.Spend(500*time.Millisecond))
+	assert.Equal(t, 600*time.Millisecond, c.Remaining)
+	assert.False(t, c.Spend(600*time.Millisecond))
+}
+
+func TestWithPonder(t *testing.T) {
+	ctx, start := WithPonder(context.Background(), Budget{})
+	if _, ok := BudgetFrom(ctx); ok {
+		t.Fatal("zero budget attached")
+	}
+	start()
+
+	b := Budget{Soft: time.Second, Hard: time.Minute}
+	ctx, start = WithPonder(context.Background(), b)
+	got, ok := BudgetFrom(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, b, got)
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+
+	tr := TrackerFrom(ctx, time.Now().Add(-time.Hour))
+	a1 := taktest.Move("a1")
+	tr.Iteration(a1)
+	later := time.Now().Add(time.Hour)
+	assert.True(t, tr.Continue(later, 0))
+	assert.False(t, tr.StopRoot(later, a1))
+
+	start()
+	now := time.Now()
+	assert.True(t, tr.Continue(now, 0))
+	assert.False(t, tr.Continue(now.Add(2*time.Second), 0))
+	assert.True(t, tr.StopRoot(now.Add(2*time.Second), a1))
+}